@@ -78,6 +78,7 @@ func (h *ITCH) OnAddOrderMessage(msg itch.AddOrderMessage) error {
 	order := matching.NewLimitOrder(
 		uint32(msg.StockLocate),
 		msg.OrderReferenceNumber,
+		0,
 		side,
 		direction,
 		matching.OrderTimeInForceGTC,
@@ -110,6 +111,7 @@ func (h *ITCH) OnAddOrderMPIDMessage(msg itch.AddOrderMPIDMessage) error {
 	order := matching.NewLimitOrder(
 		uint32(msg.StockLocate),
 		msg.OrderReferenceNumber,
+		0,
 		side,
 		direction,
 		matching.OrderTimeInForceGTC,
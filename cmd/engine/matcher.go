@@ -13,6 +13,7 @@ type Matcher struct {
 	priceLevelUpdates [3]uint64
 	orderUpdates      [3]uint64
 	executeUpdates    [2]uint64
+	rejects           uint64
 	errors            uint64
 	totalUpdates      uint64
 }
@@ -89,6 +90,11 @@ func (m *Matcher) OnDeleteOrder(orderBook *matching.OrderBook, order *matching.O
 	// fmt.Printf("Deleted order %d with price %s and amount %s\n", order.ID, order.Price, order.Quantity)
 }
 
+func (m *Matcher) OnRejectOrder(orderBook *matching.OrderBook, order *matching.Order, reason error) {
+	atomic.AddUint64(&m.rejects, 1)
+	atomic.AddUint64(&m.totalUpdates, 1)
+}
+
 func (m *Matcher) OnExecuteOrder(orderBook *matching.OrderBook, orderID uint64, price matching.Uint, quantity matching.Uint, quoteQuantity matching.Uint) {
 	atomic.AddUint64(&m.executeUpdates[0], 1)
 	atomic.AddUint64(&m.totalUpdates, 1)
@@ -118,6 +124,7 @@ func (m *Matcher) PrintStatistics(elapsed time.Duration) {
 	fmt.Printf("Order deletes %15d\n", m.orderUpdates[2])
 	fmt.Printf("Executed orders %13d\n", m.executeUpdates[0])
 	fmt.Printf("Executed trades %13d\n", m.executeUpdates[1])
+	fmt.Printf("Rejected orders %13d\n", m.rejects)
 	fmt.Printf("Errors %22d\n", m.errors)
 	fmt.Printf("Total calls %17d\n", m.totalUpdates)
 }
@@ -12,6 +12,7 @@ type Matcher struct {
 	priceLevelUpdates [3]uint64
 	orderUpdates      [3]uint64
 	executeUpdates    [2]uint64
+	rejects           uint64
 	errors            uint64
 	totalUpdates      uint64
 }
@@ -63,6 +64,11 @@ func (m *Matcher) OnDeleteOrder(orderBook *matching.OrderBook, order *matching.O
 	atomic.AddUint64(&m.totalUpdates, 1)
 }
 
+func (m *Matcher) OnRejectOrder(orderBook *matching.OrderBook, order *matching.Order, reason error) {
+	atomic.AddUint64(&m.rejects, 1)
+	atomic.AddUint64(&m.totalUpdates, 1)
+}
+
 func (m *Matcher) OnExecuteOrder(orderBook *matching.OrderBook, orderID uint64, price matching.Uint, quantity matching.Uint, quoteQuantity matching.Uint) {
 	atomic.AddUint64(&m.executeUpdates[0], 1)
 	atomic.AddUint64(&m.totalUpdates, 1)
@@ -90,6 +96,7 @@ func (m *Matcher) PrintStatistics() {
 	fmt.Printf("Order deletes %15d\n", m.orderUpdates[2])
 	fmt.Printf("Executed orders %13d\n", m.executeUpdates[0])
 	fmt.Printf("Executed trades %13d\n", m.executeUpdates[1])
+	fmt.Printf("Rejected orders %13d\n", m.rejects)
 	fmt.Printf("Errors %22d\n", m.errors)
 	fmt.Printf("Total calls %17d\n", m.totalUpdates)
 }
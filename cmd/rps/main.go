@@ -132,6 +132,7 @@ func generateInput(ordersCount int, norm, heavy bool, symbols []uint32) chan mat
 			o = matching.NewLimitOrder(
 				randomChoice(symbols),
 				uint64(i+1),
+				0,
 				side,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -144,6 +145,7 @@ func generateInput(ordersCount int, norm, heavy bool, symbols []uint32) chan mat
 			o = matching.NewMarketOrder(
 				randomChoice(symbols),
 				uint64(i+1),
+				0,
 				side,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceIOC,
@@ -156,6 +158,7 @@ func generateInput(ordersCount int, norm, heavy bool, symbols []uint32) chan mat
 			o = matching.NewStopLimitOrder(
 				randomChoice(symbols),
 				uint64(i+1),
+				0,
 				side,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -0,0 +1,98 @@
+package matching
+
+import (
+	"context"
+)
+
+// Ack is the acknowledgement of the command performed by the order book asynchronously.
+// It is done when the order book has performed the command, then the command error
+// and trades caused by the command can be read.
+type Ack struct {
+	sequence uint64
+	done     chan struct{}
+
+	// Written by the order book before done is closed
+	err    error
+	trades []Trade
+}
+
+func newAck(sequence uint64) *Ack {
+	return &Ack{sequence: sequence, done: make(chan struct{})}
+}
+
+// newRejectedAck returns done acknowledgement of the command rejected before it is performed
+// by the order book. Zero sequence is passed if the rejected command is not journaled.
+func newRejectedAck(sequence uint64, err error) *Ack {
+	ack := newAck(sequence)
+	ack.complete(err)
+	return ack
+}
+
+// complete sets the result of the command and marks the acknowledgement as done.
+func (a *Ack) complete(err error) {
+	a.err = err
+	close(a.done)
+}
+
+// Sequence returns the sequence number assigned to the command when it is journaled.
+// Commands rejected by the validation are journaled as well, so they have the sequence number too.
+// Zero is returned only if the command is not journaled (for example, the journal has failed
+// or the order book is deleted before the command is accepted).
+func (a *Ack) Sequence() uint64 {
+	return a.sequence
+}
+
+// Done returns the channel which is closed when the command is performed.
+func (a *Ack) Done() <-chan struct{} {
+	return a.done
+}
+
+// Wait waits until the command is performed and returns its error.
+// The context error is returned if the context is done before.
+func (a *Ack) Wait(ctx context.Context) error {
+	// Prefer the result if both the command and the context are done
+	select {
+	case <-a.done:
+		return a.err
+	default:
+	}
+
+	select {
+	case <-a.done:
+		return a.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Accepted returns true if the command is performed without error.
+// False is returned until the command is performed.
+func (a *Ack) Accepted() bool {
+	select {
+	case <-a.done:
+		return a.err == nil
+	default:
+		return false
+	}
+}
+
+// Err returns the error of the performed command, nil is returned until the command is performed.
+func (a *Ack) Err() error {
+	select {
+	case <-a.done:
+		return a.err
+	default:
+		return nil
+	}
+}
+
+// Trades returns trades caused by the performed command (including trades of other orders
+// matched because of the command), nil is returned until the command is performed.
+func (a *Ack) Trades() []Trade {
+	select {
+	case <-a.done:
+		return a.trades
+	default:
+		return nil
+	}
+}
@@ -125,12 +125,13 @@ func (a *Allocator) GetOrder() *Order {
 
 // PutOrder releases Order instance.
 func (a *Allocator) PutOrder(order *Order) {
+	// Clean up the instance before releasing, so the released order
+	// looks the same regardless of the pool usage
+	order.Clean()
 	if !a.usePool {
 		return
 	}
 
-	// Clean up the instance before releasing
-	order.Clean()
 	// Put back to the pool
 	a.orders.Put(order)
 }
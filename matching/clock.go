@@ -0,0 +1,95 @@
+package matching
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to the engine.
+// The time is assigned to each command when it is accepted by the engine,
+// so it is the same for all order books and is restored while replaying the journal.
+// In multithread mode the clock also drives timers expiring 'Good-Till-Date' orders.
+type Clock interface {
+	Now() time.Time
+	// After returns the channel receiving the clock time once the given duration elapses on the clock.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the clock used by default, it returns the current system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// ManualClock is the clock which time is changed manually only.
+// It is useful to drive the engine time deterministically (for example, in tests).
+// NOTE: ManualClock is thread-safe.
+type ManualClock struct {
+	mx      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+}
+
+// clockWaiter is the channel waiting for the time of ManualClock.
+type clockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewManualClock creates and returns new ManualClock instance set to the given time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+// Set sets the current time of the clock.
+func (c *ManualClock) Set(now time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = now
+	c.notify()
+}
+
+// Advance moves the current time of the clock forward by the given duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = c.now.Add(d)
+	c.notify()
+}
+
+// After returns the channel receiving the clock time once the clock is set or advanced by the given duration.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, clockWaiter{at: c.now.Add(d), ch: ch})
+	c.notify()
+	return ch
+}
+
+// notify sends the current time to waiters which time has come.
+// NOTE: Should be called with locked mutex.
+func (c *ManualClock) notify() {
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	clear(c.waiters[len(waiters):])
+	c.waiters = waiters
+}
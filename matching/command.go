@@ -0,0 +1,122 @@
+package matching
+
+// CommandKind is an enumeration of possible engine commands changing the engine state.
+type CommandKind uint8
+
+const (
+	CommandKindAddOrderBook CommandKind = iota + 1
+	CommandKindDeleteOrderBook
+	CommandKindSetIndexMarkPrices
+	CommandKindSetMarkPrice
+	CommandKindSetIndexPrice
+	CommandKindAddOrder
+	CommandKindAddOrdersPair
+	CommandKindAddTPSL
+	CommandKindAddTPSLMarket
+	CommandKindReduceOrder
+	CommandKindModifyOrder
+	CommandKindMitigateOrder
+	CommandKindReplaceOrder
+	CommandKindDeleteOrder
+	CommandKindExecuteOrder
+	CommandKindExecuteOrderByPrice
+	CommandKindEnableMatching
+	CommandKindDisableMatching
+	CommandKindMatch
+	CommandKindExpireOrders
+	CommandKindStartAuction
+	CommandKindStopAuction
+	CommandKindSetTradingState
+	CommandKindSetMatchingPolicy
+	CommandKindMassCancel
+	CommandKindUpdateSymbol
+)
+
+func (ck CommandKind) String() string {
+	switch ck {
+	case CommandKindAddOrderBook:
+		return "add-order-book"
+	case CommandKindDeleteOrderBook:
+		return "delete-order-book"
+	case CommandKindSetIndexMarkPrices:
+		return "set-index-mark-prices"
+	case CommandKindSetMarkPrice:
+		return "set-mark-price"
+	case CommandKindSetIndexPrice:
+		return "set-index-price"
+	case CommandKindAddOrder:
+		return "add-order"
+	case CommandKindAddOrdersPair:
+		return "add-orders-pair"
+	case CommandKindAddTPSL:
+		return "add-tpsl"
+	case CommandKindAddTPSLMarket:
+		return "add-tpsl-market"
+	case CommandKindReduceOrder:
+		return "reduce-order"
+	case CommandKindModifyOrder:
+		return "modify-order"
+	case CommandKindMitigateOrder:
+		return "mitigate-order"
+	case CommandKindReplaceOrder:
+		return "replace-order"
+	case CommandKindDeleteOrder:
+		return "delete-order"
+	case CommandKindExecuteOrder:
+		return "execute-order"
+	case CommandKindExecuteOrderByPrice:
+		return "execute-order-by-price"
+	case CommandKindEnableMatching:
+		return "enable-matching"
+	case CommandKindDisableMatching:
+		return "disable-matching"
+	case CommandKindMatch:
+		return "match"
+	case CommandKindExpireOrders:
+		return "expire-orders"
+	case CommandKindStartAuction:
+		return "start-auction"
+	case CommandKindStopAuction:
+		return "stop-auction"
+	case CommandKindSetTradingState:
+		return "set-trading-state"
+	case CommandKindSetMatchingPolicy:
+		return "set-matching-policy"
+	case CommandKindMassCancel:
+		return "mass-cancel"
+	case CommandKindUpdateSymbol:
+		return "update-symbol"
+	default:
+		return "unknown"
+	}
+}
+
+// command contains arguments of the public engine method call.
+// Only arguments related to the command kind are used.
+type command struct {
+	kind     CommandKind
+	sequence uint64
+	time     int64 // unix time in nanoseconds
+
+	// Order book arguments
+	symbolID      uint32
+	symbol        Symbol
+	spModesConfig StopPriceModeConfig
+	marketPrice   Uint
+	markPrice     Uint
+	indexPrice    Uint
+	iterate       bool
+	tradingState  TradingState
+	policy        MatchingPolicy
+	seed          uint64
+
+	// Orders arguments
+	order       Order
+	linkedOrder Order
+	orderID     uint64
+	newOrderID  uint64
+	price       Uint
+	quantity    Uint
+	amount      Uint
+	filter      MassCancelFilter
+}
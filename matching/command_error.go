@@ -0,0 +1,145 @@
+package matching
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrorPhase is an enumeration of possible phases of the command the error occurred in.
+type ErrorPhase uint8
+
+const (
+	// ErrorPhaseValidate means the command is rejected before it changes the order book.
+	ErrorPhaseValidate ErrorPhase = iota + 1
+	// ErrorPhaseMatch means the error occurred while matching orders (including the auction uncrossing).
+	ErrorPhaseMatch
+	// ErrorPhaseActivate means the error occurred while activating stop orders.
+	ErrorPhaseActivate
+	// ErrorPhaseExecute means the error occurred while executing orders or performing the command itself.
+	ErrorPhaseExecute
+)
+
+func (p ErrorPhase) String() string {
+	switch p {
+	case ErrorPhaseValidate:
+		return "validate"
+	case ErrorPhaseMatch:
+		return "match"
+	case ErrorPhaseActivate:
+		return "activate"
+	case ErrorPhaseExecute:
+		return "execute"
+	default:
+		return "<unknown>"
+	}
+}
+
+// CommandError is the error of the command performed on the order book.
+// All errors passed to OnError() handler by performed commands are of this type,
+// the original error is available with errors.Is() and errors.As() functions.
+type CommandError struct {
+	// Kind of the failed command.
+	Kind CommandKind
+
+	// Sequence number of the failed command.
+	Sequence uint64
+
+	// Engine time of the failed command.
+	Time time.Time
+
+	// Symbol ID of the order book the command failed on.
+	SymbolID uint32
+
+	// IDs of orders the command is performed on (including new and linked orders).
+	OrderIDs []uint64
+
+	// Phase of the command the error occurred in.
+	Phase ErrorPhase
+
+	// Original error.
+	Err error
+}
+
+// newCommandError creates the error of the command failed on the order book.
+func newCommandError(cmd *command, ob *OrderBook, err error) *CommandError {
+	orderIDs := make([]uint64, 0, 2)
+	for _, id := range []uint64{cmd.order.id, cmd.linkedOrder.id, cmd.orderID, cmd.newOrderID} {
+		if id != 0 {
+			orderIDs = append(orderIDs, id)
+		}
+	}
+
+	return &CommandError{
+		Kind:     cmd.kind,
+		Sequence: cmd.sequence,
+		Time:     time.Unix(0, cmd.time),
+		SymbolID: ob.symbol.id,
+		OrderIDs: orderIDs,
+		Phase:    errorPhase(err),
+		Err:      err,
+	}
+}
+
+// Error implements error interface.
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("failed to perform command %s (sequence: %d, symbol: %d, orders: %v) in %s phase: %s",
+		e.Kind, e.Sequence, e.SymbolID, e.OrderIDs, e.Phase, e.Err)
+}
+
+// Unwrap returns the original error.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Internal returns true if the command failed because of broken invariants of the engine state
+// rather than because the command is rejected.
+func (e *CommandError) Internal() bool {
+	return IsInternalError(e.Err)
+}
+
+////////////////////////////////////////////////////////////////
+// Error phases
+////////////////////////////////////////////////////////////////
+
+// phaseError marks the error with the phase it occurred in.
+type phaseError struct {
+	phase ErrorPhase
+	err   error
+}
+
+// errorPhase returns the phase the error occurred in. Errors without the phase occurred
+// while performing the command itself, rejects must be marked with validationError().
+func errorPhase(err error) ErrorPhase {
+	var pe *phaseError
+	if errors.As(err, &pe) {
+		return pe.phase
+	}
+	return ErrorPhaseExecute
+}
+
+// validationError marks the error rejecting the command before it changes the order book.
+func validationError(err error) error {
+	return errorInPhase(ErrorPhaseValidate, err)
+}
+
+// errorInPhase marks the error with the phase unless it is already marked by the nested phase,
+// so the innermost phase is reported. Returns nil if the error is nil.
+func errorInPhase(phase ErrorPhase, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pe *phaseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &phaseError{phase: phase, err: err}
+}
+
+func (e *phaseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
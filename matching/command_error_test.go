@@ -0,0 +1,75 @@
+package matching
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandError(t *testing.T) {
+	ob := NewOrderBook(NewSymbol(7, "BTC-USDT"), StopPriceModeConfig{Market: true}, 1)
+	defer ob.Clean()
+	cmd := &command{
+		kind:        CommandKindAddOrdersPair,
+		sequence:    42,
+		time:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+		symbolID:    7,
+		order:       Order{id: 1},
+		linkedOrder: Order{id: 2},
+	}
+
+	testCases := []struct {
+		name     string
+		err      error
+		phase    ErrorPhase
+		internal bool
+	}{
+		{
+			name:  "reject",
+			err:   validationError(ErrOrderDuplicate),
+			phase: ErrorPhaseValidate,
+		},
+		{
+			name:  "error without phase",
+			err:   fmt.Errorf("failed to add order: %w", ErrOrderDuplicate),
+			phase: ErrorPhaseExecute,
+		},
+		{
+			name:     "internal error without phase",
+			err:      fmt.Errorf("failed to delete order: %w", ErrPriceLevelNotFound),
+			phase:    ErrorPhaseExecute,
+			internal: true,
+		},
+		{
+			name:  "reject in phase",
+			err:   fmt.Errorf("failed to match: %w", errorInPhase(ErrorPhaseMatch, ErrPegPriceNotAvailable)),
+			phase: ErrorPhaseMatch,
+		},
+		{
+			name: "innermost phase",
+			err: errorInPhase(ErrorPhaseMatch, fmt.Errorf("failed to activate stop order: %w",
+				errorInPhase(ErrorPhaseActivate, ErrInternalExecutingOrderNotExecuted))),
+			phase:    ErrorPhaseActivate,
+			internal: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newCommandError(cmd, ob, tc.err)
+			require.Equal(t, CommandKindAddOrdersPair, err.Kind)
+			require.Equal(t, uint64(42), err.Sequence)
+			require.True(t, err.Time.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+			require.Equal(t, uint32(7), err.SymbolID)
+			require.Equal(t, []uint64{1, 2}, err.OrderIDs)
+			require.Equal(t, tc.phase, err.Phase)
+			require.Equal(t, tc.internal, err.Internal())
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	require.Nil(t, errorInPhase(ErrorPhaseMatch, nil))
+	require.False(t, IsInternalError(ErrOrderNotFound))
+}
@@ -7,6 +7,9 @@ const (
 	// defaultReservedOrderBookSlots specifies initial size of array storing order books by symbol id.
 	defaultReservedOrderBookSlots = 1024
 
+	// defaultMaxPendingEvents specifies number of tasks of single order book events of which could wait for their turn.
+	defaultMaxPendingEvents = 256
+
 	// defaultReservedOrderSlots specifies initial size of hashmap array storing orders by order id separately for each order book.
 	defaultReservedOrderSlots = 1024
 )
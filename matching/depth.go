@@ -0,0 +1,68 @@
+package matching
+
+import (
+	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
+)
+
+// Depth is the snapshot of the aggregated (L2) order book state.
+// Stop orders are not included since they are not visible in the order book.
+type Depth struct {
+	// ID of the next price level update of the order book. All updates with less IDs are
+	// already applied to the depth, so deltas starting from this ID should be applied to it.
+	UpdateID uint64
+
+	// Bid price levels from the best (highest) price.
+	Bids []PriceLevelL2
+
+	// Ask price levels from the best (lowest) price.
+	Asks []PriceLevelL2
+}
+
+// Depth returns up to the given amount of best price levels of each side of the order book.
+// All price levels are returned if levels is not positive.
+// NOTE: Not thread-safe, use Engine.Depth() method in multithread mode.
+func (ob *OrderBook) Depth(levels int) Depth {
+	return Depth{
+		UpdateID: ob.lastUpdateID,
+		Bids:     depthLevels(ob.bids, levels),
+		Asks:     depthLevels(ob.asks, levels),
+	}
+}
+
+// depthLevels returns up to the given amount of price levels of the tree in its order.
+func depthLevels(tree avl.Tree[Uint, *PriceLevelL3], levels int) []PriceLevelL2 {
+	result := make([]PriceLevelL2, 0, min(max(levels, 0), 64))
+	tree.IterateInOrder(func(priceLevel *PriceLevelL3) bool {
+		// Iteration is stopped only in the current subtree, so ancestors are skipped here as well
+		if levels > 0 && len(result) == levels {
+			return true
+		}
+		result = append(result, priceLevel.L2())
+		return false
+	})
+	return result
+}
+
+// Depth returns up to the given amount of best price levels of each side of the order book.
+// In multithread mode the depth is taken by the order book task, so it includes all
+// commands accepted before the call and is consistent with price level updates.
+func (e *Engine) Depth(symbolID uint32, levels int) (Depth, error) {
+	// Get the valid order book
+	ob := e.OrderBook(symbolID)
+	if ob == nil {
+		return Depth{}, ErrOrderBookNotFound
+	}
+
+	var depth Depth
+	task := func(ob *OrderBook) error {
+		depth = ob.Depth(levels)
+		return nil
+	}
+
+	// The task is performed after all accepted commands
+	if err := <-e.performOrderBookTaskAsync(ob, task); err != nil {
+		return Depth{}, err
+	}
+
+	return depth, nil
+}
@@ -0,0 +1,385 @@
+package matching
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"lukechampine.com/uint128"
+)
+
+////////////////////////////////////////////////////////////////
+// Binary encoder
+////////////////////////////////////////////////////////////////
+
+// encoder writes binary representation of the engine state.
+// The first occurred error is sticky and all further writes are ignored.
+type encoder struct {
+	w   *bufio.Writer
+	buf [16]byte
+	err error
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{w: bufio.NewWriter(w)}
+}
+
+func (enc *encoder) write(b []byte) {
+	if enc.err != nil {
+		return
+	}
+	_, enc.err = enc.w.Write(b)
+}
+
+func (enc *encoder) flush() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+func (enc *encoder) writeUint8(v uint8) {
+	enc.buf[0] = v
+	enc.write(enc.buf[:1])
+}
+
+func (enc *encoder) writeBool(v bool) {
+	if v {
+		enc.writeUint8(1)
+	} else {
+		enc.writeUint8(0)
+	}
+}
+
+func (enc *encoder) writeUint32(v uint32) {
+	binary.LittleEndian.PutUint32(enc.buf[:4], v)
+	enc.write(enc.buf[:4])
+}
+
+func (enc *encoder) writeUint64(v uint64) {
+	binary.LittleEndian.PutUint64(enc.buf[:8], v)
+	enc.write(enc.buf[:8])
+}
+
+func (enc *encoder) writeUint(v Uint) {
+	v.ToUint128().PutBytes(enc.buf[:16])
+	enc.write(enc.buf[:16])
+}
+
+// writeTime writes the time as unix time in nanoseconds, zero time is written as 0.
+func (enc *encoder) writeTime(v time.Time) {
+	if v.IsZero() {
+		enc.writeUint64(0)
+		return
+	}
+	enc.writeUint64(uint64(v.UnixNano()))
+}
+
+func (enc *encoder) writeString(v string) {
+	enc.writeUint32(uint32(len(v)))
+	enc.write([]byte(v))
+}
+
+func (enc *encoder) writeLimits(v Limits) {
+	enc.writeUint(v.Min)
+	enc.writeUint(v.Max)
+	enc.writeUint(v.Step)
+}
+
+func (enc *encoder) writeSymbol(v Symbol) {
+	enc.writeUint32(v.id)
+	enc.writeString(v.name)
+	enc.writeLimits(v.priceLimits)
+	enc.writeLimits(v.lotSizeLimits)
+	enc.writeLimits(v.quoteLotSizeLimits)
+	enc.writeUint8(uint8(v.selfTradePrevention))
+	enc.writePriceBand(v.staticPriceBand)
+	enc.writePriceBand(v.dynamicPriceBand)
+}
+
+func (enc *encoder) writePriceBand(v PriceBand) {
+	enc.writeUint8(uint8(v.Reference))
+	enc.writeUint8(uint8(v.Kind))
+	enc.writeUint(v.Distance)
+}
+
+// Matching policy tags
+const (
+	matchingPolicyTagFIFO uint8 = iota + 1
+	matchingPolicyTagProRata
+	matchingPolicyTagHybrid
+)
+
+// writeMatchingPolicy writes the built-in matching policy with its tag, zero tag is written for nil policy.
+func (enc *encoder) writeMatchingPolicy(v MatchingPolicy) {
+	switch p := v.(type) {
+	case nil:
+		enc.writeUint8(0)
+	case FIFOPolicy:
+		enc.writeUint8(matchingPolicyTagFIFO)
+	case ProRataPolicy:
+		enc.writeUint8(matchingPolicyTagProRata)
+		enc.writeUint(p.MinAllocation)
+	case HybridPolicy:
+		enc.writeUint8(matchingPolicyTagHybrid)
+		enc.writeUint32(uint32(len(p.MarketMakers)))
+		for _, ownerID := range p.MarketMakers {
+			enc.writeUint64(ownerID)
+		}
+		enc.writeUint64(p.MarketMakerShare)
+		enc.writeUint64(p.FIFOShare)
+		enc.writeUint(p.MinAllocation)
+	default:
+		if enc.err == nil {
+			enc.err = ErrUnsupportedMatchingPolicy
+		}
+	}
+}
+
+func (enc *encoder) writeMassCancelFilter(v MassCancelFilter) {
+	enc.writeUint32(v.SymbolID)
+	enc.writeBool(v.AllSymbols)
+	enc.writeUint8(uint8(v.Side))
+	enc.writeBool(v.StopOnly)
+	enc.writeUint(v.MinPrice)
+	enc.writeUint(v.MaxPrice)
+	enc.writeUint64(v.OwnerID)
+}
+
+func (enc *encoder) writeStopPriceModeConfig(v StopPriceModeConfig) {
+	enc.writeBool(v.Market)
+	enc.writeBool(v.Mark)
+	enc.writeBool(v.Index)
+}
+
+// writeOrder writes all order fields except links to the order book internals.
+func (enc *encoder) writeOrder(o *Order) {
+	enc.writeUint64(o.id)
+	enc.writeUint64(o.ownerID)
+	enc.writeUint32(o.symbolID)
+	enc.writeUint8(uint8(o.orderType))
+	enc.writeUint8(uint8(o.side))
+	enc.writeUint8(uint8(o.direction))
+	enc.writeUint8(uint8(o.timeInForce))
+	enc.writeUint(o.price)
+	enc.writeUint(o.stopPrice)
+	enc.writeUint8(uint8(o.stopPriceMode))
+	enc.writeBool(o.takeProfit)
+	enc.writeUint(o.quantity)
+	enc.writeUint(o.quoteQuantity)
+	enc.writeUint(o.maxVisible)
+	enc.writeUint(o.visible)
+	enc.writeUint(o.visibleVariance)
+	enc.writeUint8(uint8(o.pegReference))
+	enc.writeUint64(uint64(o.pegOffset))
+	enc.writeUint(o.pegCap)
+	enc.writeUint(o.minQuantity)
+	enc.writeBool(o.allOrNone)
+	enc.writeUint(o.marketSlippage)
+	enc.writeUint(o.trailingDistance)
+	enc.writeUint(o.trailingStep)
+	enc.writeUint(o.available)
+	enc.writeUint(o.restQuantity)
+	enc.writeUint(o.restQuoteQuantity)
+	enc.writeUint(o.executedQuantity)
+	enc.writeUint(o.executedQuoteQuantity)
+	enc.writeBool(o.marketQuoteMode)
+	enc.writeUint64(o.linkedOrderID)
+	enc.writeUint8(uint8(o.selfTradePrevention))
+	enc.writeUint8(uint8(o.status))
+	enc.writeTime(o.expireTime)
+	enc.writeUint64(o.placement)
+	enc.writeTime(o.acceptTime)
+	enc.writeTime(o.updateTime)
+	enc.writeTime(o.activateTime)
+}
+
+////////////////////////////////////////////////////////////////
+// Binary decoder
+////////////////////////////////////////////////////////////////
+
+// decoder reads binary representation of the engine state written by encoder.
+// The first occurred error is sticky and all further reads return zero values.
+type decoder struct {
+	r   *bufio.Reader
+	buf [16]byte
+	err error
+}
+
+func newDecoder(r io.Reader) *decoder {
+	return &decoder{r: bufio.NewReader(r)}
+}
+
+func (dec *decoder) read(b []byte) {
+	if dec.err != nil {
+		clear(b)
+		return
+	}
+	_, dec.err = io.ReadFull(dec.r, b)
+}
+
+func (dec *decoder) readUint8() uint8 {
+	dec.read(dec.buf[:1])
+	return dec.buf[0]
+}
+
+func (dec *decoder) readBool() bool {
+	return dec.readUint8() != 0
+}
+
+func (dec *decoder) readUint32() uint32 {
+	dec.read(dec.buf[:4])
+	return binary.LittleEndian.Uint32(dec.buf[:4])
+}
+
+func (dec *decoder) readUint64() uint64 {
+	dec.read(dec.buf[:8])
+	return binary.LittleEndian.Uint64(dec.buf[:8])
+}
+
+func (dec *decoder) readUint() Uint {
+	dec.read(dec.buf[:16])
+	return NewUintFromUint128(uint128.FromBytes(dec.buf[:16]))
+}
+
+func (dec *decoder) readTime() time.Time {
+	v := dec.readUint64()
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(v))
+}
+
+func (dec *decoder) readString() string {
+	size := dec.readUint32()
+	if dec.err != nil {
+		return ""
+	}
+	b := make([]byte, size)
+	dec.read(b)
+	return string(b)
+}
+
+func (dec *decoder) readLimits() Limits {
+	return Limits{
+		Min:  dec.readUint(),
+		Max:  dec.readUint(),
+		Step: dec.readUint(),
+	}
+}
+
+func (dec *decoder) readSymbol() Symbol {
+	return Symbol{
+		id:                  dec.readUint32(),
+		name:                dec.readString(),
+		priceLimits:         dec.readLimits(),
+		lotSizeLimits:       dec.readLimits(),
+		quoteLotSizeLimits:  dec.readLimits(),
+		selfTradePrevention: SelfTradePrevention(dec.readUint8()),
+		staticPriceBand:     dec.readPriceBand(),
+		dynamicPriceBand:    dec.readPriceBand(),
+	}
+}
+
+func (dec *decoder) readPriceBand() PriceBand {
+	return PriceBand{
+		Reference: PriceBandReference(dec.readUint8()),
+		Kind:      PriceBandKind(dec.readUint8()),
+		Distance:  dec.readUint(),
+	}
+}
+
+// readMatchingPolicy reads the matching policy written by writeMatchingPolicy.
+func (dec *decoder) readMatchingPolicy() MatchingPolicy {
+	switch tag := dec.readUint8(); {
+	case dec.err != nil, tag == 0:
+		return nil
+	case tag == matchingPolicyTagFIFO:
+		return FIFOPolicy{}
+	case tag == matchingPolicyTagProRata:
+		return ProRataPolicy{MinAllocation: dec.readUint()}
+	case tag == matchingPolicyTagHybrid:
+		count := dec.readUint32()
+		if dec.err != nil {
+			return nil
+		}
+		p := HybridPolicy{MarketMakers: make([]uint64, 0, min(count, 1024))}
+		for range count {
+			ownerID := dec.readUint64()
+			if dec.err != nil {
+				return nil
+			}
+			p.MarketMakers = append(p.MarketMakers, ownerID)
+		}
+		p.MarketMakerShare = dec.readUint64()
+		p.FIFOShare = dec.readUint64()
+		p.MinAllocation = dec.readUint()
+		return p
+	default:
+		dec.err = ErrUnsupportedMatchingPolicy
+		return nil
+	}
+}
+
+func (dec *decoder) readMassCancelFilter() MassCancelFilter {
+	return MassCancelFilter{
+		SymbolID:   dec.readUint32(),
+		AllSymbols: dec.readBool(),
+		Side:       OrderSide(dec.readUint8()),
+		StopOnly:   dec.readBool(),
+		MinPrice:   dec.readUint(),
+		MaxPrice:   dec.readUint(),
+		OwnerID:    dec.readUint64(),
+	}
+}
+
+func (dec *decoder) readStopPriceModeConfig() StopPriceModeConfig {
+	return StopPriceModeConfig{
+		Market: dec.readBool(),
+		Mark:   dec.readBool(),
+		Index:  dec.readBool(),
+	}
+}
+
+// readOrder reads all order fields written by writeOrder.
+func (dec *decoder) readOrder(o *Order) {
+	o.id = dec.readUint64()
+	o.ownerID = dec.readUint64()
+	o.symbolID = dec.readUint32()
+	o.orderType = OrderType(dec.readUint8())
+	o.side = OrderSide(dec.readUint8())
+	o.direction = OrderDirection(dec.readUint8())
+	o.timeInForce = OrderTimeInForce(dec.readUint8())
+	o.price = dec.readUint()
+	o.stopPrice = dec.readUint()
+	o.stopPriceMode = StopPriceMode(dec.readUint8())
+	o.takeProfit = dec.readBool()
+	o.quantity = dec.readUint()
+	o.quoteQuantity = dec.readUint()
+	o.maxVisible = dec.readUint()
+	o.visible = dec.readUint()
+	o.visibleVariance = dec.readUint()
+	o.pegReference = PegReference(dec.readUint8())
+	o.pegOffset = int64(dec.readUint64())
+	o.pegCap = dec.readUint()
+	o.minQuantity = dec.readUint()
+	o.allOrNone = dec.readBool()
+	o.marketSlippage = dec.readUint()
+	o.trailingDistance = dec.readUint()
+	o.trailingStep = dec.readUint()
+	o.available = dec.readUint()
+	o.restQuantity = dec.readUint()
+	o.restQuoteQuantity = dec.readUint()
+	o.executedQuantity = dec.readUint()
+	o.executedQuoteQuantity = dec.readUint()
+	o.marketQuoteMode = dec.readBool()
+	o.linkedOrderID = dec.readUint64()
+	o.selfTradePrevention = SelfTradePrevention(dec.readUint8())
+	o.status = OrderStatus(dec.readUint8())
+	o.expireTime = dec.readTime()
+	o.placement = dec.readUint64()
+	o.acceptTime = dec.readTime()
+	o.updateTime = dec.readTime()
+	o.activateTime = dec.readTime()
+}
@@ -1,13 +1,19 @@
 package matching
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Engine is used to manage the market with orders, price levels and order books.
 // Automatic orders matching can be enabled with EnableMatching() method or can be
 // manually performed with Match() method.
 // NOTE: The matching engine is thread safe only when created with multithread flag.
 type Engine struct {
-	handler Handler
+	handler eventHandler
 
 	// Order books
 	orderBooks      []*OrderBook
@@ -18,15 +24,77 @@ type Engine struct {
 
 	// Multi-thread mode
 	multithread bool
+
+	// Clock used to timestamp commands
+	clock Clock
+	time  int64 // time of the last accepted command (unix time in nanoseconds)
+
+	// Commands journal and sequencing
+	journal          *Journal
+	mxCommands       sync.Mutex // held while journaling and enqueueing commands
+	sequence         uint64     // sequence of the last accepted command
+	replayed         *command   // command replayed from the journal, nil if the journal is not replayed
+	restoredSequence uint64
+	mxReplay         sync.Mutex     // held while replaying the journal, so background commands wait for it
+	background       sync.WaitGroup // background commands in progress
+	stopped          bool           // set with locked commands when the engine is stopped
+
+	// Events passed to the handler with numbers of the engine-wide sequence
+	target         Handler         // handler receiving numbered events
+	tradeTarget    TradeHandler    // handler receiving trades, nil if the handler does not implement it
+	sequenceTarget SequenceHandler // handler receiving numbers of events, nil if the handler does not implement it
+	events         eventTurns      // order of passing events in multithread mode
+	eventSequence  uint64          // number of the last event passed to the handler
+
+	// Default options of order books
+	orderBookOpts []OrderBookOption
 }
 
 // NewEngine creates and returns new Engine instance.
-func NewEngine(handler Handler, multithread bool) *Engine {
-	return &Engine{
-		handler:     handler,
-		orderBooks:  make([]*OrderBook, defaultReservedOrderBookSlots),
-		multithread: multithread,
+// Engine-wide settings and default settings of order books can be changed with options.
+func NewEngine(handler Handler, multithread bool, opts ...EngineOption) *Engine {
+	config := newEngineConfig(opts)
+	e := &Engine{
+		orderBooks:    make([]*OrderBook, config.expectedOrderBooks),
+		multithread:   multithread,
+		clock:         systemClock{},
+		target:        handler,
+		orderBookOpts: config.orderBookOpts,
+	}
+	e.tradeTarget, _ = handler.(TradeHandler)
+	e.sequenceTarget, _ = handler.(SequenceHandler)
+	e.handler = eventHandler{emit: e.emitEvent}
+	e.events.cond.L = &e.events.mx
+	e.events.maxPending = config.maxPendingEvents
+	return e
+}
+
+// SetJournal sets the journal all further commands are appended to before they are performed.
+// Commands are not journaled if the journal is nil.
+func (e *Engine) SetJournal(journal *Journal) {
+	e.journal = journal
+}
+
+// SetClock sets the clock used to timestamp commands, the command time is used
+// to expire 'Good-Till-Date' orders and to timestamp orders and handler events.
+// System clock is used by default. The engine time never goes back,
+// so the clock time earlier than the time of the last accepted command is ignored.
+func (e *Engine) SetClock(clock Clock) {
+	e.clock = clock
+}
+
+// Time returns the time of the last accepted command, zero time is returned if there are no commands.
+func (e *Engine) Time() time.Time {
+	now := atomic.LoadInt64(&e.time)
+	if now == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, now)
+}
+
+// Sequence returns the sequence number of the last accepted command.
+func (e *Engine) Sequence() uint64 {
+	return atomic.LoadUint64(&e.sequence)
 }
 
 // Start starts the matching engine.
@@ -36,6 +104,12 @@ func (e *Engine) Start() {}
 // It releases all internally used order books and cleans whole order book state.
 func (e *Engine) Stop(forced bool) {
 
+	// Wait for background commands, no more of them are started
+	e.lockCommands()
+	e.stopped = true
+	e.unlockCommands()
+	e.background.Wait()
+
 	// Close all order book tasks channels
 	for i, c := 0, len(e.orderBooks); i < c; i++ {
 		if e.orderBooks[i] != nil {
@@ -46,6 +120,12 @@ func (e *Engine) Stop(forced bool) {
 		}
 	}
 
+	// Release tasks waiting for turns of their events, events are not passed in case of forced stop
+	if forced {
+		e.events.stop()
+		defer e.events.reset()
+	}
+
 	// Wait until everything is done
 	for i, c := 0, len(e.orderBooks); i < c; i++ {
 		if e.orderBooks[i] != nil {
@@ -98,13 +178,41 @@ func (e *Engine) IsMatchingEnabled() bool {
 
 // EnableMatching enables automatic matching.
 func (e *Engine) EnableMatching() {
-	e.matching = true
-	e.Match()
+	apply := func() {
+		e.matching = true
+	}
+	task := func(ob *OrderBook) error {
+		ob.matching = true
+		return e.matchOrderBook(ob)
+	}
+
+	e.performEngineCommand(&command{kind: CommandKindEnableMatching}, apply, task)
 }
 
 // DisableMatching disables automatic matching.
 func (e *Engine) DisableMatching() {
-	e.matching = false
+	apply := func() {
+		e.matching = false
+	}
+	task := func(ob *OrderBook) error {
+		ob.matching = false
+		return nil
+	}
+
+	e.performEngineCommand(&command{kind: CommandKindDisableMatching}, apply, task)
+}
+
+// ExpireOrders deletes all 'Good-Till-Date' orders expired at the current clock time.
+// Due orders are also expired by each order book before any other command is performed.
+// In multithread mode order books perform the command themselves when the clock reaches
+// the earliest expiration, so the method is needed only in single-thread mode
+// to expire orders of order books without activity.
+func (e *Engine) ExpireOrders() {
+	task := func(ob *OrderBook) error {
+		return nil
+	}
+
+	e.performEngineCommand(&command{kind: CommandKindExpireOrders}, nil, task)
 }
 
 ////////////////////////////////////////////////////////////////
@@ -112,71 +220,122 @@ func (e *Engine) DisableMatching() {
 ////////////////////////////////////////////////////////////////
 
 // AddOrderBook creates new order book and adds it to the engine.
-func (e *Engine) AddOrderBook(symbol Symbol, marketPrice Uint, spModesConfig StopPriceModeConfig) (orderBook *OrderBook, err error) {
+// Options are applied after default options of the engine. Only the matching policy is journaled,
+// so other options should be passed again with WithOrderBookDefaults() to the engine performing the replay.
+func (e *Engine) AddOrderBook(symbol Symbol, marketPrice Uint, spModesConfig StopPriceModeConfig, opts ...OrderBookOption) (orderBook *OrderBook, err error) {
 	if !symbol.Valid() {
 		err = ErrInvalidSymbol
 		return
 	}
 
-	// Ensure order books storage size
-	newSize := len(e.orderBooks)
-	for newSize <= int(symbol.id) {
-		newSize *= 2
-	}
-	if newSize > len(e.orderBooks) {
-		newOrderBooks := make([]*OrderBook, newSize)
-		copy(newOrderBooks, e.orderBooks)
-		e.orderBooks = newOrderBooks
-	}
-
-	// Ensure order book does not exist
-	if e.orderBooks[symbol.id] != nil {
-		err = ErrOrderBookDuplicate
+	config := newOrderBookConfig(e.orderBookOpts, opts)
+	if hybrid, ok := config.policy.(HybridPolicy); ok && !hybrid.Valid() {
+		err = ErrInvalidMatchingPolicy
 		return
 	}
 
+	e.lockCommands()
+	defer e.unlockCommands()
+
 	// Create order book
-	orderBook = NewOrderBook(symbol, spModesConfig, defaultOrderBookTaskQueueSize)
+	orderBook = newOrderBook(symbol, spModesConfig, config)
 	orderBook.marketPrice = marketPrice
-	e.orderBooks[symbol.id] = orderBook
-	e.orderBooksCount++
+	orderBook.anchorStaticPriceBand()
 
-	// Call the corresponding handler
-	e.handler.OnAddOrderBook(orderBook)
+	// Ensure order book does not exist before journaling
+	if e.OrderBook(symbol.id) != nil {
+		orderBook.Clean()
+		orderBook = nil
+		err = ErrOrderBookDuplicate
+		return
+	}
 
-	// Run goroutine unique to the order book to perform order book specific tasks
-	if e.multithread {
-		orderBook.wg.Add(1)
-		go e.loopOrderBook(orderBook)
+	// Secret seed is journaled, so the replayed order book shows the same iceberg slices
+	orderBook.seed = newOrderBookSeed()
+	if e.replayed != nil {
+		orderBook.seed = e.replayed.seed
+	}
+	cmd := &command{
+		kind:          CommandKindAddOrderBook,
+		symbolID:      symbol.id,
+		symbol:        symbol,
+		marketPrice:   marketPrice,
+		spModesConfig: spModesConfig,
+		policy:        config.policy,
+		seed:          orderBook.seed,
+	}
+	err = e.journalCommand(cmd)
+	if err != nil {
+		orderBook.Clean()
+		orderBook = nil
+		return
+	}
+
+	err = e.insertOrderBook(orderBook)
+	if err != nil {
+		orderBook = nil
+		return
 	}
 
+	// Call the corresponding handler in the order book task, so the event is passed in the turn of the command.
+	// The task is the first one of the order book, so it is enqueued without blocking.
+	_ = e.scheduleOrderBookTask(orderBook, func(ob *OrderBook) error {
+		e.handler.OnAddOrderBook(ob)
+		return nil
+	})()
+
 	return
 }
 
 // DeleteOrderBook deletes order book from the engine.
+// Tasks of the order book accepted before are performed before it is deleted.
 func (e *Engine) DeleteOrderBook(id uint32) (orderBook *OrderBook, err error) {
+	e.lockCommands()
 
 	// Ensure order book exists
-	if int(id) >= len(e.orderBooks) || e.orderBooks[id] == nil {
+	orderBook = e.OrderBook(id)
+	if orderBook == nil {
+		e.unlockCommands()
 		err = ErrOrderBookNotFound
 		return
 	}
 
-	orderBook = e.orderBooks[id]
+	cmd := &command{kind: CommandKindDeleteOrderBook, symbolID: id}
+	err = e.journalCommand(cmd)
+	if err != nil {
+		e.unlockCommands()
+		orderBook = nil
+		return
+	}
+
+	// Delete order book, so no more tasks are scheduled for it
+	e.orderBooks[id] = nil
+	e.orderBooksCount--
+	ticket := orderBook.enqueueTurns.take()
+	eventTicket := e.takeEventTurn()
+	e.unlockCommands()
 
-	// Close order book tasks channel
+	// Close order book tasks channel after all scheduled tasks are enqueued
+	orderBook.enqueueTurns.wait(ticket)
 	close(orderBook.chanTasks)
+	orderBook.enqueueTurns.done(ticket)
 
 	// Wait until all order book tasks are performed
 	orderBook.wg.Wait()
 
-	// Call the corresponding handler
+	// Call the corresponding handler after events of all previous commands,
+	// wait for the turn, so the order book is cleaned after the handler
 	e.handler.OnDeleteOrderBook(orderBook)
+	e.inEventTurn(eventTicket, func() {
+		for i := range orderBook.events {
+			e.passEvent(&orderBook.events[i])
+		}
+	})
+	clear(orderBook.events)
+	orderBook.events = orderBook.events[:0]
 
-	// Clean and delete order book
+	// Clean order book
 	orderBook.Clean()
-	e.orderBooks[id] = nil
-	e.orderBooksCount--
 
 	return
 }
@@ -204,14 +363,22 @@ func (e *Engine) SetIndexMarkPricesForOrderBook(symbolID uint32, indexPrice Uint
 		ob.setIndexPrice(indexPrice)
 		ob.setMarkPrice(markPrice)
 
-		if e.matching || iterate {
+		if ob.state.canMatch() && (ob.matching || iterate) {
 			e.match(ob)
 		}
 
 		return nil
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:       CommandKindSetIndexMarkPrices,
+		symbolID:   symbolID,
+		indexPrice: indexPrice,
+		markPrice:  markPrice,
+		iterate:    iterate,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // SetMarkPrice sets the mark price for order book,
@@ -225,14 +392,21 @@ func (e *Engine) SetMarkPriceForOrderBook(symbolID uint32, price Uint, iterate b
 	task := func(ob *OrderBook) error {
 		ob.setMarkPrice(price)
 
-		if e.matching || iterate {
+		if ob.state.canMatch() && (ob.matching || iterate) {
 			e.match(ob)
 		}
 
 		return nil
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:      CommandKindSetMarkPrice,
+		symbolID:  symbolID,
+		markPrice: price,
+		iterate:   iterate,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // SetIndexPriceForOrderBook sets the index price for order book,
@@ -246,14 +420,198 @@ func (e *Engine) SetIndexPriceForOrderBook(symbolID uint32, price Uint, iterate
 	task := func(ob *OrderBook) error {
 		ob.setIndexPrice(price)
 
-		if e.matching || iterate {
+		if ob.state.canMatch() && (ob.matching || iterate) {
 			e.match(ob)
 		}
 
 		return nil
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:       CommandKindSetIndexPrice,
+		symbolID:   symbolID,
+		indexPrice: price,
+		iterate:    iterate,
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+////////////////////////////////////////////////////////////////
+// Trading state
+////////////////////////////////////////////////////////////////
+
+// SetTradingState changes the trading state of order book (see TradingState for allowed operations).
+// Leaving the auction state to continuous trading executes crossed orders at the auction clearing price.
+func (e *Engine) SetTradingState(symbolID uint32, state TradingState) error {
+	ob := e.OrderBook(symbolID)
+	if ob == nil {
+		return ErrOrderBookNotFound
+	}
+
+	if !state.Valid() {
+		return ErrInvalidTradingState
+	}
+
+	task := func(ob *OrderBook) error {
+		return e.setTradingState(ob, state)
+	}
+
+	cmd := &command{
+		kind:         CommandKindSetTradingState,
+		symbolID:     symbolID,
+		tradingState: state,
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+// StartAuction starts the auction phase for order book.
+// During the auction orders are accumulated in the order book without matching.
+func (e *Engine) StartAuction(symbolID uint32) error {
+	ob := e.OrderBook(symbolID)
+	if ob == nil {
+		return ErrOrderBookNotFound
+	}
+
+	task := func(ob *OrderBook) error {
+		if ob.state == TradingStateAuction {
+			return validationError(ErrAuctionStarted)
+		}
+
+		return e.setTradingState(ob, TradingStateAuction)
+	}
+
+	cmd := &command{
+		kind:     CommandKindStartAuction,
+		symbolID: symbolID,
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+// StopAuction ends the auction phase for order book.
+// All crossed orders are executed at the single clearing price (see OrderBook.AuctionPrice()),
+// then the order book continues trading with automatic matching if it is enabled.
+func (e *Engine) StopAuction(symbolID uint32) error {
+	ob := e.OrderBook(symbolID)
+	if ob == nil {
+		return ErrOrderBookNotFound
+	}
+
+	task := func(ob *OrderBook) error {
+		if ob.state != TradingStateAuction {
+			return validationError(ErrAuctionNotStarted)
+		}
+
+		return e.setTradingState(ob, TradingStateTrading)
+	}
+
+	cmd := &command{
+		kind:     CommandKindStopAuction,
+		symbolID: symbolID,
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+// SetMatchingPolicy sets the policy allocating the taker quantity between orders of the single price level.
+// Nil policy restores the default price-time FIFO matching.
+// NOTE: Only built-in policies can be stored in the journal and in the snapshot.
+func (e *Engine) SetMatchingPolicy(symbolID uint32, policy MatchingPolicy) error {
+	ob := e.OrderBook(symbolID)
+	if ob == nil {
+		return ErrOrderBookNotFound
+	}
+
+	if hybrid, ok := policy.(HybridPolicy); ok && !hybrid.Valid() {
+		return ErrInvalidMatchingPolicy
+	}
+
+	task := func(ob *OrderBook) error {
+		ob.policy = policy
+		return nil
+	}
+
+	cmd := &command{
+		kind:     CommandKindSetMatchingPolicy,
+		symbolID: symbolID,
+		policy:   policy,
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+// UpdateSymbol updates the symbol of the order book with the same symbol ID.
+// Price limits, lot size limits, the self-trade prevention mode and price bands are changed
+// for all further orders. The static price band is anchored to the current reference price
+// unless the order book is out of the continuous trading, it is anchored when the trading is started then.
+func (e *Engine) UpdateSymbol(symbol Symbol) error {
+	ob := e.OrderBook(symbol.id)
+	if ob == nil {
+		return ErrOrderBookNotFound
+	}
+
+	if !symbol.Valid() {
+		return ErrInvalidSymbol
+	}
+
+	task := func(ob *OrderBook) error {
+		ob.symbol = symbol
+
+		// Anchor the static price band during the continuous trading
+		if ob.state == TradingStateTrading {
+			ob.anchorStaticPriceBand()
+		}
+
+		// Call the corresponding handler
+		e.handler.OnUpdateOrderBook(ob)
+
+		return nil
+	}
+
+	cmd := &command{
+		kind:     CommandKindUpdateSymbol,
+		symbolID: symbol.id,
+		symbol:   symbol,
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+// setTradingState changes the trading state of order book.
+func (e *Engine) setTradingState(ob *OrderBook, state TradingState) error {
+	if ob.state == state {
+		return nil
+	}
+
+	// Uncross the order book at the end of the auction
+	if ob.state == TradingStateAuction && state == TradingStateTrading {
+		err := e.uncross(ob)
+		if err != nil {
+			return fmt.Errorf("failed to uncross: %w", err)
+		}
+	}
+
+	ob.state = state
+
+	// Anchor the static price band when the continuous trading is started
+	if state == TradingStateTrading {
+		ob.anchorStaticPriceBand()
+	}
+
+	// Call the corresponding handler
+	e.handler.OnUpdateOrderBook(ob)
+
+	// Automatic order matching
+	if ob.isMatching() {
+		err := e.match(ob)
+		if err != nil {
+			return fmt.Errorf("failed to match: %w", err)
+		}
+	}
+
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////
@@ -262,67 +620,146 @@ func (e *Engine) SetIndexPriceForOrderBook(symbolID uint32, price Uint, iterate
 
 // AddOrder adds new order to the engine.
 func (e *Engine) AddOrder(order Order) error {
+	ob, cmd, task, err := e.addOrderCommand(order)
+	if err != nil {
+		return err
+	}
+
+	return e.performCommand(ob, cmd, task)
+}
+
+// TryAddOrder adds new order to the engine without blocking.
+// ErrQueueFull is returned if the task queue of the order book is full, the order is not added in this case.
+// NOTE: Only new orders can be shed this way, other order commands (including cancellations)
+// always wait for the space in the queue, so they are never dropped because of the load.
+func (e *Engine) TryAddOrder(order Order) error {
+	ob, cmd, task, err := e.addOrderCommand(order)
+	if err != nil {
+		return err
+	}
+
+	enqueued, err := e.tryPerformCommand(ob, cmd, task)
+	if err == nil && !enqueued {
+		return ErrQueueFull
+	}
+
+	return err
+}
+
+// AddOrderContext adds new order to the engine waiting for the space in the task queue
+// of the order book until the context is done. Callers of other order books are not blocked
+// while waiting. The context error is returned if the order is not added because of the context.
+// NOTE: Other order commands have no context variants, see TryAddOrder().
+func (e *Engine) AddOrderContext(ctx context.Context, order Order) error {
+	ob, cmd, task, err := e.addOrderCommand(order)
+	if err != nil {
+		return err
+	}
+
+	return e.performCommandContext(ctx, ob, cmd, task)
+}
+
+// AddOrderAsync adds new order to the engine and returns the acknowledgement of the command.
+// The acknowledgement is done when the order is added (or rejected) by its order book,
+// so in multithread mode the result of the order can be linked to the request.
+func (e *Engine) AddOrderAsync(order Order) *Ack {
+	ob, cmd, task, err := e.addOrderCommand(order)
+	if err != nil {
+		return newRejectedAck(cmd.sequence, err)
+	}
+
+	return e.performCommandAsync(ob, cmd, task)
+}
+
+// AddOrderWait adds new order to the engine and waits until it is added by its order book.
+// Returns trades of the order or the error the order is rejected with.
+// The context error is returned if the context is done before, the order is still added in this case.
+func (e *Engine) AddOrderWait(ctx context.Context, order Order) ([]Trade, error) {
+	ack := e.AddOrderAsync(order)
+	if err := ack.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return ack.Trades(), nil
+}
+
+// addOrderCommand validates the order and prepares the command adding it to its order book.
+// The command of the invalid order is journaled and the order is rejected without performing it,
+// the rejected command is returned with the error then.
+func (e *Engine) addOrderCommand(order Order) (ob *OrderBook, cmd *command, task func(ob *OrderBook) error, err error) {
+	defer func() {
+		if err != nil {
+			cmd = &command{kind: CommandKindAddOrder, symbolID: order.symbolID, order: order}
+			err = e.rejectCommand(ob, cmd, err, order)
+		}
+	}()
+
 	// Get the valid order book for the order
-	ob := e.OrderBook(order.symbolID)
+	ob = e.OrderBook(order.symbolID)
 	if ob == nil {
-		return ErrOrderBookNotFound
+		return nil, nil, nil, ErrOrderBookNotFound
 	}
 
 	// Change market slippage before validation
-	if order.Type() == OrderTypeMarket || order.Type() == OrderTypeStop || order.Type() == OrderTypeTrailingStop {
+	if order.Type() == OrderTypeMarket || order.Type() == OrderTypeMarketToLimit ||
+		order.Type() == OrderTypeStop || order.Type() == OrderTypeTrailingStop {
 		order.marketSlippage = Min(order.marketSlippage, ob.symbol.priceLimits.Max)
 	}
 
 	// Validate order parameters
 	if err := order.Validate(ob); err != nil {
-		return err
+		return ob, nil, nil, err
 	}
 
 	// Validate order parameters
 	if err := order.CheckLocked(); err != nil {
-		return err
+		return ob, nil, nil, err
 	}
 
-	task := func(ob *OrderBook) error {
+	task = e.rejectingTask(func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
+
+		// Reject GTD order expired before it is added
+		if order.isExpired(ob.now) {
+			return validationError(ErrOrderExpired)
+		}
+
 		// Add the corresponding order type
 		switch order.orderType {
 		case OrderTypeLimit:
 			return e.addLimitOrder(ob, order, false)
 		case OrderTypeMarket:
 			return e.addMarketOrder(ob, order, false)
+		case OrderTypeMarketToLimit:
+			return e.addMarketToLimitOrder(ob, order, false)
 		case OrderTypeStop, OrderTypeTrailingStop:
 			return e.addStopOrder(ob, order, false)
 		case OrderTypeStopLimit, OrderTypeTrailingStopLimit:
 			return e.addStopLimitOrder(ob, order, false)
 		default:
-			return ErrInvalidOrderType
+			return validationError(ErrInvalidOrderType)
 		}
+	}, order)
+
+	cmd = &command{
+		kind:     CommandKindAddOrder,
+		symbolID: order.symbolID,
+		order:    order,
 	}
 
-	return e.performOrderBookTask(ob, task)
+	return ob, cmd, task, nil
 }
 
 // AddOrdersPair adds new orders pair (OCO orders) to the engine.
 // First order should be stop-limit order and second one should be limit order.
 // NOTE: lock all amount in limit order.
 func (e *Engine) AddOrdersPair(stopLimitOrder Order, limitOrder Order) error {
-
-	// Get the valid order book for the order
-	ob := e.OrderBook(stopLimitOrder.symbolID)
-	if ob == nil {
-		return ErrOrderBookNotFound
-	}
-
-	// Validate orders parameters
-	if err := stopLimitOrder.Validate(ob); err != nil {
-		return err
-	}
-	if err := limitOrder.Validate(ob); err != nil {
-		return err
-	}
-
-	// Check locked
-	if err := CheckLockedOCO(&stopLimitOrder, &limitOrder); err != nil {
+	// Get the valid order book for orders and validate them
+	ob, err := e.validateOrdersPair(CommandKindAddOrdersPair, &stopLimitOrder, &limitOrder, CheckLockedOCO)
+	if err != nil {
 		return err
 	}
 
@@ -330,22 +767,36 @@ func (e *Engine) AddOrdersPair(stopLimitOrder Order, limitOrder Order) error {
 	stopLimitOrder.linkedOrderID = limitOrder.id
 	limitOrder.linkedOrderID = stopLimitOrder.id
 
-	task := func(ob *OrderBook) error {
+	task := e.rejectingTask(func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
+
+		// Check duplicates before any of orders is added
+		if err := ob.checkOrdersPairDuplicate(&stopLimitOrder, &limitOrder); err != nil {
+			return validationError(err)
+		}
+
+		// Reject GTD orders expired before they are added
+		if stopLimitOrder.isExpired(ob.now) || limitOrder.isExpired(ob.now) {
+			return validationError(ErrOrderExpired)
+		}
 
 		// Check market price
 		if stopLimitOrder.IsBuy() {
 			if stopLimitOrder.stopPrice.LessThan(ob.GetMarketPrice()) {
-				return ErrBuyOCOStopPriceLessThanMarketPrice
+				return validationError(ErrBuyOCOStopPriceLessThanMarketPrice)
 			}
 			if limitOrder.price.GreaterThan(ob.GetMarketPrice()) {
-				return ErrBuyOCOLimitPriceGreaterThanMarketPrice
+				return validationError(ErrBuyOCOLimitPriceGreaterThanMarketPrice)
 			}
 		} else {
 			if stopLimitOrder.stopPrice.GreaterThan(ob.GetMarketPrice()) {
-				return ErrSellOCOStopPriceGreaterThanMarketPrice
+				return validationError(ErrSellOCOStopPriceGreaterThanMarketPrice)
 			}
 			if limitOrder.price.LessThan(ob.GetMarketPrice()) {
-				return ErrSellOCOLimitPriceLessThanMarketPrice
+				return validationError(ErrSellOCOLimitPriceLessThanMarketPrice)
 			}
 		}
 
@@ -360,8 +811,9 @@ func (e *Engine) AddOrdersPair(stopLimitOrder Order, limitOrder Order) error {
 		// Check if limit order has been executed
 		if limitOrderFromOB == nil || limitOrderFromOB.PartiallyExecuted() {
 			// Imitation of order placing and cancellation
-			e.handler.OnAddOrder(ob, &stopLimitOrder)
-			e.handler.OnDeleteOrder(ob, &stopLimitOrder)
+			stopLimitOrder.status = OrderStatusNew
+			e.handleAddOrder(ob, &stopLimitOrder)
+			e.handleDeleteOrder(ob, &stopLimitOrder, OrderReasonOCOSibling)
 		} else {
 			// Add stop-limit order
 			err = e.addStopLimitOrder(ob, stopLimitOrder, false)
@@ -381,7 +833,7 @@ func (e *Engine) AddOrdersPair(stopLimitOrder Order, limitOrder Order) error {
 				}
 
 				// Cancel limit order
-				err := e.deleteOrder(ob, limitOrderFromOB, false)
+				err := e.deleteOrder(ob, limitOrderFromOB, OrderReasonOCOSibling, false)
 				if err != nil {
 					return fmt.Errorf("failed to delete order (id: %d): %w", limitOrderFromOB.ID(), err)
 				}
@@ -389,9 +841,16 @@ func (e *Engine) AddOrdersPair(stopLimitOrder Order, limitOrder Order) error {
 		}
 
 		return nil
+	}, stopLimitOrder, limitOrder)
+
+	cmd := &command{
+		kind:        CommandKindAddOrdersPair,
+		symbolID:    stopLimitOrder.symbolID,
+		order:       stopLimitOrder,
+		linkedOrder: limitOrder,
 	}
 
-	return e.performOrderBookTask(ob, task)
+	return e.performCommand(ob, cmd, task)
 }
 
 // AddTPSL adds new orders pair take-profit and stop-loss (OCO orders) to the engine.
@@ -399,22 +858,9 @@ func (e *Engine) AddOrdersPair(stopLimitOrder Order, limitOrder Order) error {
 // Based on stop-limit type.
 // NOTE: lock all amount in take-profit order.
 func (e *Engine) AddTPSL(tp Order, sl Order) error {
-	// Get the valid order book for the order
-	ob := e.OrderBook(tp.symbolID)
-	if ob == nil {
-		return ErrOrderBookNotFound
-	}
-
-	// Validate orders parameters
-	if err := tp.Validate(ob); err != nil {
-		return err
-	}
-	if err := sl.Validate(ob); err != nil {
-		return err
-	}
-
-	// Check locked
-	if err := CheckLockedTPSL(&tp, &sl); err != nil {
+	// Get the valid order book for orders and validate them
+	ob, err := e.validateOrdersPair(CommandKindAddTPSL, &tp, &sl, CheckLockedTPSL)
+	if err != nil {
 		return err
 	}
 
@@ -422,23 +868,38 @@ func (e *Engine) AddTPSL(tp Order, sl Order) error {
 	tp.linkedOrderID = sl.id
 	sl.linkedOrderID = tp.id
 
-	task := func(ob *OrderBook) error {
+	task := e.rejectingTask(func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
+
+		// Check duplicates before any of orders is added
+		if err := ob.checkOrdersPairDuplicate(&tp, &sl); err != nil {
+			return validationError(err)
+		}
+
+		// Reject GTD orders expired before they are added
+		if tp.isExpired(ob.now) || sl.isExpired(ob.now) {
+			return validationError(ErrOrderExpired)
+		}
+
 		engineStopPrice := ob.GetStopPrice(tp.StopPriceMode())
 
 		// Check engine price
 		if tp.IsBuy() {
 			if sl.stopPrice.LessThan(engineStopPrice) {
-				return ErrBuySLStopPriceLessThanEnginePrice
+				return validationError(ErrBuySLStopPriceLessThanEnginePrice)
 			}
 			if tp.stopPrice.GreaterThan(engineStopPrice) {
-				return ErrBuyTPStopPriceGreaterThanEnginePrice
+				return validationError(ErrBuyTPStopPriceGreaterThanEnginePrice)
 			}
 		} else {
 			if sl.stopPrice.GreaterThan(engineStopPrice) {
-				return ErrSellSLStopPriceGreaterThanEnginePrice
+				return validationError(ErrSellSLStopPriceGreaterThanEnginePrice)
 			}
 			if tp.stopPrice.LessThan(engineStopPrice) {
-				return ErrSellTPStopPriceLessThanEnginePrice
+				return validationError(ErrSellTPStopPriceLessThanEnginePrice)
 			}
 		}
 
@@ -452,8 +913,9 @@ func (e *Engine) AddTPSL(tp Order, sl Order) error {
 		// Check if tp order has been executed or activated
 		if tpFromOB == nil || tpFromOB.Activated() {
 			// Imitation of order placing and cancellation of sl linked order
-			e.handler.OnAddOrder(ob, &sl)
-			e.handler.OnDeleteOrder(ob, &sl)
+			sl.status = OrderStatusNew
+			e.handleAddOrder(ob, &sl)
+			e.handleDeleteOrder(ob, &sl, OrderReasonOCOSibling)
 		} else {
 			// Add sl order
 			err = e.addStopLimitOrder(ob, sl, false)
@@ -473,7 +935,7 @@ func (e *Engine) AddTPSL(tp Order, sl Order) error {
 				}
 
 				// Cancel tp linked order
-				err := e.deleteOrder(ob, tpFromOB, false)
+				err := e.deleteOrder(ob, tpFromOB, OrderReasonOCOSibling, false)
 				if err != nil {
 					return fmt.Errorf("failed to delete order (id: %d): %w", tpFromOB.ID(), err)
 				}
@@ -481,9 +943,16 @@ func (e *Engine) AddTPSL(tp Order, sl Order) error {
 		}
 
 		return nil
+	}, tp, sl)
+
+	cmd := &command{
+		kind:        CommandKindAddTPSL,
+		symbolID:    tp.symbolID,
+		order:       tp,
+		linkedOrder: sl,
 	}
 
-	return e.performOrderBookTask(ob, task)
+	return e.performCommand(ob, cmd, task)
 }
 
 // AddTPSLMarket adds new orders pair take-profit and stop-limit (OCO orders) to the engine.
@@ -491,22 +960,9 @@ func (e *Engine) AddTPSL(tp Order, sl Order) error {
 // Based on stop type.
 // NOTE: lock all amount in take-profit order.
 func (e *Engine) AddTPSLMarket(tp Order, sl Order) error {
-	// Get the valid order book for the order
-	ob := e.OrderBook(tp.symbolID)
-	if ob == nil {
-		return ErrOrderBookNotFound
-	}
-
-	// Validate orders parameters
-	if err := tp.Validate(ob); err != nil {
-		return err
-	}
-	if err := sl.Validate(ob); err != nil {
-		return err
-	}
-
-	// Check locked
-	if err := CheckLockedTPSLMarket(&tp, &sl); err != nil {
+	// Get the valid order book for orders and validate them
+	ob, err := e.validateOrdersPair(CommandKindAddTPSLMarket, &tp, &sl, CheckLockedTPSLMarket)
+	if err != nil {
 		return err
 	}
 
@@ -514,23 +970,38 @@ func (e *Engine) AddTPSLMarket(tp Order, sl Order) error {
 	tp.linkedOrderID = sl.id
 	sl.linkedOrderID = tp.id
 
-	task := func(ob *OrderBook) error {
+	task := e.rejectingTask(func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
+
+		// Check duplicates before any of orders is added
+		if err := ob.checkOrdersPairDuplicate(&tp, &sl); err != nil {
+			return validationError(err)
+		}
+
+		// Reject GTD orders expired before they are added
+		if tp.isExpired(ob.now) || sl.isExpired(ob.now) {
+			return validationError(ErrOrderExpired)
+		}
+
 		engineStopPrice := ob.GetStopPrice(tp.StopPriceMode())
 
 		// Check engine price
 		if tp.IsBuy() {
 			if sl.stopPrice.LessThan(engineStopPrice) {
-				return ErrBuySLStopPriceLessThanEnginePrice
+				return validationError(ErrBuySLStopPriceLessThanEnginePrice)
 			}
 			if tp.stopPrice.GreaterThan(engineStopPrice) {
-				return ErrBuyTPStopPriceGreaterThanEnginePrice
+				return validationError(ErrBuyTPStopPriceGreaterThanEnginePrice)
 			}
 		} else {
 			if sl.stopPrice.GreaterThan(engineStopPrice) {
-				return ErrSellSLStopPriceGreaterThanEnginePrice
+				return validationError(ErrSellSLStopPriceGreaterThanEnginePrice)
 			}
 			if tp.stopPrice.LessThan(engineStopPrice) {
-				return ErrSellTPStopPriceLessThanEnginePrice
+				return validationError(ErrSellTPStopPriceLessThanEnginePrice)
 			}
 		}
 
@@ -544,8 +1015,9 @@ func (e *Engine) AddTPSLMarket(tp Order, sl Order) error {
 		// Check if tp order has been executed or activated
 		if tpFromOB == nil || tpFromOB.Activated() {
 			// Imitation of order placing and cancellation of sl linked order
-			e.handler.OnAddOrder(ob, &sl)
-			e.handler.OnDeleteOrder(ob, &sl)
+			sl.status = OrderStatusNew
+			e.handleAddOrder(ob, &sl)
+			e.handleDeleteOrder(ob, &sl, OrderReasonOCOSibling)
 		} else {
 			// Add sl order
 			err = e.addStopOrder(ob, sl, false)
@@ -565,7 +1037,7 @@ func (e *Engine) AddTPSLMarket(tp Order, sl Order) error {
 				}
 
 				// Cancel tp linked order
-				err := e.deleteOrder(ob, tpFromOB, false)
+				err := e.deleteOrder(ob, tpFromOB, OrderReasonOCOSibling, false)
 				if err != nil {
 					return fmt.Errorf("failed to delete order (id: %d): %w", tpFromOB.ID(), err)
 				}
@@ -573,9 +1045,89 @@ func (e *Engine) AddTPSLMarket(tp Order, sl Order) error {
 		}
 
 		return nil
+	}, tp, sl)
+
+	cmd := &command{
+		kind:        CommandKindAddTPSLMarket,
+		symbolID:    tp.symbolID,
+		order:       tp,
+		linkedOrder: sl,
 	}
 
-	return e.performOrderBookTask(ob, task)
+	return e.performCommand(ob, cmd, task)
+}
+
+// validateOrdersPair returns the valid order book for orders of the OCO pair and validates them.
+// Both orders are rejected with the journaled command of the given kind if any of them is invalid.
+func (e *Engine) validateOrdersPair(kind CommandKind, first *Order, second *Order, checkLocked func(*Order, *Order) error) (ob *OrderBook, err error) {
+	defer func() {
+		if err != nil {
+			cmd := &command{kind: kind, symbolID: first.symbolID, order: *first, linkedOrder: *second}
+			err = e.rejectCommand(ob, cmd, err, *first, *second)
+		}
+	}()
+
+	// Get the valid order book for the order
+	ob = e.OrderBook(first.symbolID)
+	if ob == nil {
+		return nil, ErrOrderBookNotFound
+	}
+
+	// Validate orders parameters
+	if err := first.Validate(ob); err != nil {
+		return ob, err
+	}
+	if err := second.Validate(ob); err != nil {
+		return ob, err
+	}
+
+	// Check locked
+	return ob, checkLocked(first, second)
+}
+
+// rejectingTask wraps the task adding new orders, so the handler is notified about
+// orders rejected by the task before they change the order book.
+func (e *Engine) rejectingTask(task func(ob *OrderBook) error, orders ...Order) func(ob *OrderBook) error {
+	return func(ob *OrderBook) error {
+		err := task(ob)
+		if err != nil && errorPhase(err) == ErrorPhaseValidate {
+			rejectOrders(e.handler, ob, ob.now, err, orders...)
+		}
+		return err
+	}
+}
+
+// rejectCommand appends the command with orders rejected by the validation to the journal
+// and calls the reject handler for each of them, so replayed commands reject them again.
+// The order book is nil if it is not found. Returns the error orders are rejected with
+// or the error of the journal, orders are not rejected in the latter case.
+func (e *Engine) rejectCommand(ob *OrderBook, cmd *command, err error, orders ...Order) error {
+	e.lockCommands()
+	if ob != nil && !e.hasOrderBook(ob) {
+		e.unlockCommands()
+		return ErrOrderBookNotFound
+	}
+	if journalErr := e.journalCommand(cmd); journalErr != nil {
+		e.unlockCommands()
+		return journalErr
+	}
+	emit := e.scheduleEvents(func(handler Handler) {
+		rejectOrders(handler, ob, time.Unix(0, cmd.time), err, orders...)
+	})
+	e.unlockCommands()
+
+	emit()
+
+	return err
+}
+
+// rejectOrders calls the reject handler for each of orders rejected with the error at the given time.
+func rejectOrders(handler Handler, ob *OrderBook, now time.Time, err error, orders ...Order) {
+	for _, order := range orders {
+		order.status = OrderStatusRejected
+		order.updateTime = now
+		handler.OnRejectOrder(ob, &order, err)
+	}
 }
 
 // ReduceOrder reduces the order by the given quantity.
@@ -587,18 +1139,29 @@ func (e *Engine) ReduceOrder(symbolID uint32, orderID uint64, quantity Uint) err
 	}
 
 	task := func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canReduceOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
 
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
 		}
 
 		// Reduce the order
-		return e.reduceOrder(ob, order, quantity, false)
+		return e.reduceOrder(ob, order, quantity, OrderReasonUserCancel, false)
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:     CommandKindReduceOrder,
+		symbolID: symbolID,
+		orderID:  orderID,
+		quantity: quantity,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // ModifyOrder modifies the order with the given new price and quantity.
@@ -611,18 +1174,35 @@ func (e *Engine) ModifyOrder(symbolID uint32, orderID uint64, newPrice Uint, new
 	}
 
 	task := func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
 
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
+		}
+
+		// Reject limit order price outside of price bands
+		if order.IsLimit() && !ob.priceInBands(newPrice) {
+			return validationError(ErrOrderPriceOutOfBand)
 		}
 
 		// Modify the order
 		return e.modifyOrder(ob, order, newPrice, newQuantity, NewZeroUint(), false, false)
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:     CommandKindModifyOrder,
+		symbolID: symbolID,
+		orderID:  orderID,
+		price:    newPrice,
+		quantity: newQuantity,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // MitigateOrder mitigates the order with the given new price and quantity.
@@ -635,18 +1215,36 @@ func (e *Engine) MitigateOrder(symbolID uint32, orderID uint64, newPrice Uint, n
 	}
 
 	task := func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
 
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
+		}
+
+		// Reject limit order price outside of price bands
+		if order.IsLimit() && !ob.priceInBands(newPrice) {
+			return validationError(ErrOrderPriceOutOfBand)
 		}
 
 		// Mitigate the order
 		return e.modifyOrder(ob, order, newPrice, newQuantity, additionalAmountToLock, true, false)
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:     CommandKindMitigateOrder,
+		symbolID: symbolID,
+		orderID:  orderID,
+		price:    newPrice,
+		quantity: newQuantity,
+		amount:   additionalAmountToLock,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // ReplaceOrder replaces the order with a new one.
@@ -659,22 +1257,40 @@ func (e *Engine) ReplaceOrder(symbolID uint32, orderID uint64, newID uint64, new
 	}
 
 	task := func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canAddOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
 
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
 		}
 		if !order.IsLimit() {
 			// Only limit orders can be replaced
-			return ErrInvalidOrderType
+			return validationError(ErrInvalidOrderType)
+		}
+
+		// Reject the new price outside of price bands
+		if !ob.priceInBands(newPrice) {
+			return validationError(ErrOrderPriceOutOfBand)
 		}
 
 		// Replace the order with new one
 		return e.replaceOrder(ob, order, newID, newPrice, newQuantity, false)
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:       CommandKindReplaceOrder,
+		symbolID:   symbolID,
+		orderID:    orderID,
+		newOrderID: newID,
+		price:      newPrice,
+		quantity:   newQuantity,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // DeleteOrder deletes the order from the engine.
@@ -687,11 +1303,15 @@ func (e *Engine) DeleteOrder(symbolID uint32, orderID uint64) error {
 	}
 
 	task := func(ob *OrderBook) error {
+		// Check the order book trading state
+		if !ob.state.canDeleteOrders() {
+			return validationError(ErrForbiddenTradingState)
+		}
 
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
 		}
 
 		// Delete linked order if it exists
@@ -701,10 +1321,16 @@ func (e *Engine) DeleteOrder(symbolID uint32, orderID uint64) error {
 		}
 
 		// Delete the order
-		return e.deleteOrder(ob, order, false)
+		return e.deleteOrder(ob, order, OrderReasonUserCancel, false)
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:     CommandKindDeleteOrder,
+		symbolID: symbolID,
+		orderID:  orderID,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // ExecuteOrder executes the order by the given quantity.
@@ -720,10 +1346,15 @@ func (e *Engine) ExecuteOrder(symbolID uint32, orderID uint64, quantity Uint) er
 	}
 
 	task := func(ob *OrderBook) (err error) {
+		// Check the order book trading state
+		if !ob.state.canMatch() {
+			return validationError(ErrForbiddenTradingState)
+		}
+
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
 		}
 
 		// Calculate the minimal possible order quantity to execute
@@ -749,9 +1380,11 @@ func (e *Engine) ExecuteOrder(symbolID uint32, orderID uint64, quantity Uint) er
 		// Increase the order executed quantity1
 		order.executedQuantity = order.executedQuantity.Add(quantity)
 		order.executedQuoteQuantity = order.executedQuoteQuantity.Add(quoteQuantity)
+		order.status = OrderStatusPartiallyFilled
 
 		// Reduce the order leaves quantity
 		order.restQuantity = orderQuantity.Sub(quantity)
+		order.executeVisible(quantity)
 
 		visible = visible.Sub(order.VisibleQuantity())
 
@@ -768,12 +1401,17 @@ func (e *Engine) ExecuteOrder(symbolID uint32, orderID uint64, quantity Uint) er
 		if !order.IsExecuted() {
 
 			// Call the corresponding handler
-			e.handler.OnUpdateOrder(ob, order)
+			e.handleUpdateOrder(ob, order)
+
+			// Refresh the exhausted visible slice of the iceberg order
+			if err := e.refreshIceberg(ob, order); err != nil {
+				return err
+			}
 
 		} else {
 
 			// Call the corresponding handler
-			e.handler.OnDeleteOrder(ob, order)
+			e.handleDeleteOrder(ob, order, OrderReasonNone)
 
 			// Erase the order
 			ob.orders.Delete(order.id)
@@ -783,7 +1421,7 @@ func (e *Engine) ExecuteOrder(symbolID uint32, orderID uint64, quantity Uint) er
 		}
 
 		// Automatic order matching
-		if e.matching {
+		if ob.isMatching() {
 			err := e.match(ob)
 			if err != nil {
 				return fmt.Errorf("failed to match: %w", err)
@@ -793,7 +1431,14 @@ func (e *Engine) ExecuteOrder(symbolID uint32, orderID uint64, quantity Uint) er
 		return
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:     CommandKindExecuteOrder,
+		symbolID: symbolID,
+		orderID:  orderID,
+		quantity: quantity,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 // ExecuteOrderByPrice executes the order by the given price and quantity.
@@ -809,11 +1454,15 @@ func (e *Engine) ExecuteOrderByPrice(symbolID uint32, orderID uint64, price Uint
 	}
 
 	task := func(ob *OrderBook) (err error) {
+		// Check the order book trading state
+		if !ob.state.canMatch() {
+			return validationError(ErrForbiddenTradingState)
+		}
 
 		// Get the order by given id
 		order := ob.Order(orderID)
 		if order == nil {
-			return ErrOrderNotFound
+			return validationError(ErrOrderNotFound)
 		}
 
 		// Calculate the minimal possible order quantity to execute
@@ -839,9 +1488,11 @@ func (e *Engine) ExecuteOrderByPrice(symbolID uint32, orderID uint64, price Uint
 		// Increase the order executed quantity1
 		order.executedQuantity = order.executedQuantity.Add(quantity)
 		order.executedQuoteQuantity = order.executedQuoteQuantity.Add(quoteQuantity)
+		order.status = OrderStatusPartiallyFilled
 
 		// Reduce the order leaves quantity
 		order.restQuantity = orderQuantity.Sub(quantity)
+		order.executeVisible(quantity)
 
 		visible = visible.Sub(order.VisibleQuantity())
 
@@ -858,12 +1509,17 @@ func (e *Engine) ExecuteOrderByPrice(symbolID uint32, orderID uint64, price Uint
 		if !order.IsExecuted() {
 
 			// Call the corresponding handler
-			e.handler.OnUpdateOrder(ob, order)
+			e.handleUpdateOrder(ob, order)
+
+			// Refresh the exhausted visible slice of the iceberg order
+			if err := e.refreshIceberg(ob, order); err != nil {
+				return err
+			}
 
 		} else {
 
 			// Call the corresponding handler
-			e.handler.OnDeleteOrder(ob, order)
+			e.handleDeleteOrder(ob, order, OrderReasonNone)
 
 			// Erase the order
 			ob.orders.Delete(order.id)
@@ -873,7 +1529,7 @@ func (e *Engine) ExecuteOrderByPrice(symbolID uint32, orderID uint64, price Uint
 		}
 
 		// Automatic order matching
-		if e.matching {
+		if ob.isMatching() {
 			err := e.match(ob)
 			if err != nil {
 				return fmt.Errorf("failed to match: %w", err)
@@ -883,7 +1539,15 @@ func (e *Engine) ExecuteOrderByPrice(symbolID uint32, orderID uint64, price Uint
 		return
 	}
 
-	return e.performOrderBookTask(ob, task)
+	cmd := &command{
+		kind:     CommandKindExecuteOrderByPrice,
+		symbolID: symbolID,
+		orderID:  orderID,
+		price:    price,
+		quantity: quantity,
+	}
+
+	return e.performCommand(ob, cmd, task)
 }
 
 ////////////////////////////////////////////////////////////////
@@ -897,18 +1561,21 @@ func (e *Engine) ExecuteOrderByPrice(symbolID uint32, orderID uint64, price Uint
 // matching operation each order book will have the top (best) bid price guarantied
 // less than the top (best) ask price!
 func (e *Engine) Match() {
-	task := func(ob *OrderBook) error {
-		err := e.match(ob)
-		if err != nil {
-			return fmt.Errorf("failed to match: %w", err)
-		}
+	e.performEngineCommand(&command{kind: CommandKindMatch}, nil, e.matchOrderBook)
+}
+
+// matchOrderBook matches crossed orders in the given order book.
+// Order books which trading state forbids matching are not matched.
+func (e *Engine) matchOrderBook(ob *OrderBook) error {
+	if !ob.state.canMatch() {
 		return nil
 	}
-	for i, c := 0, len(e.orderBooks); i < c; i++ {
-		if e.orderBooks[i] != nil {
-			e.performOrderBookTask(e.orderBooks[i], task)
-		}
+
+	err := e.match(ob)
+	if err != nil {
+		return fmt.Errorf("failed to match: %w", err)
 	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////
@@ -919,6 +1586,10 @@ func (e *Engine) Match() {
 func (e *Engine) loopOrderBook(ob *OrderBook) {
 	defer ob.wg.Done()
 
+	// Timer of the earliest expiration of GTD orders
+	var timer <-chan time.Time
+	var timerTime int64
+
 	// Loop over order book tasks from the queue
 	for {
 		select {
@@ -926,15 +1597,29 @@ func (e *Engine) loopOrderBook(ob *OrderBook) {
 			if !ok {
 				return
 			}
-			// Perform task
-			if err := task(ob); err != nil {
-				// Call the corresponding handler
-				// TODO: Make handled errors more informative
-				e.handler.OnError(ob, err)
+			// Wake up callers waiting for the space in the queue
+			ob.dequeued.signal()
+			// Perform task, errors are passed to the handler by the task itself
+			_ = task(ob)
+		case now := <-timer:
+			timer = nil
+			if now.UnixNano() < timerTime {
+				timerTime = 0 // clock has not reached the expiration yet, so set the timer again
+				break
 			}
+			// Orders are expired by the journaled command, the timer is set again once they are deleted
+			e.expireOrdersInBackground()
 		case <-ob.chanForcedStop:
 			return
 		}
+
+		// Set the timer when the earliest expiration is changed
+		if t, ok := ob.expirations.first(); ok && t != timerTime {
+			timerTime = t
+			timer = e.clock.After(time.Unix(0, t).Sub(e.clock.Now()))
+		} else if !ok {
+			timer, timerTime = nil, 0
+		}
 	}
 }
 
@@ -942,6 +1627,41 @@ func (e *Engine) loopOrderBook(ob *OrderBook) {
 // Internal helpers
 ////////////////////////////////////////////////////////////////
 
+// insertOrderBook stores prepared order book in the engine and runs its goroutine in multithread mode.
+func (e *Engine) insertOrderBook(orderBook *OrderBook) error {
+	id := orderBook.symbol.id
+
+	// Ensure order books storage size
+	newSize := len(e.orderBooks)
+	for newSize <= int(id) {
+		newSize *= 2
+	}
+	if newSize > len(e.orderBooks) {
+		newOrderBooks := make([]*OrderBook, newSize)
+		copy(newOrderBooks, e.orderBooks)
+		e.orderBooks = newOrderBooks
+	}
+
+	// Ensure order book does not exist
+	if e.orderBooks[id] != nil {
+		return ErrOrderBookDuplicate
+	}
+
+	e.orderBooks[id] = orderBook
+	e.orderBooksCount++
+
+	// Order book is added after all previous commands, so it is safe to inherit the flag
+	orderBook.matching = e.matching
+
+	// Run goroutine unique to the order book to perform order book specific tasks
+	if e.multithread {
+		orderBook.wg.Add(1)
+		go e.loopOrderBook(orderBook)
+	}
+
+	return nil
+}
+
 func (e *Engine) handleUpdatePriceLevel(ob *OrderBook, update PriceLevelUpdate) {
 	update.ID = ob.lastUpdateID
 	ob.lastUpdateID++ // no need to use atomic.AddUint64()
@@ -956,16 +1676,265 @@ func (e *Engine) handleUpdatePriceLevel(ob *OrderBook, update PriceLevelUpdate)
 	e.handler.OnUpdateOrderBook(ob)
 }
 
-func (e *Engine) performOrderBookTask(ob *OrderBook, task func(ob *OrderBook) error) error {
-	if e.multithread {
+// scheduleTask schedules the task to be performed by the order book after all previously scheduled tasks.
+// Returned function performs the task in single-thread mode or enqueues it in multithread mode.
+// It should be called with unlocked commands, so callers waiting for the space in the queue
+// of the order book do not block commands of other order books.
+// NOTE: Should be called with locked commands.
+func (e *Engine) scheduleTask(ob *OrderBook, task func(ob *OrderBook) error) func() error {
+	if !e.multithread {
+		return func() error { return task(ob) }
+	}
+
+	ticket := ob.enqueueTurns.take()
+	return func() error {
+		ob.enqueueTurns.wait(ticket)
+		defer ob.enqueueTurns.done(ticket)
 		ob.chanTasks <- task
 		return nil
-	} else {
+	}
+}
+
+// scheduleOrderBookTask schedules the task emitting handler events in the order commands are accepted.
+// Errors of the task are passed to the handler, errors of commands are described by CommandError.
+// NOTE: Should be called with locked commands.
+func (e *Engine) scheduleOrderBookTask(ob *OrderBook, task func(ob *OrderBook) error) func() error {
+	return e.scheduleTask(ob, e.eventTask(func(ob *OrderBook) error {
 		err := task(ob)
 		if err != nil {
 			// Call the corresponding handler
 			e.handler.OnError(ob, err)
 		}
 		return err
+	}))
+}
+
+// scheduleOrderBookTaskAsync schedules the task and returns the function enqueuing it and the channel
+// receiving its result. In single-thread mode the task is performed by the function.
+// Returned error is not passed to the handler.
+// NOTE: Should be called with locked commands.
+func (e *Engine) scheduleOrderBookTaskAsync(ob *OrderBook, task func(ob *OrderBook) error) (func(), <-chan error) {
+	done := make(chan error, 1)
+	perform := e.scheduleTask(ob, func(ob *OrderBook) error {
+		done <- task(ob)
+		return nil
+	})
+
+	return func() { _ = perform() }, done
+}
+
+// performOrderBookTaskAsync performs the task after all accepted commands and returns the channel receiving its result.
+// Returned error is not passed to the handler.
+func (e *Engine) performOrderBookTaskAsync(ob *OrderBook, task func(ob *OrderBook) error) <-chan error {
+	e.lockCommands()
+	if !e.hasOrderBook(ob) {
+		e.unlockCommands()
+		done := make(chan error, 1)
+		done <- ErrOrderBookNotFound
+		return done
+	}
+	enqueue, done := e.scheduleOrderBookTaskAsync(ob, task)
+	e.unlockCommands()
+
+	enqueue()
+
+	return done
+}
+
+// performCommand appends the command to the journal and performs the order book task.
+// Tasks of the order book are enqueued in the same order commands are journaled.
+func (e *Engine) performCommand(ob *OrderBook, cmd *command, task func(ob *OrderBook) error) error {
+	e.lockCommands()
+	if err := e.journalOrderBookCommand(ob, cmd); err != nil {
+		e.unlockCommands()
+		return err
+	}
+	perform := e.scheduleOrderBookTask(ob, e.commandTask(cmd, task))
+	e.unlockCommands()
+
+	return perform()
+}
+
+// tryPerformCommand appends the command to the journal and performs the order book task
+// only if the task can be enqueued without blocking. Returns false if the queue is full,
+// the command is not journaled in this case.
+func (e *Engine) tryPerformCommand(ob *OrderBook, cmd *command, task func(ob *OrderBook) error) (bool, error) {
+	e.lockCommands()
+
+	// Tasks are enqueued only in turns of scheduled tasks, so the free space cannot be taken by others
+	if e.multithread && (!ob.enqueueTurns.idle() || len(ob.chanTasks) == cap(ob.chanTasks)) {
+		e.unlockCommands()
+		return false, nil
+	}
+
+	if err := e.journalOrderBookCommand(ob, cmd); err != nil {
+		e.unlockCommands()
+		return false, err
+	}
+	perform := e.scheduleOrderBookTask(ob, e.commandTask(cmd, task))
+	e.unlockCommands()
+
+	return true, perform()
+}
+
+// performCommandContext performs the command as soon as its task can be enqueued without blocking
+// other commands. The context error is returned if the context is done before.
+func (e *Engine) performCommandContext(ctx context.Context, ob *OrderBook, cmd *command, task func(ob *OrderBook) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dequeued := ob.dequeued.wait()
+		enqueued, err := e.tryPerformCommand(ob, cmd, task)
+		if enqueued || err != nil {
+			return err
+		}
+
+		select {
+		case <-dequeued:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// performCommandAsync appends the command to the journal and performs the order book task
+// recording its trades. Returned acknowledgement is done when the task is performed.
+func (e *Engine) performCommandAsync(ob *OrderBook, cmd *command, task func(ob *OrderBook) error) *Ack {
+	e.lockCommands()
+	if err := e.journalOrderBookCommand(ob, cmd); err != nil {
+		e.unlockCommands()
+		return newRejectedAck(0, err)
+	}
+
+	ack := newAck(cmd.sequence)
+	task = e.commandTask(cmd, task)
+	perform := e.scheduleOrderBookTask(ob, func(ob *OrderBook) error {
+		ob.trades = &ack.trades
+		err := task(ob)
+		ob.trades = nil
+		ack.complete(err)
+		return err
+	})
+	e.unlockCommands()
+
+	_ = perform()
+
+	return ack
+}
+
+// performEngineCommand appends the engine-wide command to the journal, applies it to
+// the engine and performs the task for each order book.
+func (e *Engine) performEngineCommand(cmd *command, apply func(), task func(ob *OrderBook) error) {
+	e.lockCommands()
+	if err := e.journalCommand(cmd); err != nil {
+		emit := e.scheduleEvents(func(handler Handler) {
+			handler.OnError(nil, err)
+		})
+		e.unlockCommands()
+		emit()
+		return
+	}
+
+	if apply != nil {
+		apply()
+	}
+
+	task = e.commandTask(cmd, task)
+	performs := make([]func() error, 0, e.orderBooksCount)
+	for i, c := 0, len(e.orderBooks); i < c; i++ {
+		if e.orderBooks[i] != nil {
+			performs = append(performs, e.scheduleOrderBookTask(e.orderBooks[i], task))
+		}
+	}
+	e.unlockCommands()
+
+	for _, perform := range performs {
+		_ = perform()
+	}
+}
+
+// commandTask wraps the order book task of the command,
+// so due orders are expired at the command time before the task is performed
+// and pegged orders follow the top of the order book after it.
+// Returned errors are wrapped into CommandError describing the failed command.
+func (e *Engine) commandTask(cmd *command, task func(ob *OrderBook) error) func(ob *OrderBook) error {
+	now := time.Unix(0, cmd.time)
+	return func(ob *OrderBook) error {
+		ob.now = now
+		if err := e.expireOrders(ob); err != nil {
+			return newCommandError(cmd, ob, fmt.Errorf("failed to expire orders: %w", err))
+		}
+		if err := task(ob); err != nil {
+			return newCommandError(cmd, ob, err)
+		}
+		if err := e.updatePeggedOrders(ob); err != nil {
+			return newCommandError(cmd, ob, err)
+		}
+		return nil
+	}
+}
+
+// journalCommand assigns the next sequence number and the current time to the command and appends it to the journal.
+// While replaying the journal, the command keeps its original sequence number and time.
+// NOTE: Should be called with locked commands.
+func (e *Engine) journalCommand(cmd *command) error {
+	if e.replayed != nil {
+		cmd.sequence = e.replayed.sequence
+		cmd.time = e.replayed.time
+		atomic.StoreInt64(&e.time, cmd.time)
+		return nil
+	}
+
+	cmd.sequence = e.sequence + 1
+	cmd.time = e.now().UnixNano()
+	if e.journal != nil {
+		if err := e.journal.append(cmd); err != nil {
+			cmd.sequence = 0 // the sequence number is not taken by the failed command
+			return fmt.Errorf("failed to journal command (%s): %w", cmd.kind, err)
+		}
+	}
+	atomic.StoreUint64(&e.sequence, cmd.sequence)
+	atomic.StoreInt64(&e.time, cmd.time)
+
+	return nil
+}
+
+// journalOrderBookCommand appends the command of the order book to the journal.
+// The order book could be deleted while the caller is waiting for locked commands, ErrOrderBookNotFound is returned then.
+// NOTE: Should be called with locked commands.
+func (e *Engine) journalOrderBookCommand(ob *OrderBook, cmd *command) error {
+	if !e.hasOrderBook(ob) {
+		return ErrOrderBookNotFound
+	}
+	return e.journalCommand(cmd)
+}
+
+// hasOrderBook returns true if the order book is still added to the engine.
+func (e *Engine) hasOrderBook(ob *OrderBook) bool {
+	return e.OrderBook(ob.symbol.id) == ob
+}
+
+// now returns the current clock time, but not earlier than the time of the last accepted command.
+func (e *Engine) now() time.Time {
+	now := e.clock.Now()
+	if last := atomic.LoadInt64(&e.time); now.UnixNano() < last {
+		return time.Unix(0, last)
+	}
+	return now
+}
+
+// lockCommands locks journaling and enqueueing of commands in multithread mode.
+func (e *Engine) lockCommands() {
+	if e.multithread {
+		e.mxCommands.Lock()
+	}
+}
+
+// unlockCommands unlocks journaling and enqueueing of commands in multithread mode.
+func (e *Engine) unlockCommands() {
+	if e.multithread {
+		e.mxCommands.Unlock()
 	}
 }
@@ -108,12 +108,22 @@ func (e *Engine) activateStopOrders(ob *OrderBook, side OrderSide, node *avl.Nod
 	return
 }
 
-func (e *Engine) activateStopOrder(ob *OrderBook, order *Order) (bool, error) {
-	// Call handler before further actions
+// handleActivateOrder marks the stop order as triggered at the order book time and calls the corresponding handler.
+func (e *Engine) handleActivateOrder(ob *OrderBook, order *Order) {
+	order.status = OrderStatusTriggered
+	order.activateTime = ob.now
+	order.updateTime = ob.now
 	e.handler.OnActivateOrder(ob, order)
+}
+
+func (e *Engine) activateStopOrder(ob *OrderBook, order *Order) (_ bool, err error) {
+	defer func() { err = errorInPhase(ErrorPhaseActivate, err) }()
+
+	// Call handler before further actions
+	e.handleActivateOrder(ob, order)
 
 	// Check and delete linked orders (OCO)
-	err := e.deleteLinkedOrder(ob, order, true)
+	err = e.deleteLinkedOrder(ob, order, true)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete linked order: %w", err)
 	}
@@ -131,14 +141,17 @@ func (e *Engine) activateStopOrder(ob *OrderBook, order *Order) (bool, error) {
 	order.timeInForce = OrderTimeInForceIOC
 
 	// Call the corresponding handler
-	e.handler.OnUpdateOrder(ob, order)
+	e.handleUpdateOrder(ob, order)
 
-	// Match the market order
-	e.matchMarketOrder(ob, order)
+	// Match the market order, the order deleted by matching has been already released
+	deleted, err := e.matchMarketOrder(ob, order)
+	if err != nil {
+		return false, fmt.Errorf("failed to match market order: %w", err)
+	}
 
-	if !order.IsExecuted() {
+	if !deleted {
 		// Call the corresponding handler
-		e.handler.OnDeleteOrder(ob, order)
+		e.handleDeleteOrder(ob, order, remainderReason(ob, order))
 
 		// Erase the order
 		ob.orders.Delete(order.id)
@@ -150,12 +163,14 @@ func (e *Engine) activateStopOrder(ob *OrderBook, order *Order) (bool, error) {
 	return true, nil
 }
 
-func (e *Engine) activateStopLimitOrder(ob *OrderBook, order *Order) (bool, error) {
+func (e *Engine) activateStopLimitOrder(ob *OrderBook, order *Order) (_ bool, err error) {
+	defer func() { err = errorInPhase(ErrorPhaseActivate, err) }()
+
 	// Call handler before further actions
-	e.handler.OnActivateOrder(ob, order)
+	e.handleActivateOrder(ob, order)
 
 	// Check and delete linked orders (OCO)
-	err := e.deleteLinkedOrder(ob, order, true)
+	err = e.deleteLinkedOrder(ob, order, true)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete linked order: %w", err)
 	}
@@ -171,19 +186,28 @@ func (e *Engine) activateStopLimitOrder(ob *OrderBook, order *Order) (bool, erro
 	order.stopPrice = NewZeroUint()
 
 	// Call the corresponding handler
-	e.handler.OnUpdateOrder(ob, order)
+	e.handleUpdateOrder(ob, order)
 
-	// Match the limit order
-	err = e.matchLimitOrder(ob, order)
+	// Check post-only order before matching
+	placed, err := e.checkPostOnly(ob, order)
+	if err != nil || !placed {
+		return true, err
+	}
+
+	// Match the limit order, the order deleted by matching has been already released
+	deleted, err := e.matchLimitOrder(ob, order)
 	if err != nil {
 		return false, fmt.Errorf("failed to match limit order: %w", err)
 	}
+	if deleted {
+		return true, nil
+	}
 
 	// Delete remaining part in case of 'Immediate-Or-Cancel'/'Fill-Or-Kill' and exit.
 	// If executed, handler has been already called.
 	if (order.IsIOC() || order.IsFOK()) && !order.IsExecuted() {
 		// Call the corresponding handler
-		e.handler.OnDeleteOrder(ob, order)
+		e.handleDeleteOrder(ob, order, remainderReason(ob, order))
 
 		// Erase the order
 		ob.orders.Delete(order.id)
@@ -192,8 +216,8 @@ func (e *Engine) activateStopLimitOrder(ob *OrderBook, order *Order) (bool, erro
 		ob.allocator.PutOrder(order)
 	}
 
-	// Add remaining order in order book for GTC
-	if order.IsGTC() && !order.IsExecuted() {
+	// Add remaining order in order book for GTC and post-only
+	if order.isResting() && !order.IsExecuted() {
 		// Add the new limit order into the order book
 		priceLevelUpdate, err := ob.addOrder(ob.treeForOrder(order), order)
 		if err != nil {
@@ -287,7 +311,7 @@ func (e *Engine) recalculateTrailingStopPrice(ob *OrderBook, side OrderSide, nod
 				}
 
 				// Call the corresponding handler
-				e.handler.OnUpdateOrder(ob, order)
+				e.handleUpdateOrder(ob, order)
 
 				// Add the new stop order into the order book
 				_, err = ob.addOrder(tree, order)
@@ -0,0 +1,306 @@
+package matching
+
+import (
+	"fmt"
+
+	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
+	"github.com/cryptonstudio/crypton-matching-engine/types/list"
+)
+
+////////////////////////////////////////////////////////////////
+// Auction clearing price
+////////////////////////////////////////////////////////////////
+
+// auctionLevel is the price level volume used to calculate the auction clearing price.
+type auctionLevel struct {
+	price  Uint
+	volume Uint
+}
+
+// AuctionPrice returns the clearing price and the executable volume of the auction
+// if it is ended now. The clearing price is the price with the maximum executable volume,
+// then with the minimum imbalance and then the closest one to the market price.
+// The executable volume excludes orders which the uncrossing skips because their
+// all-or-none or minimum quantity conditions are not met.
+// Zero volume is returned if the order book is not crossed.
+func (ob *OrderBook) AuctionPrice() (price Uint, volume Uint) {
+	price, volume = NewZeroUint(), NewZeroUint()
+
+	// Continue only if there are crossed orders
+	topBid, topAsk := ob.TopBid(), ob.TopAsk()
+	if topBid == nil || topAsk == nil || topBid.Value().Price().LessThan(topAsk.Value().Price()) {
+		return
+	}
+	low, high := topAsk.Value().Price(), topBid.Value().Price()
+
+	// Collect crossed price levels and their orders in the price-time priority,
+	// bids are sorted from the highest price and asks from the lowest one
+	bids, bidOrders := crossedLevels(topBid, func(p Uint) bool { return p.GreaterThanOrEqualTo(low) })
+	asks, askOrders := crossedLevels(topAsk, func(p Uint) bool { return p.LessThanOrEqualTo(high) })
+
+	// Clearing price is one of crossed price levels or the market price between them
+	candidates := make([]Uint, 0, len(bids)+len(asks)+1)
+	for _, level := range bids {
+		candidates = append(candidates, level.price)
+	}
+	for _, level := range asks {
+		candidates = append(candidates, level.price)
+	}
+	if ob.marketPrice.GreaterThanOrEqualTo(low) && ob.marketPrice.LessThanOrEqualTo(high) {
+		candidates = append(candidates, ob.marketPrice)
+	}
+
+	var imbalance, distance Uint
+	found := false
+	for _, candidate := range candidates {
+		buyVolume, sellVolume := NewZeroUint(), NewZeroUint()
+		for _, level := range bids {
+			if level.price.GreaterThanOrEqualTo(candidate) {
+				buyVolume = buyVolume.Add(level.volume)
+			}
+		}
+		for _, level := range asks {
+			if level.price.LessThanOrEqualTo(candidate) {
+				sellVolume = sellVolume.Add(level.volume)
+			}
+		}
+
+		candidateVolume := executableVolume(bidOrders, askOrders, candidate)
+		candidateImbalance := absDiff(buyVolume, sellVolume)
+		candidateDistance := absDiff(candidate, ob.marketPrice)
+
+		better := !found
+		switch {
+		case better:
+		case !candidateVolume.Equals(volume):
+			better = candidateVolume.GreaterThan(volume)
+		case !candidateImbalance.Equals(imbalance):
+			better = candidateImbalance.LessThan(imbalance)
+		case !candidateDistance.Equals(distance):
+			better = candidateDistance.LessThan(distance)
+		default:
+			better = candidate.LessThan(price)
+		}
+		if better {
+			price, volume, imbalance, distance = candidate, candidateVolume, candidateImbalance, candidateDistance
+			found = true
+		}
+	}
+
+	return
+}
+
+// crossedLevels returns price levels starting from the given top one while they are crossed
+// and orders of these levels in the price-time priority.
+func crossedLevels(top *avl.Node[Uint, *PriceLevelL3], crossed func(price Uint) bool) ([]auctionLevel, []*Order) {
+	var levels []auctionLevel
+	var orders []*Order
+	for node := top; node != nil && crossed(node.Key()); node = node.NextRight() {
+		priceLevel := node.Value()
+		levels = append(levels, auctionLevel{priceLevel.Price(), priceLevel.Volume()})
+		for it := priceLevel.Iterator(); it.Next(); {
+			orders = append(orders, it.Current().Value)
+		}
+	}
+	return levels, orders
+}
+
+// executableVolume returns the volume executed by the uncrossing at the given clearing price.
+// Crossed orders are paired in the price-time priority the same way the uncrossing does,
+// orders which cannot be executed by the paired quantity are skipped.
+func executableVolume(bids []*Order, asks []*Order, price Uint) Uint {
+	volume := NewZeroUint()
+
+	var bid, ask auctionOrder
+	i, j := 0, 0
+	for {
+		// Take the next crossed orders of both sides
+		if bid.order == nil {
+			if i == len(bids) || bids[i].price.LessThan(price) {
+				return volume
+			}
+			bid = newAuctionOrder(bids[i], price)
+			i++
+		}
+		if ask.order == nil {
+			if j == len(asks) || asks[j].price.GreaterThan(price) {
+				return volume
+			}
+			ask = newAuctionOrder(asks[j], price)
+			j++
+		}
+
+		// Orders requiring bigger executions are skipped
+		quantity := Min(bid.quantity, ask.quantity)
+		bidSkipped, askSkipped := quantity.LessThan(bid.minExecution()), quantity.LessThan(ask.minExecution())
+		if bidSkipped || askSkipped {
+			if bidSkipped {
+				bid = auctionOrder{}
+			}
+			if askSkipped {
+				ask = auctionOrder{}
+			}
+			continue
+		}
+
+		volume = volume.Add(quantity)
+		bid.execute(quantity)
+		ask.execute(quantity)
+	}
+}
+
+// auctionOrder is the crossed order with the quantity left to execute by the uncrossing.
+type auctionOrder struct {
+	order    *Order
+	quantity Uint
+	executed bool
+}
+
+// newAuctionOrder returns the crossed order with its whole quantity executable at the given price.
+// Iceberg orders are refreshed by the uncrossing, so the hidden quantity is executable as well.
+func newAuctionOrder(order *Order, price Uint) auctionOrder {
+	quantity, _ := calcRestAvailableQuantities(order, price)
+	return auctionOrder{order: order, quantity: quantity}
+}
+
+// minExecution returns the minimum quantity of the next execution of the order, see Order.minExecution().
+func (o *auctionOrder) minExecution() Uint {
+	if o.executed {
+		return NewZeroUint()
+	}
+	return o.order.minExecution()
+}
+
+// execute reduces the quantity left to execute, the order is done once it is fully executed.
+func (o *auctionOrder) execute(quantity Uint) {
+	o.quantity = o.quantity.Sub(quantity)
+	o.executed = true
+	if o.quantity.IsZero() {
+		*o = auctionOrder{}
+	}
+}
+
+// absDiff returns the absolute difference of given values.
+func absDiff(a Uint, b Uint) Uint {
+	if a.LessThan(b) {
+		return b.Sub(a)
+	}
+	return a.Sub(b)
+}
+
+////////////////////////////////////////////////////////////////
+// Auction uncrossing
+////////////////////////////////////////////////////////////////
+
+// uncross executes all crossed orders of the order book at the auction clearing price.
+// Orders are executed in the price-time priority, the order which has come earlier is the maker.
+func (e *Engine) uncross(ob *OrderBook) (err error) {
+	defer func() { err = errorInPhase(ErrorPhaseMatch, err) }()
+
+	price, volume := ob.AuctionPrice()
+	if volume.IsZero() {
+		return nil
+	}
+
+	bids := newAuctionCursor(&ob.bids, func(p Uint) bool { return p.GreaterThanOrEqualTo(price) })
+	asks := newAuctionCursor(&ob.asks, func(p Uint) bool { return p.LessThanOrEqualTo(price) })
+	for {
+		// Continue only if there are orders crossed at the clearing price
+		bid, ask := bids.order(), asks.order()
+		if bid == nil || ask == nil {
+			return nil
+		}
+
+		// Price levels following the current ones are kept by changes of the current orders
+		bidNext, askNext := bids.level.NextRight(), asks.level.NextRight()
+
+		// Prevent self-trade of crossed orders, the newest order is the one which has come later
+		newest, oldest := bid, ask
+		if newest.placedBefore(oldest) {
+			newest, oldest = oldest, newest
+		}
+		if mode := ob.selfTradePrevention(newest, oldest); mode != 0 {
+			_, _, err := e.preventSelfTrade(ob, newest, oldest, mode)
+			if err != nil {
+				return fmt.Errorf("failed to prevent self-trade (id: %d): %w", newest.ID(), err)
+			}
+			bids.sync(bidNext)
+			asks.sync(askNext)
+			continue
+		}
+
+		// Orders requiring bigger executions (all-or-none) are skipped keeping their time priority,
+		// they are left to the continuous trading
+		if bidSkipped, askSkipped := skipCrossedOrders(bid, ask, price); bidSkipped || askSkipped {
+			if bidSkipped {
+				bids.skip()
+			}
+			if askSkipped {
+				asks.skip()
+			}
+			continue
+		}
+
+		_, _, err := e.executeCrossedOrders(ob, bid, ask, price)
+		if err != nil {
+			return err
+		}
+		bids.sync(bidNext)
+		asks.sync(askNext)
+	}
+}
+
+// auctionCursor walks crossed orders of one side of the order book in the price-time priority.
+// Orders before the current one in its price level are all skipped by the uncrossing, they are kept
+// in the order book, so the cursor keeps the last skipped order instead of walking the level again.
+type auctionCursor struct {
+	tree    *avl.Tree[Uint, *PriceLevelL3]
+	level   *avl.Node[Uint, *PriceLevelL3]
+	price   Uint                  // price of the current level
+	skipped *list.Element[*Order] // last skipped order of the current level
+	crossed func(price Uint) bool
+}
+
+// newAuctionCursor returns the cursor at the top of the given tree.
+func newAuctionCursor(tree *avl.Tree[Uint, *PriceLevelL3], crossed func(price Uint) bool) *auctionCursor {
+	c := &auctionCursor{tree: tree, crossed: crossed}
+	c.moveTo(tree.MostLeft())
+	return c
+}
+
+// order returns the current crossed order, it is the first not skipped order in the price-time priority.
+// Returns nil if there is no such order.
+func (c *auctionCursor) order() *Order {
+	for c.level != nil && c.crossed(c.price) {
+		next := c.level.Value().Queue().Front()
+		if c.skipped != nil {
+			next = c.skipped.Next()
+		}
+		if next != nil {
+			return next.Value
+		}
+		c.moveTo(c.level.NextRight())
+	}
+	return nil
+}
+
+// skip skips the current order, it keeps its place in the price level.
+func (c *auctionCursor) skip() {
+	c.skipped = c.order().orderQueued
+}
+
+// sync moves the cursor to the next price level if the current one is deleted by executions
+// of its orders. The next level is taken before the order book is changed.
+func (c *auctionCursor) sync(next *avl.Node[Uint, *PriceLevelL3]) {
+	if c.level != nil && c.tree.Find(c.price) != c.level {
+		c.moveTo(next)
+	}
+}
+
+// moveTo moves the cursor to the beginning of the given price level.
+func (c *auctionCursor) moveTo(level *avl.Node[Uint, *PriceLevelL3]) {
+	c.level, c.skipped = level, nil
+	if level != nil {
+		c.price = level.Key()
+	}
+}
@@ -2,6 +2,7 @@ package matching
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
 )
@@ -11,7 +12,9 @@ import (
 ////////////////////////////////////////////////////////////////
 
 // match matches crossed orders in given order book.
-func (e *Engine) match(ob *OrderBook) error {
+func (e *Engine) match(ob *OrderBook) (err error) {
+	defer func() { err = errorInPhase(ErrorPhaseMatch, err) }()
+
 	// Matching loop
 	for {
 		for {
@@ -29,89 +32,76 @@ func (e *Engine) match(ob *OrderBook) error {
 			itAsk.Next()
 			// Find the first order to execute and the first order to reduce
 			// Execute crossed orders
+			progressed := false
 			for itBid.Valid() && itAsk.Valid() {
 				if itBid.Current().Value == nil || itAsk.Current().Value == nil {
 					break
 				}
-				// Find the best order to execute and the best order to reduce
-				executing, reducing, swapped := itBid.Current().Value, itAsk.Current().Value, false
+				// Prevent self-trade of crossed orders, the newest order is the one which has come later
+				bid, ask := itBid.Current().Value, itAsk.Current().Value
+				newest, oldest := bid, ask
+				if newest.placedBefore(oldest) {
+					newest, oldest = oldest, newest
+				}
+				if mode := ob.selfTradePrevention(newest, oldest); mode != 0 {
+					newestCancelled, oldestCancelled, err := e.preventSelfTrade(ob, newest, oldest, mode)
+					if err != nil {
+						return fmt.Errorf("failed to prevent self-trade (id: %d): %w", newest.ID(), err)
+					}
+					if newestCancelled && newest == bid || oldestCancelled && oldest == bid {
+						itBid.Next()
+					}
+					if newestCancelled && newest == ask || oldestCancelled && oldest == ask {
+						itAsk.Next()
+					}
+					progressed = true
+					continue
+				}
 
 				// Need to define price based on maker order,
 				// define maker as order that has come earlier,
 				// calculate price and call handler based on this.
 				var price Uint
-				if executing.id < reducing.id {
-					price = getPriceForTrade(executing, reducing)
+				if bid.placedBefore(ask) {
+					price = getPriceForTrade(bid, ask)
 				} else {
-					price = getPriceForTrade(reducing, executing)
+					price = getPriceForTrade(ask, bid)
 				}
 
-				// Define quantities for current execution.
-				reducingQty, reducingQuoteQty := calcRestAvailableQuantities(reducing, price)
-				executingQty, executingQuoteQty := calcRestAvailableQuantities(executing, price)
-				quantity, quoteQuantity := executingQty, executingQuoteQty
-
-				if reducingQty.LessThan(executingQty) {
-					quantity, quoteQuantity = reducingQty, reducingQuoteQty
-					executing, reducing, swapped = reducing, executing, true // swap
+				// Halt the order book instead of the execution outside of price bands
+				if !ob.priceInBands(price) {
+					return e.setTradingState(ob, TradingStateHalted)
 				}
 
-				e.handler.OnExecuteOrder(ob, reducing.id, price, quantity, quoteQuantity)
-				e.handler.OnExecuteOrder(ob, executing.id, price, quantity, quoteQuantity)
-
-				if executing.id < reducing.id {
-					e.handler.OnExecuteTrade(
-						ob, OrderUpdate{executing.id, quantity, quoteQuantity}, OrderUpdate{reducing.id, quantity, quoteQuantity},
-						price, quantity, quoteQuantity,
-					)
-				} else {
-					e.handler.OnExecuteTrade(
-						ob, OrderUpdate{reducing.id, quantity, quoteQuantity}, OrderUpdate{executing.id, quantity, quoteQuantity},
-						price, quantity, quoteQuantity,
-					)
+				// Skip orders requiring bigger executions (all-or-none), they keep their time priority
+				if bidSkipped, askSkipped := skipCrossedOrders(bid, ask, price); bidSkipped || askSkipped {
+					if bidSkipped {
+						itBid.Next()
+					}
+					if askSkipped {
+						itAsk.Next()
+					}
+					continue
 				}
 
-				// Execute orders
-				reducingExecuted, err := e.executeOrder(ob, reducing, quantity, quoteQuantity)
-				if err != nil {
-					return fmt.Errorf("failed to execute order (id: %d): %w", reducing.ID(), err)
-				}
-				executingExecuted, err := e.executeOrder(ob, executing, quantity, quoteQuantity)
+				bidExecuted, askExecuted, err := e.executeCrossedOrders(ob, bid, ask, price)
 				if err != nil {
-					return fmt.Errorf("failed to execute order (id: %d): %w", executing.ID(), err)
-				}
-
-				// Update common market price.
-				ob.updateMarketPrice(price)
-
-				// Cut remainders for orders.
-				if !reducingExecuted {
-					reducingExecuted = e.cutRemainders(ob, reducing)
-				}
-				if !executingExecuted {
-					executingExecuted = e.cutRemainders(ob, executing)
-				}
-
-				// Check if executing is executed.
-				if !executingExecuted {
-					return ErrInternalExecutingOrderNotExecuted
+					return err
 				}
+				progressed = true
 
-				// Next executing order.
-				if !swapped {
+				// Next orders to execute
+				if bidExecuted {
 					itBid.Next()
-				} else {
+				}
+				if askExecuted {
 					itAsk.Next()
 				}
+			}
 
-				// If reducing is executed too.
-				if reducingExecuted {
-					if !swapped {
-						itAsk.Next()
-					} else {
-						itBid.Next()
-					}
-				}
+			// Leave the order book crossed by skipped orders
+			if !progressed {
+				break
 			}
 
 			// Activate stop orders only if the current price level changed
@@ -133,8 +123,14 @@ func (e *Engine) match(ob *OrderBook) error {
 			return fmt.Errorf("failed to activate all stop orders: %w", err)
 		}
 
-		// Activate stop orders until there is something to activate
-		if !activated {
+		// Reprice pegged orders if the top of the order book is changed
+		repriced, err := e.repricePeggedOrders(ob)
+		if err != nil {
+			return fmt.Errorf("failed to reprice pegged orders: %w", err)
+		}
+
+		// Activate stop orders and reprice pegged orders until there is something to do
+		if !activated && !repriced {
 			break
 		}
 	}
@@ -142,28 +138,101 @@ func (e *Engine) match(ob *OrderBook) error {
 	return nil
 }
 
+// skipCrossedOrders returns true for each crossed order which requires bigger execution
+// than the other one allows at the given price.
+func skipCrossedOrders(bid *Order, ask *Order, price Uint) (bool, bool) {
+	bidQty, _ := calcMakerQuantities(bid, price)
+	askQty, _ := calcMakerQuantities(ask, price)
+	quantity := Min(bidQty, askQty)
+	return quantity.LessThan(bid.minExecution()), quantity.LessThan(ask.minExecution())
+}
+
+// executeCrossedOrders executes crossed bid and ask orders with each other at the given price,
+// the order which has come earlier is the maker. Returns true for each fully executed order.
+func (e *Engine) executeCrossedOrders(ob *OrderBook, bid *Order, ask *Order, price Uint) (bool, bool, error) {
+	// Find the best order to execute and the best order to reduce
+	executing, reducing := bid, ask
+
+	// Define quantities for current execution.
+	reducingQty, reducingQuoteQty := calcMakerQuantities(reducing, price)
+	executingQty, executingQuoteQty := calcMakerQuantities(executing, price)
+	quantity, quoteQuantity := executingQty, executingQuoteQty
+
+	if reducingQty.LessThan(executingQty) {
+		quantity, quoteQuantity = reducingQty, reducingQuoteQty
+		executing, reducing = reducing, executing // swap
+	}
+
+	e.handler.OnExecuteOrder(ob, reducing.id, price, quantity, quoteQuantity)
+	e.handler.OnExecuteOrder(ob, executing.id, price, quantity, quoteQuantity)
+
+	if executing.placedBefore(reducing) {
+		e.handleTrade(ob, executing, reducing, price, quantity, quoteQuantity)
+	} else {
+		e.handleTrade(ob, reducing, executing, price, quantity, quoteQuantity)
+	}
+
+	// Iceberg orders with exhausted visible slices are moved to the back of the queue
+	reducingRefreshed := reducing.IsIceberg() && quantity.GreaterThanOrEqualTo(reducing.visible)
+	executingRefreshed := executing.IsIceberg() && quantity.GreaterThanOrEqualTo(executing.visible)
+
+	// Execute orders
+	reducingExecuted, err := e.executeOrder(ob, reducing, quantity, quoteQuantity)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to execute order (id: %d): %w", reducing.ID(), err)
+	}
+	executingExecuted, err := e.executeOrder(ob, executing, quantity, quoteQuantity)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to execute order (id: %d): %w", executing.ID(), err)
+	}
+
+	// Update common market price.
+	ob.updateMarketPrice(price)
+
+	// Cut remainders for orders.
+	if !reducingExecuted {
+		reducingExecuted = e.cutRemainders(ob, reducing)
+	}
+	if !executingExecuted {
+		executingExecuted = e.cutRemainders(ob, executing)
+	}
+
+	// Check if executing is executed or requeued.
+	if !executingExecuted && !executingRefreshed {
+		return false, false, ErrInternalExecutingOrderNotExecuted
+	}
+	reducingExecuted = reducingExecuted || reducingRefreshed
+
+	if executing == bid {
+		return true, reducingExecuted, nil
+	}
+	return reducingExecuted, true, nil
+}
+
 ////////////////////////////////////////////////////////////////
 // Matching orders
 ////////////////////////////////////////////////////////////////
 
 // matchLimitOrder matches given limit order in given order book.
-func (e *Engine) matchLimitOrder(ob *OrderBook, order *Order) error {
+// Returns true if the order is deleted by matching, see matchOrder().
+func (e *Engine) matchLimitOrder(ob *OrderBook, order *Order) (bool, error) {
 	// Match the limit order
-	err := e.matchOrder(ob, order)
+	deleted, err := e.matchOrder(ob, order)
 	if err != nil {
-		return fmt.Errorf("failed to match order: %w", err)
+		return deleted, fmt.Errorf("failed to match order: %w", err)
 	}
 
-	return nil
+	return deleted, nil
 }
 
 // matchMarketOrder matches given market order in given order book.
-func (e *Engine) matchMarketOrder(ob *OrderBook, order *Order) error {
+// Returns true if the order is deleted by matching, see matchOrder().
+func (e *Engine) matchMarketOrder(ob *OrderBook, order *Order) (bool, error) {
 	var topPrice Uint
 	if order.IsBuy() {
 		// Check if there is nothing to buy
 		if ob.TopAsk() == nil {
-			return nil
+			return false, nil
 		}
 
 		// Get top price from asks and max price for symbol.
@@ -179,7 +248,7 @@ func (e *Engine) matchMarketOrder(ob *OrderBook, order *Order) error {
 	} else {
 		// Check if there is nothing to sell
 		if ob.TopBid() == nil {
-			return nil
+			return false, nil
 		}
 
 		topPrice = ob.TopBid().Value().Price()
@@ -191,70 +260,101 @@ func (e *Engine) matchMarketOrder(ob *OrderBook, order *Order) error {
 	}
 
 	// Match the market order
-	err := e.matchOrder(ob, order)
+	deleted, err := e.matchOrder(ob, order)
 	if err != nil {
-		return fmt.Errorf("failed to match order: %w", err)
+		return deleted, fmt.Errorf("failed to match order: %w", err)
 	}
 
-	return nil
+	return deleted, nil
 }
 
 // matchOrder matches given order in given order book.
-func (e *Engine) matchOrder(ob *OrderBook, taker *Order) error {
-	// Special case for 'Fill-Or-Kill'
+// Returns true if the taker order is deleted by matching: fully executed, canceled by self-trade prevention
+// or by the circuit breaker, or deleted with the dust remainder. The deleted order is released
+// and must not be used by the caller anymore.
+func (e *Engine) matchOrder(ob *OrderBook, taker *Order) (_ bool, err error) {
+	defer func() { err = errorInPhase(ErrorPhaseMatch, err) }()
+
+	// Special case for 'Fill-Or-Kill' and orders with the minimum execution quantity
+	required := taker.minExecution()
 	if taker.IsFOK() {
+		required = taker.quantity
+	}
+	if !required.IsZero() {
 		// Determine the best bid/ask price level
-		var priceLevel *avl.Node[Uint, *PriceLevelL3]
-		if taker.IsBuy() {
-			priceLevel = ob.TopAsk()
-		} else {
-			priceLevel = ob.TopBid()
-		}
+		priceLevel := ob.matchingPriceLevel(taker, false, NewZeroUint())
 		if priceLevel == nil {
-			return nil
+			return false, nil
 		}
 
-		if !e.canExecuteChain(taker, priceLevel) {
-			return nil
+		if !e.canExecuteChain(ob, taker, priceLevel, required) {
+			return false, nil
 		}
 	}
 
-	// Start the matching from the top of the book
+	// Start the matching from the top of the book,
+	// price levels with skipped orders are passed by the next iterations
+	skipped, skippedPrice := false, NewZeroUint()
 	for {
 		// Determine the best bid/ask price level
-		var priceLevel *avl.Node[Uint, *PriceLevelL3]
-		if taker.IsBuy() {
-			priceLevel = ob.TopAsk()
-		} else {
-			priceLevel = ob.TopBid()
-		}
+		priceLevel := ob.matchingPriceLevel(taker, skipped, skippedPrice)
 		if priceLevel == nil {
-			return nil
+			return false, nil
 		}
 
 		// Check the arbitrage bid/ask prices
 		if taker.IsEndByPrice(priceLevel.Value().Price()) {
-			return nil
+			return false, nil
 		}
 
 		if taker.IsExecuted() {
-			return nil
+			return false, nil
+		}
+
+		// Allocate the taker quantity between orders of the price level by the matching policy
+		if ob.policy != nil {
+			deleted, done, levelSkipped, err := e.matchPriceLevel(ob, taker, priceLevel.Value())
+			if err != nil || done {
+				return deleted, err
+			}
+			if levelSkipped {
+				skipped, skippedPrice = true, priceLevel.Value().Price()
+			}
+			continue
 		}
 
+		levelSkipped := false
 		it := priceLevel.Value().Iterator()
 		// Execute crossed orders
 		for it.Next() {
 			maker := it.Current().Value
 
+			// Prevent self-trade, the taker is always the newest order
+			if mode := ob.selfTradePrevention(taker, maker); mode != 0 {
+				takerCancelled, _, err := e.preventSelfTrade(ob, taker, maker, mode)
+				if err != nil {
+					return takerCancelled, fmt.Errorf("failed to prevent self-trade (id: %d): %w", taker.ID(), err)
+				}
+				if takerCancelled {
+					return true, nil
+				}
+				continue
+			}
+
 			// Get the execution price and quantity of crossed order, executing is maker
 			price := getPriceForTrade(maker, taker)
 
-			makerQty, makerQuoteQty := calcRestAvailableQuantities(maker, price)
+			// Halt the order book instead of the execution outside of price bands
+			if !ob.priceInBands(price) {
+				return true, e.haltByCircuitBreaker(ob, taker)
+			}
+
+			makerQty, makerQuoteQty := calcMakerQuantities(maker, price)
 			qty, quoteQty := calcRestAvailableQuantities(taker, price)
 
 			// Check if can't be matched at all (market with not enough available)
 			if qty.IsZero() {
-				return nil
+				return false, nil
 			}
 
 			// Choose less qty as qty for trade
@@ -265,54 +365,301 @@ func (e *Engine) matchOrder(ob *OrderBook, taker *Order) error {
 				quoteQty = Min(makerQuoteQty, quoteQty)
 			}
 
-			// Calc quantities and call handlers
-			e.handler.OnExecuteOrder(ob, taker.id, price, qty, quoteQty)
-			e.handler.OnExecuteOrder(ob, maker.id, price, qty, quoteQty)
-			e.handler.OnExecuteTrade(
-				ob, OrderUpdate{maker.id, qty, quoteQty}, OrderUpdate{taker.id, qty, quoteQty},
-				price, qty, quoteQty,
-			)
+			// Skip the order which requires bigger execution (all-or-none), it keeps its time priority
+			if qty.LessThan(maker.minExecution()) {
+				levelSkipped = true
+				continue
+			}
 
-			// Execute orders
-			takerExecuted, err := e.executeOrder(ob, taker, qty, quoteQty)
+			takerExecuted, err := e.executeMatchedOrders(ob, maker, taker, price, qty, quoteQty)
 			if err != nil {
-				return fmt.Errorf("failed to execute order (id: %d): %w", taker.ID(), err)
+				return takerExecuted, err
 			}
-			makerExecuted, err := e.executeOrder(ob, maker, qty, quoteQty)
-			if err != nil {
-				return fmt.Errorf("failed to execute order (id: %d): %w", maker.ID(), err)
+
+			// Exit the loop if the order is executed
+			if takerExecuted {
+				return true, nil
 			}
+		}
+
+		// Price level with skipped orders is left in the order book
+		if levelSkipped {
+			skipped, skippedPrice = true, priceLevel.Value().Price()
+		}
+	}
+}
 
-			// Update common market price
-			ob.updateMarketPrice(price)
+// matchingPriceLevel returns the best price level of the opposite side of the order book for the taker order.
+// If skipped is true, only price levels worse than the skipped price are returned.
+func (ob *OrderBook) matchingPriceLevel(taker *Order, skipped bool, skippedPrice Uint) *avl.Node[Uint, *PriceLevelL3] {
+	var priceLevel *avl.Node[Uint, *PriceLevelL3]
+	if taker.IsBuy() {
+		priceLevel = ob.TopAsk()
+	} else {
+		priceLevel = ob.TopBid()
+	}
+
+	// Price levels of both sides are ordered from the best price to the worst one
+	for skipped && priceLevel != nil {
+		price := priceLevel.Value().Price()
+		if taker.IsBuy() && price.GreaterThan(skippedPrice) || taker.IsSell() && price.LessThan(skippedPrice) {
+			break
+		}
+		priceLevel = priceLevel.NextRight()
+	}
+
+	return priceLevel
+}
 
-			// Cut remainders for orders
-			if !takerExecuted {
-				takerExecuted = e.cutRemainders(ob, taker)
+// matchPriceLevel matches given taker order with orders of the price level allocated by the matching policy.
+// Returns true if the taker order is deleted by matching (see matchOrder()), the second flag is true
+// if the matching of the taker order is done and should not continue with the next price level,
+// the third flag is true if orders requiring bigger executions (all-or-none) are skipped at the price level.
+func (e *Engine) matchPriceLevel(ob *OrderBook, taker *Order, priceLevel *PriceLevelL3) (bool, bool, bool, error) {
+	// Halt the order book instead of the execution outside of price bands
+	if !ob.priceInBands(priceLevel.Price()) {
+		return true, true, false, e.haltByCircuitBreaker(ob, taker)
+	}
+
+	// Collect orders of the price level in the time priority
+	makers := make([]*Order, 0, priceLevel.Orders())
+	it := priceLevel.Iterator()
+	for it.Next() {
+		makers = append(makers, it.Current().Value)
+	}
+
+	// Prevent self-trade, the taker is always the newest order
+	progressed := false
+	candidates := make([]Allocation, 0, len(makers))
+	for _, maker := range makers {
+		if mode := ob.selfTradePrevention(taker, maker); mode != 0 {
+			takerCancelled, _, err := e.preventSelfTrade(ob, taker, maker, mode)
+			if err != nil {
+				return takerCancelled, true, false, fmt.Errorf("failed to prevent self-trade (id: %d): %w", taker.ID(), err)
 			}
-			if !makerExecuted {
-				_ = e.cutRemainders(ob, maker)
+			if takerCancelled {
+				return true, true, false, nil
 			}
+			progressed = true
+			continue
+		}
 
-			// Exit the loop if the order is executed
-			if takerExecuted {
-				return nil
+		makerQty, _ := calcMakerQuantities(maker, getPriceForTrade(maker, taker))
+		if !makerQty.IsZero() {
+			candidates = append(candidates, Allocation{Order: maker, Quantity: makerQty})
+		}
+	}
+
+	// Check if can't be matched at all (market with not enough available)
+	takerQty, _ := calcRestAvailableQuantities(taker, priceLevel.Price())
+	if takerQty.IsZero() {
+		return false, true, false, nil
+	}
+
+	// Orders requiring bigger executions (all-or-none) are skipped and the quantity is allocated again
+	skipped := false
+	allocations := ob.policy.Allocate(candidates, takerQty, ob.symbol.lotSizeLimits.Step)
+	for {
+		candidates = candidates[:0]
+		for _, allocation := range allocations {
+			if allocation.Quantity.IsZero() || !allocation.Quantity.LessThan(allocation.Order.minExecution()) {
+				candidates = append(candidates, allocation)
 			}
 		}
+		if len(candidates) == len(allocations) {
+			break
+		}
+		skipped = true
+		for i := range candidates {
+			candidates[i].Quantity, _ = calcMakerQuantities(candidates[i].Order, getPriceForTrade(candidates[i].Order, taker))
+		}
+		allocations = ob.policy.Allocate(candidates, takerQty, ob.symbol.lotSizeLimits.Step)
+	}
+
+	// Execute allocated quantities
+	for _, allocation := range allocations {
+		maker := allocation.Order
+		if allocation.Quantity.IsZero() || ob.Order(maker.id) != maker {
+			continue
+		}
+
+		// Get the execution price and quantity of crossed order, executing is maker
+		price := getPriceForTrade(maker, taker)
+		makerQty, makerQuoteQty := calcMakerQuantities(maker, price)
+		qty, quoteQty := calcRestAvailableQuantities(taker, price)
+		if qty.IsZero() {
+			return false, true, skipped, nil
+		}
+
+		// Choose less qty as qty for trade
+		switch {
+		case allocation.Quantity.LessThan(Min(makerQty, qty)):
+			qty = allocation.Quantity
+			quoteQty = qty.Mul(price).Div64(UintPrecision)
+		case makerQty.LessThanOrEqualTo(qty):
+			qty = makerQty
+			quoteQty = Min(makerQuoteQty, quoteQty)
+		}
+
+		takerExecuted, err := e.executeMatchedOrders(ob, maker, taker, price, qty, quoteQty)
+		if err != nil {
+			return takerExecuted, true, skipped, err
+		}
+		progressed = true
+
+		// Exit the loop if the order is executed
+		if takerExecuted {
+			return true, true, skipped, nil
+		}
+	}
+
+	// Leave the rest of the taker order if the policy has not allocated anything
+	return false, !progressed && !skipped, skipped, nil
+}
+
+// handleTrade assigns the next trade ID to the trade of the maker and taker orders,
+// calls the trade handler and records the trade for the acknowledged command.
+func (e *Engine) handleTrade(ob *OrderBook, maker *Order, taker *Order, price Uint, quantity Uint, quoteQuantity Uint) {
+	ob.lastTradeID++
+	trade := Trade{
+		ID:            ob.lastTradeID,
+		Time:          ob.now,
+		AggressorSide: taker.side,
+		MakerOrderID:  maker.id,
+		MakerOwnerID:  maker.ownerID,
+		MakerSide:     maker.side,
+		TakerOrderID:  taker.id,
+		TakerOwnerID:  taker.ownerID,
+		TakerSide:     taker.side,
+		Price:         price,
+		Quantity:      quantity,
+		QuoteQuantity: quoteQuantity,
+	}
+	e.handler.OnTrade(ob, trade)
+	if ob.trades != nil {
+		*ob.trades = append(*ob.trades, trade)
+	}
+}
+
+// executeMatchedOrders executes the taker order with the maker order at the given price and quantities.
+// Returns true if the taker order is fully executed or deleted with the dust remainder and released.
+func (e *Engine) executeMatchedOrders(ob *OrderBook, maker *Order, taker *Order, price Uint, qty Uint, quoteQty Uint) (bool, error) {
+	// Call handlers
+	e.handler.OnExecuteOrder(ob, taker.id, price, qty, quoteQty)
+	e.handler.OnExecuteOrder(ob, maker.id, price, qty, quoteQty)
+	e.handleTrade(ob, maker, taker, price, qty, quoteQty)
+
+	// Execute orders
+	takerExecuted, err := e.executeOrder(ob, taker, qty, quoteQty)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute order (id: %d): %w", taker.ID(), err)
+	}
+	makerExecuted, err := e.executeOrder(ob, maker, qty, quoteQty)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute order (id: %d): %w", maker.ID(), err)
+	}
+
+	// Update common market price
+	ob.updateMarketPrice(price)
+
+	// Cut remainders for orders
+	if !takerExecuted {
+		takerExecuted = e.cutRemainders(ob, taker)
+	}
+	if !makerExecuted {
+		_ = e.cutRemainders(ob, maker)
+	}
+
+	return takerExecuted, nil
+}
+
+////////////////////////////////////////////////////////////////
+// Repricing pegged orders
+////////////////////////////////////////////////////////////////
+
+// updatePeggedOrders reprices pegged orders after the command, so they follow the top of the order book
+// also when it is changed without matching (deleted orders, disabled matching, auction).
+// Repriced orders are matched if the automatic matching is enabled.
+func (e *Engine) updatePeggedOrders(ob *OrderBook) error {
+	repriced, err := e.repricePeggedOrders(ob)
+	if err != nil {
+		return fmt.Errorf("failed to reprice pegged orders: %w", err)
+	}
+	if !repriced || !ob.isMatching() {
+		return nil
+	}
+	if err := e.match(ob); err != nil {
+		return fmt.Errorf("failed to match: %w", err)
+	}
+	return nil
+}
+
+// repricePeggedOrders moves pegged orders to prices pegged to the current top of the order book.
+// Orders are repriced only if reference prices are changed since the last repricing.
+// Returns true if any order is moved to another price level.
+func (e *Engine) repricePeggedOrders(ob *OrderBook) (bool, error) {
+	if len(ob.pegs.ids) == 0 {
+		return false, nil
+	}
+
+	bid, ask := ob.pegReferencePrices()
+	if bid.Equals(ob.pegs.bid) && ask.Equals(ob.pegs.ask) {
+		return false, nil
+	}
+	ob.pegs.bid, ob.pegs.ask = bid, ask
+
+	repriced := false
+	for _, orderID := range slices.Clone(ob.pegs.ids) {
+		// Forget deleted orders
+		order := ob.Order(orderID)
+		if order == nil || !order.IsPegged() || order.priceLevel == nil {
+			ob.pegs.remove(orderID)
+			continue
+		}
+
+		// Keep the current price if the pegged one is not available
+		price, ok := ob.pegPrice(order, bid, ask)
+		if !ok || price.Equals(order.price) || !ob.priceInBands(price) {
+			continue
+		}
+
+		// Move the order to the new price level
+		tree := ob.treeForOrder(order)
+		priceLevelUpdate, err := ob.deleteOrder(tree, order)
+		if err != nil {
+			return repriced, fmt.Errorf("failed to delete pegged order (id: %d): %w", orderID, err)
+		}
+		e.handleUpdatePriceLevel(ob, priceLevelUpdate)
+
+		order.price = price
+		priceLevelUpdate, err = ob.addOrder(tree, order)
+		if err != nil {
+			return repriced, fmt.Errorf("failed to add pegged order (id: %d): %w", orderID, err)
+		}
+		e.handleUpdatePriceLevel(ob, priceLevelUpdate)
+
+		e.handleUpdateOrder(ob, order)
+		repriced = true
 	}
+
+	return repriced, nil
 }
 
 /////////////////////////////////////////////////////
 // Matching chains
 ////////////////////////////////////////////////////////////////
 
-// canExecuteChain have to be used for FOK orders to check if full execution is possible
-// here we can only deal with limit type.
+// canExecuteChain have to be used for FOK orders and orders with the minimum quantity to check
+// if execution of the required quantity is possible, here we can only deal with limit type.
+// Orders requiring bigger executions (all-or-none) are skipped the same way as by the matching.
 func (e *Engine) canExecuteChain(
+	ob *OrderBook,
 	taker *Order,
 	priceLevel *avl.Node[Uint, *PriceLevelL3],
+	required Uint,
 ) bool {
-	required := taker.quantity
+	rest := taker.restQuantity
+	executed := NewZeroUint()
 
 	// Travel through price levels
 	for priceLevel != nil {
@@ -320,6 +667,11 @@ func (e *Engine) canExecuteChain(
 			return false
 		}
 
+		// Full execution would halt the order book
+		if !ob.priceInBands(priceLevel.Value().Price()) {
+			return false
+		}
+
 		// Travel through orders at current price levels
 		it := priceLevel.Value().Iterator()
 		for it.Next() {
@@ -327,13 +679,26 @@ func (e *Engine) canExecuteChain(
 			if order == nil {
 				break
 			}
-			quantity := order.restQuantity
 
-			if required.LessThanOrEqualTo(quantity) {
+			// Self-trade prevention either skips the order or breaks the full execution
+			if mode := ob.selfTradePrevention(taker, order); mode != 0 {
+				if mode != SelfTradePreventionCancelOldest {
+					return false
+				}
+				continue
+			}
+
+			quantity := Min(order.restQuantity, rest)
+			if quantity.LessThan(order.minExecution()) {
+				continue
+			}
+
+			executed = executed.Add(quantity)
+			if required.LessThanOrEqualTo(executed) {
 				return true
 			}
 
-			required = required.Sub(quantity)
+			rest = rest.Sub(quantity)
 		}
 
 		priceLevel = priceLevel.NextRight()
@@ -343,12 +708,83 @@ func (e *Engine) canExecuteChain(
 	return false
 }
 
+////////////////////////////////////////////////////////////////
+// Self-trade prevention
+////////////////////////////////////////////////////////////////
+
+// preventSelfTrade cancels or decrements crossed orders of the same owner according to the given mode.
+// Returned flags are true when the corresponding order is canceled, canceled orders are released.
+func (e *Engine) preventSelfTrade(ob *OrderBook, newest *Order, oldest *Order, mode SelfTradePrevention) (bool, bool, error) {
+	switch mode {
+	case SelfTradePreventionCancelNewest:
+		return true, false, e.cancelOrder(ob, newest, OrderReasonSelfTradePrevention)
+	case SelfTradePreventionCancelOldest:
+		return false, true, e.cancelOrder(ob, oldest, OrderReasonSelfTradePrevention)
+	case SelfTradePreventionCancelBoth:
+		if err := e.cancelOrder(ob, oldest, OrderReasonSelfTradePrevention); err != nil {
+			return false, false, err
+		}
+		return true, true, e.cancelOrder(ob, newest, OrderReasonSelfTradePrevention)
+	case SelfTradePreventionDecrementAndCancel:
+		// Quantities are compared at the price of the oldest (resting) order
+		newestQty, newestQuoteQty := calcRestAvailableQuantities(newest, oldest.price)
+		oldestQty, _ := calcRestAvailableQuantities(oldest, oldest.price)
+
+		switch {
+		case newestQty.LessThan(oldestQty):
+			if err := e.decrementOrder(ob, oldest, newestQty, newestQuoteQty); err != nil {
+				return false, false, err
+			}
+			return true, false, e.cancelOrder(ob, newest, OrderReasonSelfTradePrevention)
+		case oldestQty.LessThan(newestQty):
+			oldestQuoteQty := oldestQty.Mul(oldest.price).Div64(UintPrecision)
+			if err := e.decrementOrder(ob, newest, oldestQty, oldestQuoteQty); err != nil {
+				return false, false, err
+			}
+			return false, true, e.cancelOrder(ob, oldest, OrderReasonSelfTradePrevention)
+		default:
+			if err := e.cancelOrder(ob, oldest, OrderReasonSelfTradePrevention); err != nil {
+				return false, false, err
+			}
+			return true, true, e.cancelOrder(ob, newest, OrderReasonSelfTradePrevention)
+		}
+	}
+
+	return false, false, nil
+}
+
+// haltByCircuitBreaker halts the order book instead of the execution of the taker order outside of price bands.
+// The rest of the taker order is canceled and released, so it never rests crossing the opposite top of the order book.
+func (e *Engine) haltByCircuitBreaker(ob *OrderBook, taker *Order) error {
+	if err := e.setTradingState(ob, TradingStateHalted); err != nil {
+		return err
+	}
+
+	return e.cancelOrder(ob, taker, OrderReasonCircuitBreaker)
+}
+
+// decrementOrder reduces rest quantity of the order without execution because of self-trade prevention.
+func (e *Engine) decrementOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Uint) error {
+	order.reason = OrderReasonSelfTradePrevention
+	defer func() {
+		order.reason = OrderReasonNone
+	}()
+
+	if order.marketQuoteMode {
+		order.SubRestQuoteQuantity(Min(quoteQty, order.restQuoteQuantity))
+		e.handleUpdateOrder(ob, order)
+		return nil
+	}
+
+	return e.reduceOrder(ob, order, qty, OrderReasonSelfTradePrevention, true)
+}
+
 // getPrice ForTrade choses price for trade assuming that quote locking orders execution depends on price,
 // so to guarantee execution quantity of limit orders, less price must be chosen.
 func getPriceForTrade(maker *Order, taker *Order) Uint {
 	switch {
-	// Check market orders, they always executed by price of maker.
-	case taker.IsMarket():
+	// Check market and market-to-limit orders, they always executed by price of maker.
+	case taker.IsMarket() || taker.IsMarketToLimit():
 		return maker.price
 	// Both locked in quote, so take min.
 	case maker.IsLockingQuote() && taker.IsLockingQuote():
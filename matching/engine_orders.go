@@ -11,19 +11,42 @@ import (
 func (e *Engine) addLimitOrder(ob *OrderBook, order Order, recursive bool) error {
 	// Check duplicate
 	if _, ok := ob.orders.Get(order.id); ok {
-		return ErrOrderDuplicate
+		return validationError(ErrOrderDuplicate)
+	}
+
+	// Price the pegged order by the top of the order book
+	if order.IsPegged() {
+		bid, ask := ob.pegReferencePrices()
+		price, ok := ob.pegPrice(&order, bid, ask)
+		if !ok {
+			return validationError(ErrPegPriceNotAvailable)
+		}
+		order.price = price
+	}
+
+	// Reject order price outside of price bands
+	if !ob.priceInBands(order.price) {
+		return validationError(ErrOrderPriceOutOfBand)
 	}
 
 	// Create a new order
 	newOrder := ob.allocator.GetOrder()
 	*newOrder = order
+	newOrder.status = OrderStatusNew
 
 	// Call the corresponding handler
-	e.handler.OnAddOrder(ob, newOrder)
+	e.handleAddOrder(ob, newOrder)
 
-	// Automatic order matching
-	if e.matching && !recursive {
-		err := e.matchLimitOrder(ob, newOrder)
+	// Check post-only order before matching
+	placed, err := e.checkPostOnly(ob, newOrder)
+	if err != nil || !placed {
+		return err
+	}
+
+	// Automatic order matching, the order deleted by matching has been already released
+	deleted := false
+	if ob.isMatching() && !recursive {
+		deleted, err = e.matchLimitOrder(ob, newOrder)
 		if err != nil {
 			return fmt.Errorf("failed to match limit order: %w", err)
 		}
@@ -31,12 +54,12 @@ func (e *Engine) addLimitOrder(ob *OrderBook, order Order, recursive bool) error
 
 	// Delete remaining part in case of 'Immediate-Or-Cancel'/'Fill-Or-Kill' and exit.
 	// If executed, handler has been already called.
-	if (newOrder.IsIOC() || newOrder.IsFOK()) && !newOrder.IsExecuted() {
-		e.handler.OnDeleteOrder(ob, newOrder)
+	if !deleted && (newOrder.IsIOC() || newOrder.IsFOK()) && !newOrder.IsExecuted() {
+		e.handleDeleteOrder(ob, newOrder, remainderReason(ob, newOrder))
 	}
 
-	// Add remaining order in order book for GTC
-	if newOrder.IsGTC() && !newOrder.IsExecuted() {
+	// Add remaining order in order book for GTC and post-only
+	if !deleted && newOrder.isResting() && !newOrder.IsExecuted() {
 		// Set order to internal order storage
 		ob.orders.Set(newOrder.id, newOrder)
 
@@ -49,7 +72,7 @@ func (e *Engine) addLimitOrder(ob *OrderBook, order Order, recursive bool) error
 	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -62,29 +85,40 @@ func (e *Engine) addLimitOrder(ob *OrderBook, order Order, recursive bool) error
 func (e *Engine) addMarketOrder(ob *OrderBook, order Order, recursive bool) error {
 	// Check duplicate
 	if _, ok := ob.orders.Get(order.id); ok {
-		return ErrOrderDuplicate
+		return validationError(ErrOrderDuplicate)
 	}
 
-	newOrder := order
+	// Create a new order
+	newOrder := ob.allocator.GetOrder()
+	*newOrder = order
+	newOrder.status = OrderStatusNew
 
 	newOrder.timeInForce = OrderTimeInForceIOC
 
 	// Call the corresponding handler
 	// Market order must be IOC
-	e.handler.OnAddOrder(ob, &newOrder)
+	e.handleAddOrder(ob, newOrder)
 
-	// Automatic order matching
-	if e.matching && !recursive {
-		e.matchMarketOrder(ob, &newOrder)
+	// Automatic order matching, the order deleted by matching has been already released
+	deleted := false
+	if ob.isMatching() && !recursive {
+		var err error
+		deleted, err = e.matchMarketOrder(ob, newOrder)
+		if err != nil {
+			return fmt.Errorf("failed to match market order: %w", err)
+		}
 	}
 
-	if !newOrder.IsExecuted() {
+	if !deleted {
 		// Call the corresponding handler
-		e.handler.OnDeleteOrder(ob, &newOrder)
+		e.handleDeleteOrder(ob, newOrder, remainderReason(ob, newOrder))
+
+		// Release the order
+		ob.allocator.PutOrder(newOrder)
 	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -94,15 +128,108 @@ func (e *Engine) addMarketOrder(ob *OrderBook, order Order, recursive bool) erro
 	return nil
 }
 
+func (e *Engine) addMarketToLimitOrder(ob *OrderBook, order Order, recursive bool) error {
+	// Check duplicate
+	if _, ok := ob.orders.Get(order.id); ok {
+		return validationError(ErrOrderDuplicate)
+	}
+
+	// Create a new order
+	newOrder := ob.allocator.GetOrder()
+	*newOrder = order
+	newOrder.status = OrderStatusNew
+
+	// Call the corresponding handler
+	e.handleAddOrder(ob, newOrder)
+
+	// Automatic order matching
+	deleted := false
+	if ob.isMatching() && !recursive {
+		var err error
+		deleted, err = e.matchMarketOrder(ob, newOrder)
+		if err != nil {
+			return fmt.Errorf("failed to match market-to-limit order: %w", err)
+		}
+	}
+
+	// Rest the unfilled part of the order, the order deleted by matching has been already released
+	if !deleted {
+		err := e.restMarketToLimitOrder(ob, newOrder)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Automatic order matching
+	if ob.isMatching() && !recursive {
+		err := e.match(ob)
+		if err != nil {
+			return fmt.Errorf("failed to match: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restMarketToLimitOrder converts the rest of the market-to-limit order into the GTC limit order
+// at the price of its last execution and adds it into the order book.
+// The order which has not been executed at all is deleted like the market order.
+func (e *Engine) restMarketToLimitOrder(ob *OrderBook, order *Order) error {
+	if !order.executedQuantity.IsZero() {
+		order.orderType = OrderTypeLimit
+		order.timeInForce = OrderTimeInForceGTC
+		order.price = ob.marketPrice
+		order.marketSlippage = NewZeroUint()
+
+		// Rest quote quantity is converted into the base quantity rounded to the lot size
+		if order.marketQuoteMode {
+			order.restQuantity = ob.symbol.CalcQtyWithLimits(order.restQuoteQuantity, order.price)
+			order.quantity = order.executedQuantity.Add(order.restQuantity)
+			order.restQuoteQuantity = NewZeroUint()
+			order.marketQuoteMode = false
+		}
+	}
+
+	// Delete the order which has nothing to rest
+	if !order.IsLimit() || order.restQuantity.IsZero() {
+		e.handleDeleteOrder(ob, order, remainderReason(ob, order))
+		ob.allocator.PutOrder(order)
+		return nil
+	}
+
+	// Delete the rest which price is outside of price bands
+	if !ob.priceInBands(order.price) {
+		e.handleDeleteOrder(ob, order, OrderReasonPriceOutOfBand)
+		ob.allocator.PutOrder(order)
+		return nil
+	}
+
+	// Call the corresponding handler
+	e.handleUpdateOrder(ob, order)
+
+	// Set order to internal order storage
+	ob.orders.Set(order.id, order)
+
+	// Add the converted limit order into the order book
+	priceLevelUpdate, err := ob.addOrder(ob.treeForOrder(order), order)
+	if err != nil {
+		return err
+	}
+	e.handleUpdatePriceLevel(ob, priceLevelUpdate)
+
+	return nil
+}
+
 func (e *Engine) addStopOrder(ob *OrderBook, order Order, recursive bool) error {
 	// Check duplicate
 	if _, ok := ob.orders.Get(order.id); ok {
-		return ErrOrderDuplicate
+		return validationError(ErrOrderDuplicate)
 	}
 
 	// Create a new order
 	newOrder := ob.allocator.GetOrder()
 	*newOrder = order
+	newOrder.status = OrderStatusNew
 
 	// Find the market price for further stop calculation
 	marketPrice := ob.GetStopPrice(order.stopPriceMode)
@@ -129,10 +256,10 @@ func (e *Engine) addStopOrder(ob *OrderBook, order Order, recursive bool) error
 	}
 
 	// Call the corresponding handler
-	e.handler.OnAddOrder(ob, newOrder)
+	e.handleAddOrder(ob, newOrder)
 
 	// Automatic order matching
-	if !e.matching || recursive {
+	if !ob.isMatching() || recursive {
 		return nil
 	}
 
@@ -140,7 +267,7 @@ func (e *Engine) addStopOrder(ob *OrderBook, order Order, recursive bool) error
 	arbitrage := newOrder.stopPrice.Equals(marketPrice)
 	if arbitrage {
 		// Call handler before further actions
-		e.handler.OnActivateOrder(ob, newOrder)
+		e.handleActivateOrder(ob, newOrder)
 
 		// delete linked order
 		e.deleteLinkedOrder(ob, newOrder, true)
@@ -160,14 +287,17 @@ func (e *Engine) addStopOrder(ob *OrderBook, order Order, recursive bool) error
 		newOrder.timeInForce = OrderTimeInForceIOC
 
 		// Call the corresponding handler
-		e.handler.OnUpdateOrder(ob, newOrder)
+		e.handleUpdateOrder(ob, newOrder)
 
-		// Match the market order
-		e.matchMarketOrder(ob, newOrder)
+		// Match the market order, the order deleted by matching has been already released
+		deleted, err := e.matchMarketOrder(ob, newOrder)
+		if err != nil {
+			return fmt.Errorf("failed to match market order: %w", err)
+		}
 
-		if !newOrder.IsExecuted() {
+		if !deleted {
 			// Call the corresponding handler
-			e.handler.OnDeleteOrder(ob, newOrder)
+			e.handleDeleteOrder(ob, newOrder, remainderReason(ob, newOrder))
 
 			// Erase the order
 			ob.orders.Delete(newOrder.id)
@@ -188,12 +318,13 @@ func (e *Engine) addStopOrder(ob *OrderBook, order Order, recursive bool) error
 func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) error {
 	// Check duplicate
 	if _, ok := ob.orders.Get(order.id); ok {
-		return ErrOrderDuplicate
+		return validationError(ErrOrderDuplicate)
 	}
 
 	// Create a new order
 	newOrder := ob.allocator.GetOrder()
 	*newOrder = order
+	newOrder.status = OrderStatusNew
 
 	// Find the market price for further stop calculation
 	engineStopPrice := ob.GetStopPrice(order.stopPriceMode)
@@ -221,7 +352,7 @@ func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) e
 	}
 
 	// Call the corresponding handler
-	e.handler.OnAddOrder(ob, newOrder)
+	e.handleAddOrder(ob, newOrder)
 
 	// Check the market price
 	arbitrage := newOrder.stopPrice.Equals(engineStopPrice)
@@ -236,7 +367,7 @@ func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) e
 		}
 	} else {
 		// Call handler before further actions
-		e.handler.OnActivateOrder(ob, newOrder)
+		e.handleActivateOrder(ob, newOrder)
 
 		// delete linked order
 		e.deleteLinkedOrder(ob, newOrder, true)
@@ -246,11 +377,18 @@ func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) e
 		newOrder.stopPrice = NewZeroUint()
 
 		// Call the corresponding handler
-		e.handler.OnUpdateOrder(ob, newOrder)
+		e.handleUpdateOrder(ob, newOrder)
 
-		// Automatic order matching
-		if e.matching && !recursive {
-			err := e.matchLimitOrder(ob, newOrder)
+		// Check post-only order before matching
+		placed, err := e.checkPostOnly(ob, newOrder)
+		if err != nil || !placed {
+			return err
+		}
+
+		// Automatic order matching, the order deleted by matching has been already released
+		deleted := false
+		if ob.isMatching() && !recursive {
+			deleted, err = e.matchLimitOrder(ob, newOrder)
 			if err != nil {
 				return fmt.Errorf("failed to match limit order: %w", err)
 			}
@@ -258,12 +396,12 @@ func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) e
 
 		// Delete remaining part in case of 'Immediate-Or-Cancel'/'Fill-Or-Kill' and exit.
 		// If executed, handler has been already called.
-		if (newOrder.IsIOC() || newOrder.IsFOK()) && !newOrder.IsExecuted() {
-			e.handler.OnDeleteOrder(ob, newOrder)
+		if !deleted && (newOrder.IsIOC() || newOrder.IsFOK()) && !newOrder.IsExecuted() {
+			e.handleDeleteOrder(ob, newOrder, remainderReason(ob, newOrder))
 		}
 
-		// Add remaining order in order book for GTC
-		if newOrder.IsGTC() && !newOrder.IsExecuted() {
+		// Add remaining order in order book for GTC and post-only
+		if !deleted && newOrder.isResting() && !newOrder.IsExecuted() {
 			// Set order to internal order storage
 			ob.orders.Set(newOrder.id, newOrder)
 
@@ -277,7 +415,7 @@ func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) e
 	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -292,7 +430,9 @@ func (e *Engine) addStopLimitOrder(ob *OrderBook, order Order, recursive bool) e
 
 // executeOrder processes the fact of order execution.
 // bool flag is true when order is executed/deleted.
-func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Uint) (bool, error) {
+func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Uint) (_ bool, err error) {
+	defer func() { err = errorInPhase(ErrorPhaseExecute, err) }()
+
 	// Decrease the order available quantity
 	if order.IsLockingQuote() {
 		order.SubAvailable(quoteQty)
@@ -303,9 +443,10 @@ func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Ui
 	// Increase the order executed quantity
 	order.AddExecutedQuantity(qty)
 	order.AddExecutedQuoteQuantity(quoteQty)
+	order.status = OrderStatusPartiallyFilled
 
 	// Check and delete linked orders
-	err := e.deleteLinkedOrder(ob, order, true)
+	err = e.deleteLinkedOrder(ob, order, true)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete linked order (id: %d): %w", order.ID(), err)
 	}
@@ -315,11 +456,12 @@ func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Ui
 		executed := false
 		order.SubRestQuoteQuantity(quoteQty)
 		if !order.IsExecuted() {
-			e.handler.OnUpdateOrder(ob, order)
+			e.handleUpdateOrder(ob, order)
 		} else {
 			executed = true
-			e.handler.OnDeleteOrder(ob, order)
-			e.deleteOrder(ob, order, true)
+			if err := e.deleteOrder(ob, order, OrderReasonNone, true); err != nil {
+				return false, err
+			}
 		}
 
 		return executed, nil
@@ -328,14 +470,15 @@ func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Ui
 	// Reduce the order rest quantities
 	visible := order.VisibleQuantity()
 	order.SubRestQuantity(qty)
+	order.executeVisible(qty)
 	visible = visible.Sub(order.VisibleQuantity())
 	executed := false
 
 	if !order.IsExecuted() {
-		e.handler.OnUpdateOrder(ob, order)
+		e.handleUpdateOrder(ob, order)
 	} else {
 		executed = true
-		e.handler.OnDeleteOrder(ob, order)
+		e.handleDeleteOrder(ob, order, OrderReasonNone)
 	}
 
 	// TODO: this part is copy from deleteOrder but without matching. Need unify.
@@ -350,6 +493,11 @@ func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Ui
 		e.handleUpdatePriceLevel(ob, priceLevelUpdate)
 	}
 
+	// Refresh the exhausted visible slice of the iceberg order
+	if err := e.refreshIceberg(ob, order); err != nil {
+		return false, err
+	}
+
 	// Delete the empty order
 	if order.IsExecuted() {
 		// Erase the order
@@ -362,11 +510,30 @@ func (e *Engine) executeOrder(ob *OrderBook, order *Order, qty Uint, quoteQty Ui
 	return executed, nil
 }
 
+// refreshIceberg shows the next visible slice of the iceberg order if the current one is exhausted.
+// The refreshed order is moved to the back of the price level queue.
+func (e *Engine) refreshIceberg(ob *OrderBook, order *Order) error {
+	if !order.isSliceExhausted() {
+		return nil
+	}
+
+	priceLevelUpdate, err := ob.requeueOrder(ob.treeForOrder(order), order)
+	if err != nil {
+		return err
+	}
+
+	e.handleUpdateOrder(ob, order)
+	e.handleUpdatePriceLevel(ob, priceLevelUpdate)
+
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////
 // Reducing orders
 ////////////////////////////////////////////////////////////////
 
-func (e *Engine) reduceOrder(ob *OrderBook, order *Order, quantity Uint, recursive bool) error {
+// reduceOrder reduces the rest quantity of the order, the order reduced to zero quantity is deleted for the reason.
+func (e *Engine) reduceOrder(ob *OrderBook, order *Order, quantity Uint, reason OrderReason, recursive bool) error {
 	// Calculate the minimal possible order quantity to reduce
 	quantity = Min(quantity, order.restQuantity)
 
@@ -377,9 +544,9 @@ func (e *Engine) reduceOrder(ob *OrderBook, order *Order, quantity Uint, recursi
 
 	// Call the corresponding handler
 	if !order.IsExecuted() {
-		e.handler.OnUpdateOrder(ob, order)
+		e.handleUpdateOrder(ob, order)
 	} else {
-		e.handler.OnDeleteOrder(ob, order)
+		e.handleDeleteOrder(ob, order, reason)
 	}
 
 	if order.priceLevel != nil {
@@ -402,7 +569,7 @@ func (e *Engine) reduceOrder(ob *OrderBook, order *Order, quantity Uint, recursi
 	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -442,22 +609,45 @@ func (e *Engine) modifyOrder(ob *OrderBook, order *Order, newPrice Uint, newQuan
 		}
 	}
 
-	// Update the order
-	if !order.IsExecuted() {
+	if order.IsExecuted() {
+		// Delete the empty order, it is either canceled with zero quantity
+		// or fully executed before the mitigated modification
+		reason := OrderReasonNone
+		if newQuantity.IsZero() {
+			reason = OrderReasonUserCancel
+		}
+		e.handleDeleteOrder(ob, order, reason)
+
+		// Erase the order
+		ob.orders.Delete(order.id)
 
+		// Release the order
+		ob.allocator.PutOrder(order)
+	} else {
 		// Call the corresponding handler
-		e.handler.OnUpdateOrder(ob, order)
+		e.handleUpdateOrder(ob, order)
+
+		// Check post-only limit order before matching, pending stop orders are checked and matched when activated
+		if order.IsLimit() {
+			placed, err := e.checkPostOnly(ob, order)
+			if err != nil || !placed {
+				return err
+			}
+		}
 
 		// Automatic order matching
-		if e.matching && !recursive {
-			err := e.matchLimitOrder(ob, order)
+		deleted := false
+		if order.IsLimit() && ob.isMatching() && !recursive {
+			var err error
+			deleted, err = e.matchLimitOrder(ob, order)
 			if err != nil {
 				return fmt.Errorf("failed to match limit order: %w", err)
 			}
 		}
 
-		// Add non empty order into the order book
-		if !order.IsExecuted() {
+		// Add non empty order into the order book,
+		// the order deleted by matching has been already released
+		if !deleted && !order.IsExecuted() {
 			// Add the modified order into the order book
 			priceLevelUpdate, err := ob.addOrder(ob.treeForOrder(order), order)
 			if err != nil {
@@ -467,23 +657,10 @@ func (e *Engine) modifyOrder(ob *OrderBook, order *Order, newPrice Uint, newQuan
 				e.handleUpdatePriceLevel(ob, priceLevelUpdate)
 			}
 		}
-
-	}
-
-	// Delete the empty order
-	if order.IsExecuted() {
-		// Call the corresponding handler
-		e.handler.OnDeleteOrder(ob, order)
-
-		// Erase the order
-		ob.orders.Delete(order.id)
-
-		// Release the order
-		ob.allocator.PutOrder(order)
 	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -508,13 +685,15 @@ func (e *Engine) replaceOrder(ob *OrderBook, order *Order, newID uint64, newPric
 	}
 
 	// Call the corresponding handler
-	e.handler.OnDeleteOrder(ob, order)
+	e.handleDeleteOrder(ob, order, OrderReasonReplaced)
 
 	// Erase the order
 	ob.orders.Delete(order.id)
 
 	// Update the order with new values
 	order.id = newID
+	order.status = OrderStatusNew
+	order.reason = OrderReasonNone
 	order.price = newPrice
 	order.quantity = newQuantity
 	order.executedQuantity = NewZeroUint()
@@ -522,18 +701,27 @@ func (e *Engine) replaceOrder(ob *OrderBook, order *Order, newID uint64, newPric
 	order.restQuantity = newQuantity
 
 	// Call the corresponding handler
-	e.handler.OnAddOrder(ob, order)
+	e.handleAddOrder(ob, order)
+
+	// Check post-only limit order before matching, pending stop orders are checked and matched when activated
+	if order.IsLimit() {
+		placed, err := e.checkPostOnly(ob, order)
+		if err != nil || !placed {
+			return err
+		}
+	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
-		err := e.matchLimitOrder(ob, order)
+	deleted := false
+	if order.IsLimit() && ob.isMatching() && !recursive {
+		deleted, err = e.matchLimitOrder(ob, order)
 		if err != nil {
 			return fmt.Errorf("failed to match limit order: %w", err)
 		}
 	}
 
-	// Add the order
-	if !order.IsExecuted() {
+	// Add the order, the order deleted by matching has been already released
+	if !deleted && !order.IsExecuted() {
 		// Insert the order
 		ob.orders.Set(order.id, order)
 
@@ -545,17 +733,10 @@ func (e *Engine) replaceOrder(ob *OrderBook, order *Order, newID uint64, newPric
 		if order.IsLimit() {
 			e.handleUpdatePriceLevel(ob, priceLevelUpdate)
 		}
-
-	} else {
-		// Call the corresponding handler
-		e.handler.OnDeleteOrder(ob, order)
-
-		// Release the order
-		ob.allocator.PutOrder(order)
 	}
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -569,7 +750,8 @@ func (e *Engine) replaceOrder(ob *OrderBook, order *Order, newID uint64, newPric
 // Deleting orders
 ////////////////////////////////////////////////////////////////
 
-func (e *Engine) deleteOrder(ob *OrderBook, order *Order, recursive bool) error {
+// deleteOrder deletes the order from the order book for the reason, zero reason means the order is fully executed.
+func (e *Engine) deleteOrder(ob *OrderBook, order *Order, reason OrderReason, recursive bool) error {
 	// Delete the order from the order book
 	if order.priceLevel != nil {
 		priceLevelUpdate, err := ob.deleteOrder(ob.treeForOrder(order), order)
@@ -583,7 +765,7 @@ func (e *Engine) deleteOrder(ob *OrderBook, order *Order, recursive bool) error
 	}
 
 	// Call the corresponding handler
-	e.handler.OnDeleteOrder(ob, order)
+	e.handleDeleteOrder(ob, order, reason)
 
 	// Erase the order
 	ob.orders.Delete(order.id)
@@ -592,7 +774,7 @@ func (e *Engine) deleteOrder(ob *OrderBook, order *Order, recursive bool) error
 	ob.allocator.PutOrder(order)
 
 	// Automatic order matching
-	if e.matching && !recursive {
+	if ob.isMatching() && !recursive {
 		err := e.match(ob)
 		if err != nil {
 			return fmt.Errorf("failed to match: %w", err)
@@ -602,6 +784,110 @@ func (e *Engine) deleteOrder(ob *OrderBook, order *Order, recursive bool) error
 	return nil
 }
 
+// checkPostOnly checks if the post-only order would take liquidity from the order book.
+// Such order is either canceled or repriced to one price step away from the opposite top
+// of the book according to its time in force. Returns false if the order is canceled.
+func (e *Engine) checkPostOnly(ob *OrderBook, order *Order) (bool, error) {
+	if !order.IsPostOnly() {
+		return true, nil
+	}
+
+	// Find the opposite top of the book
+	var top Uint
+	if order.IsBuy() {
+		if ob.TopAsk() == nil {
+			return true, nil
+		}
+		top = ob.TopAsk().Value().Price()
+	} else {
+		if ob.TopBid() == nil {
+			return true, nil
+		}
+		top = ob.TopBid().Value().Price()
+	}
+	if order.IsEndByPrice(top) {
+		return true, nil
+	}
+
+	if order.timeInForce == OrderTimeInForcePostOnlySlide {
+		// Calculate the price one step away from the opposite top
+		step, limits := ob.symbol.priceLimits.Step, ob.symbol.priceLimits
+		var price Uint
+		slid := false
+		if order.IsBuy() {
+			if top.GreaterThan(step) {
+				price = top.Sub(step)
+				slid = price.GreaterThanOrEqualTo(limits.Min)
+			}
+		} else {
+			if top.LessThanOrEqualTo(limits.Max.Sub(step)) {
+				price = top.Add(step)
+				slid = true
+			}
+		}
+
+		// Slid price should be within price bands, otherwise the order is canceled as post-only one
+		if slid && ob.priceInBands(price) {
+			order.price = price
+			order.reason = OrderReasonPostOnlySlide
+			e.handleUpdateOrder(ob, order)
+			order.reason = OrderReasonNone
+			return true, nil
+		}
+	}
+
+	return false, e.cancelOrder(ob, order, OrderReasonPostOnly)
+}
+
+// cancelOrder deletes the order with its linked order by the engine itself for the given reason.
+func (e *Engine) cancelOrder(ob *OrderBook, order *Order, reason OrderReason) error {
+	err := e.deleteLinkedOrder(ob, order, true)
+	if err != nil {
+		return fmt.Errorf("failed to delete linked order (id: %d): %w", order.ID(), err)
+	}
+
+	return e.deleteOrder(ob, order, reason, true)
+}
+
+// handleAddOrder stamps the order accepted at the order book time and calls the corresponding handler.
+func (e *Engine) handleAddOrder(ob *OrderBook, order *Order) {
+	order.acceptTime = ob.now
+	order.updateTime = ob.now
+	e.handler.OnAddOrder(ob, order)
+}
+
+// handleUpdateOrder stamps the order updated at the order book time and calls the corresponding handler.
+func (e *Engine) handleUpdateOrder(ob *OrderBook, order *Order) {
+	order.updateTime = ob.now
+	e.handler.OnUpdateOrder(ob, order)
+}
+
+// handleDeleteOrder sets the final status of the order deleted for the reason and calls the corresponding handler,
+// the reject handler is called too if the order is rejected by matching.
+func (e *Engine) handleDeleteOrder(ob *OrderBook, order *Order, reason OrderReason) {
+	order.reason = reason
+	order.status = reason.deletedStatus()
+	order.updateTime = ob.now
+	e.handler.OnDeleteOrder(ob, order)
+
+	if err := reason.rejectError(); err != nil {
+		e.handler.OnRejectOrder(ob, order, err)
+	}
+}
+
+// remainderReason returns the reason of deleting the rest of the order which is not executed immediately.
+func remainderReason(ob *OrderBook, order *Order) OrderReason {
+	switch {
+	case order.IsFOK():
+		return OrderReasonFOKUnfillable
+	case order.IsLockingBase() && order.available.LessThan(ob.symbol.lotSizeLimits.Step),
+		order.IsLockingQuote() && order.available.LessThan(ob.symbol.quoteLotSizeLimits.Step):
+		return OrderReasonInsufficientLocked
+	default:
+		return OrderReasonIOCRemainder
+	}
+}
+
 // Checks linked OCO order and deletes if it exists
 func (e *Engine) deleteLinkedOrder(ob *OrderBook, order *Order, recursive bool) error {
 	if order.linkedOrderID == 0 {
@@ -618,7 +904,7 @@ func (e *Engine) deleteLinkedOrder(ob *OrderBook, order *Order, recursive bool)
 		// reset link
 		order.linkedOrderID = 0
 
-		return e.deleteOrder(ob, linkedOrder, recursive)
+		return e.deleteOrder(ob, linkedOrder, OrderReasonOCOSibling, recursive)
 	}
 
 	return nil
@@ -633,13 +919,18 @@ func (e *Engine) cutRemainders(ob *OrderBook, order *Order) bool {
 	case
 		// Check rest quantities.
 		!restQuantity.IsZero() && restQuantity.LessThan(ob.symbol.lotSizeLimits.Step),
-		!restQuoteQuantity.IsZero() && restQuoteQuantity.LessThan(ob.symbol.quoteLotSizeLimits.Step),
+		!restQuoteQuantity.IsZero() && restQuoteQuantity.LessThan(ob.symbol.quoteLotSizeLimits.Step):
+
+		// Delete order.
+		e.deleteOrder(ob, order, OrderReasonDustRemainder, true)
+		executed = true
+	case
 		// Check locked quantities.
 		order.IsLockingBase() && order.Available().LessThan(ob.symbol.lotSizeLimits.Step),
 		order.IsLockingQuote() && order.Available().LessThan(ob.symbol.quoteLotSizeLimits.Step):
 
 		// Delete order.
-		e.deleteOrder(ob, order, true)
+		e.deleteOrder(ob, order, OrderReasonInsufficientLocked, true)
 		executed = true
 	}
 
@@ -675,6 +966,17 @@ func calcRestAvailableQuantities(order *Order, price Uint) (Uint, Uint) {
 	return restQuantity, restQuoteQuantity
 }
 
+// calcMakerQuantities returns quantities of the resting maker order which can be executed at the price.
+// Iceberg orders are executed only by the rest of their visible slices.
+func calcMakerQuantities(order *Order, price Uint) (Uint, Uint) {
+	qty, quoteQty := calcRestAvailableQuantities(order, price)
+	if order.IsIceberg() && order.priceLevel != nil && order.visible.LessThan(qty) {
+		qty = order.visible
+		quoteQty = qty.Mul(price).Div64(UintPrecision)
+	}
+	return qty, quoteQty
+}
+
 func calcQuantitiesFromQuoteAndPrice(quoteQuantity Uint, price Uint) (Uint, Uint) {
 	quantity, _ := quoteQuantity.Mul64(UintPrecision).QuoRem(price)
 	return quantity, quoteQuantity
@@ -10,21 +10,48 @@ var (
 	ErrOrderBookNotFound         = errors.New("order book is not found")
 	ErrOrderDuplicate            = errors.New("order is duplicated")
 	ErrOrderNotFound             = errors.New("order is not found")
-	ErrPriceLevelDuplicate       = errors.New("price level is duplicated")
-	ErrPriceLevelNotFound        = errors.New("price level is not found")
 	ErrInvalidSymbol             = errors.New("invalid symbol")
 	ErrInvalidOrderID            = errors.New("invalid order id")
 	ErrInvalidOrderSide          = errors.New("invalid order side")
 	ErrInvalidOrderDirection     = errors.New("invalid order direction")
 	ErrInvalidOrderType          = errors.New("invalid order type")
+	ErrInvalidOrderTimeInForce   = errors.New("invalid order time in force")
 	ErrInvalidOrderPrice         = errors.New("invalid order price")
 	ErrInvalidOrderStopPrice     = errors.New("invalid order stop price")
+	ErrInvalidOrderExpireTime    = errors.New("invalid order expire time")
 	ErrInvalidOrderQuantity      = errors.New("invalid order quantity")
 	ErrInvalidOrderQuoteQuantity = errors.New("invalid order quote quantity")
+	ErrInvalidIcebergVariance    = errors.New("invalid iceberg variance")
+	ErrInvalidOrderPeg           = errors.New("invalid pegged order")
+	ErrInvalidOrderMinQuantity   = errors.New("invalid order minimum quantity")
+	ErrInvalidOrderAllOrNone     = errors.New("invalid all-or-none order")
+	ErrPegPriceNotAvailable      = errors.New("pegged order price is not available")
 	ErrInvalidMarketSlippage     = errors.New("invalid market slippage")
 	ErrForbiddenManualExecution  = errors.New("manual execution is forbidden for automatically matching engine")
-	ErrOrderTreeNotFound         = errors.New("order tree not found")
 	ErrNotEnoughLockedAmount     = errors.New("not enough locked amount for order")
+	ErrOrderExpired              = errors.New("order is expired")
+	ErrInvalidSnapshot           = errors.New("invalid snapshot")
+	ErrInvalidJournal            = errors.New("invalid journal")
+	ErrJournalTruncated          = errors.New("journal is truncated")
+	ErrQueueFull                 = errors.New("order book task queue is full")
+	ErrPostOnlyWouldTake         = errors.New("post-only order would take liquidity")
+	ErrFOKUnfillable             = errors.New("fill-or-kill order cannot be fully executed")
+
+	// Trading state
+	ErrInvalidTradingState   = errors.New("invalid trading state")
+	ErrForbiddenTradingState = errors.New("operation is forbidden in the current trading state")
+	ErrAuctionStarted        = errors.New("auction is already started")
+	ErrAuctionNotStarted     = errors.New("auction is not started")
+
+	// Price bands
+	ErrOrderPriceOutOfBand = errors.New("order price is out of price band")
+
+	// Matching policy
+	ErrInvalidMatchingPolicy     = errors.New("invalid matching policy")
+	ErrUnsupportedMatchingPolicy = errors.New("matching policy is not supported by encoding")
+
+	// Self-trade prevention
+	ErrInvalidSelfTradePrevention = errors.New("invalid self-trade prevention mode")
 
 	// OCO
 	ErrBuyOCOStopPriceLessThanMarketPrice     = errors.New("stop price must be greater than market price (buy OCO order)")
@@ -41,5 +68,29 @@ var (
 	ErrSLNotZeroLocked                       = errors.New("stop limit order locked must be zero: locked amount must be in take profit")
 
 	// Internal Errors
-	ErrInternalExecutingOrderNotExecuted = errors.New("internal matching error: executing order not executed")
+	ErrPriceLevelDuplicate               = newInternalError("price level is duplicated")
+	ErrPriceLevelNotFound                = newInternalError("price level is not found")
+	ErrOrderTreeNotFound                 = newInternalError("order tree not found")
+	ErrInternalExecutingOrderNotExecuted = newInternalError("internal matching error: executing order not executed")
 )
+
+// InternalError is an error caused by broken invariants of the engine state.
+// Unlike other errors it is never caused by the rejected command itself.
+type InternalError struct {
+	msg string
+}
+
+func newInternalError(msg string) error {
+	return &InternalError{msg: msg}
+}
+
+// Error implements error interface.
+func (e *InternalError) Error() string {
+	return e.msg
+}
+
+// IsInternalError returns true if the error is caused by broken invariants of the engine state.
+func IsInternalError(err error) bool {
+	var internal *InternalError
+	return errors.As(err, &internal)
+}
@@ -0,0 +1,403 @@
+package matching
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+////////////////////////////////////////////////////////////////
+// Event sequence
+////////////////////////////////////////////////////////////////
+
+// EventSequence returns the number of the last event passed to the handler.
+// Every event (including rejects of invalid orders and errors) gets the next number of the engine-wide
+// gap-free sequence, so the handler can call the method to get the number of the handled event
+// or implement SequenceHandler to receive numbers with events.
+// Events are passed in the order commands are accepted, events of engine-wide commands are passed
+// in the order of symbol IDs, so numbers are the same in both modes and for the replayed journal.
+// In multithread mode order books perform tasks concurrently, but buffer their events and pass them
+// after the task in the turn of the command, so events are passed one by one.
+// The sequence number is stored in snapshots.
+func (e *Engine) EventSequence() uint64 {
+	return atomic.LoadUint64(&e.eventSequence)
+}
+
+////////////////////////////////////////////////////////////////
+// Events
+////////////////////////////////////////////////////////////////
+
+// eventKind is the kind of the handler event.
+type eventKind uint8
+
+const (
+	eventKindAddOrderBook eventKind = iota + 1
+	eventKindUpdateOrderBook
+	eventKindDeleteOrderBook
+	eventKindAddPriceLevel
+	eventKindUpdatePriceLevel
+	eventKindDeletePriceLevel
+	eventKindAddOrder
+	eventKindActivateOrder
+	eventKindUpdateOrder
+	eventKindDeleteOrder
+	eventKindRejectOrder
+	eventKindExecuteOrder
+	eventKindExecuteTrade
+	eventKindError
+)
+
+// event is the handler event with its arguments.
+type event struct {
+	kind          eventKind
+	orderBook     *OrderBook
+	update        PriceLevelUpdate
+	order         *Order
+	orderID       uint64
+	price         Uint
+	quantity      Uint
+	quoteQuantity Uint
+	trade         Trade
+	err           error
+}
+
+// buffered returns the event which can be passed after the order is changed by the order book.
+func (ev event) buffered() event {
+	if ev.order != nil {
+		view := ev.order.view()
+		ev.order = &view
+	}
+	return ev
+}
+
+// pass calls the handler method corresponding to the event,
+// trades are passed to the trade handler instead if it is not nil.
+func (ev *event) pass(handler Handler, tradeHandler TradeHandler) {
+	switch ev.kind {
+	case eventKindAddOrderBook:
+		handler.OnAddOrderBook(ev.orderBook)
+	case eventKindUpdateOrderBook:
+		handler.OnUpdateOrderBook(ev.orderBook)
+	case eventKindDeleteOrderBook:
+		handler.OnDeleteOrderBook(ev.orderBook)
+	case eventKindAddPriceLevel:
+		handler.OnAddPriceLevel(ev.orderBook, ev.update)
+	case eventKindUpdatePriceLevel:
+		handler.OnUpdatePriceLevel(ev.orderBook, ev.update)
+	case eventKindDeletePriceLevel:
+		handler.OnDeletePriceLevel(ev.orderBook, ev.update)
+	case eventKindAddOrder:
+		handler.OnAddOrder(ev.orderBook, ev.order)
+	case eventKindActivateOrder:
+		handler.OnActivateOrder(ev.orderBook, ev.order)
+	case eventKindUpdateOrder:
+		handler.OnUpdateOrder(ev.orderBook, ev.order)
+	case eventKindDeleteOrder:
+		handler.OnDeleteOrder(ev.orderBook, ev.order)
+	case eventKindRejectOrder:
+		handler.OnRejectOrder(ev.orderBook, ev.order, ev.err)
+	case eventKindExecuteOrder:
+		handler.OnExecuteOrder(ev.orderBook, ev.orderID, ev.price, ev.quantity, ev.quoteQuantity)
+	case eventKindExecuteTrade:
+		if tradeHandler != nil {
+			tradeHandler.OnTrade(ev.orderBook, ev.trade)
+			return
+		}
+		handler.OnExecuteTrade(
+			ev.orderBook, ev.trade.makerOrderUpdate(), ev.trade.takerOrderUpdate(),
+			ev.trade.Price, ev.trade.Quantity, ev.trade.QuoteQuantity,
+		)
+	case eventKindError:
+		handler.OnError(ev.orderBook, ev.err)
+	}
+}
+
+////////////////////////////////////////////////////////////////
+// Event handler
+////////////////////////////////////////////////////////////////
+
+// eventHandler implements Handler and TradeHandler emitting events with the given function.
+type eventHandler struct {
+	emit func(ev event)
+}
+
+func (h eventHandler) OnAddOrderBook(orderBook *OrderBook) {
+	h.emit(event{kind: eventKindAddOrderBook, orderBook: orderBook})
+}
+
+func (h eventHandler) OnUpdateOrderBook(orderBook *OrderBook) {
+	h.emit(event{kind: eventKindUpdateOrderBook, orderBook: orderBook})
+}
+
+func (h eventHandler) OnDeleteOrderBook(orderBook *OrderBook) {
+	h.emit(event{kind: eventKindDeleteOrderBook, orderBook: orderBook})
+}
+
+func (h eventHandler) OnAddPriceLevel(orderBook *OrderBook, update PriceLevelUpdate) {
+	h.emit(event{kind: eventKindAddPriceLevel, orderBook: orderBook, update: update})
+}
+
+func (h eventHandler) OnUpdatePriceLevel(orderBook *OrderBook, update PriceLevelUpdate) {
+	h.emit(event{kind: eventKindUpdatePriceLevel, orderBook: orderBook, update: update})
+}
+
+func (h eventHandler) OnDeletePriceLevel(orderBook *OrderBook, update PriceLevelUpdate) {
+	h.emit(event{kind: eventKindDeletePriceLevel, orderBook: orderBook, update: update})
+}
+
+func (h eventHandler) OnAddOrder(orderBook *OrderBook, order *Order) {
+	h.emit(event{kind: eventKindAddOrder, orderBook: orderBook, order: order})
+}
+
+func (h eventHandler) OnActivateOrder(orderBook *OrderBook, order *Order) {
+	h.emit(event{kind: eventKindActivateOrder, orderBook: orderBook, order: order})
+}
+
+func (h eventHandler) OnUpdateOrder(orderBook *OrderBook, order *Order) {
+	h.emit(event{kind: eventKindUpdateOrder, orderBook: orderBook, order: order})
+}
+
+func (h eventHandler) OnDeleteOrder(orderBook *OrderBook, order *Order) {
+	h.emit(event{kind: eventKindDeleteOrder, orderBook: orderBook, order: order})
+}
+
+func (h eventHandler) OnRejectOrder(orderBook *OrderBook, order *Order, reason error) {
+	h.emit(event{kind: eventKindRejectOrder, orderBook: orderBook, order: order, err: reason})
+}
+
+func (h eventHandler) OnExecuteOrder(orderBook *OrderBook, orderID uint64, price Uint, quantity Uint, quoteQuantity Uint) {
+	h.emit(event{
+		kind:          eventKindExecuteOrder,
+		orderBook:     orderBook,
+		orderID:       orderID,
+		price:         price,
+		quantity:      quantity,
+		quoteQuantity: quoteQuantity,
+	})
+}
+
+func (h eventHandler) OnExecuteTrade(orderBook *OrderBook, makerOrderUpdate OrderUpdate, takerOrderUpdate OrderUpdate, price Uint, quantity Uint, quoteQuantity Uint) {
+	h.OnTrade(orderBook, Trade{
+		MakerOrderID:  makerOrderUpdate.ID,
+		TakerOrderID:  takerOrderUpdate.ID,
+		Price:         price,
+		Quantity:      quantity,
+		QuoteQuantity: quoteQuantity,
+	})
+}
+
+func (h eventHandler) OnTrade(orderBook *OrderBook, trade Trade) {
+	h.emit(event{kind: eventKindExecuteTrade, orderBook: orderBook, trade: trade})
+}
+
+func (h eventHandler) OnError(orderBook *OrderBook, err error) {
+	h.emit(event{kind: eventKindError, orderBook: orderBook, err: err})
+}
+
+////////////////////////////////////////////////////////////////
+// Event turns
+////////////////////////////////////////////////////////////////
+
+// eventTurns orders passing of events by tickets taken in the order commands are accepted.
+type eventTurns struct {
+	mx         sync.Mutex
+	cond       sync.Cond
+	taken      uint64                   // number of taken tickets
+	turn       uint64                   // ticket allowed to pass its events
+	pending    map[uint64]pendingEvents // events passed later by the goroutine finishing the previous turn
+	counts     map[*OrderBook]int       // number of pending turns of each order book
+	maxPending int                      // maximum number of pending turns of the order book
+	stopped    bool
+}
+
+// pendingEvents are events of the order book waiting for their turn.
+type pendingEvents struct {
+	orderBook *OrderBook
+	pass      func()
+}
+
+// take returns the next ticket.
+// NOTE: Should be called with locked commands.
+func (t *eventTurns) take() uint64 {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.taken++
+	return t.taken - 1
+}
+
+// wait waits for the turn of the ticket, returns false if turns are stopped.
+func (t *eventTurns) wait(ticket uint64) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for t.turn != ticket && !t.stopped {
+		t.cond.Wait()
+	}
+	return !t.stopped
+}
+
+// done finishes the current turn and passes pending events of next turns.
+func (t *eventTurns) done() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.turn++
+	for !t.stopped {
+		events, ok := t.pending[t.turn]
+		if !ok {
+			break
+		}
+		delete(t.pending, t.turn)
+		if t.counts[events.orderBook]--; t.counts[events.orderBook] == 0 {
+			delete(t.counts, events.orderBook)
+		}
+		t.mx.Unlock()
+		events.pass()
+		t.mx.Lock()
+		t.turn++
+	}
+	t.cond.Broadcast()
+}
+
+// submit passes events of the order book in the turn of the ticket without waiting for it:
+// events are passed right away in the turn or later by the goroutine finishing the previous turn.
+// If the order book already has the maximum number of pending turns, it waits for the turn of the ticket.
+func (t *eventTurns) submit(ticket uint64, ob *OrderBook, pass func()) {
+	t.mx.Lock()
+	for t.turn != ticket && !t.stopped && t.counts[ob] >= t.maxPending {
+		t.cond.Wait()
+	}
+	if t.stopped {
+		t.mx.Unlock()
+		return
+	}
+	if t.turn != ticket {
+		if t.pending == nil {
+			t.pending = make(map[uint64]pendingEvents)
+			t.counts = make(map[*OrderBook]int)
+		}
+		t.pending[ticket] = pendingEvents{orderBook: ob, pass: pass}
+		t.counts[ob]++
+		t.mx.Unlock()
+		return
+	}
+	t.mx.Unlock()
+
+	pass()
+	t.done()
+}
+
+// stop releases all waiting goroutines, events of further turns are not passed.
+func (t *eventTurns) stop() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.stopped = true
+	t.cond.Broadcast()
+}
+
+// reset starts turns with the next ticket.
+func (t *eventTurns) reset() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.turn = t.taken
+	t.pending = nil
+	t.counts = nil
+	t.stopped = false
+}
+
+////////////////////////////////////////////////////////////////
+// Passing events
+////////////////////////////////////////////////////////////////
+
+// emitEvent passes the event of the order book to the handler. In multithread mode the event
+// is buffered by the order book and passed after its task in the turn of the command.
+func (e *Engine) emitEvent(ev event) {
+	if e.multithread {
+		ev.orderBook.events = append(ev.orderBook.events, ev.buffered())
+		return
+	}
+	e.passEvent(&ev)
+}
+
+// passEvent assigns the next sequence number to the event and passes it to the handler.
+func (e *Engine) passEvent(ev *event) {
+	sequence := atomic.AddUint64(&e.eventSequence, 1)
+	if e.sequenceTarget != nil {
+		e.sequenceTarget.OnSequence(sequence)
+	}
+	ev.pass(e.target, e.tradeTarget)
+}
+
+// takeEventTurn returns the ticket of the turn events of the accepted command are passed in.
+// NOTE: Should be called with locked commands.
+func (e *Engine) takeEventTurn() uint64 {
+	if !e.multithread {
+		return 0
+	}
+	return e.events.take()
+}
+
+// inEventTurn calls the function after events of all previously accepted commands are passed.
+// In multithread mode the function is not called if the engine is forcibly stopped.
+func (e *Engine) inEventTurn(ticket uint64, fn func()) {
+	if !e.multithread {
+		fn()
+		return
+	}
+	if e.events.wait(ticket) {
+		fn()
+		e.events.done()
+	}
+}
+
+// passOrderBookEvents passes events buffered by the order book in the turn of the ticket.
+// It does not wait for the turn, so order books do not wait for each other: buffered events are copied
+// and passed right away or later by the goroutine finishing the previous turn. The order book waits
+// for the turn only if events of the maximum number of its tasks are pending.
+func (e *Engine) passOrderBookEvents(ob *OrderBook, ticket uint64) {
+	events := slices.Clone(ob.events)
+	clear(ob.events)
+	ob.events = ob.events[:0]
+	e.events.submit(ticket, ob, func() {
+		for i := range events {
+			e.passEvent(&events[i])
+		}
+	})
+}
+
+// eventTask wraps the order book task of the accepted command, so its events are passed in the turn of the command.
+// NOTE: Should be called with locked commands.
+func (e *Engine) eventTask(task func(ob *OrderBook) error) func(ob *OrderBook) error {
+	if !e.multithread {
+		return task
+	}
+
+	ticket := e.takeEventTurn()
+	return func(ob *OrderBook) error {
+		err := task(ob)
+		e.passOrderBookEvents(ob, ticket)
+		return err
+	}
+}
+
+// scheduleEvents schedules events emitted by the function outside of order book tasks
+// (the order book could be nil), so they are passed in the turn of the accepted command.
+// Returned function emits events, in multithread mode it does not wait for the turn
+// unless events of the maximum number of such commands are pending.
+// NOTE: Should be called with locked commands.
+func (e *Engine) scheduleEvents(emit func(handler Handler)) func() {
+	if !e.multithread {
+		return func() { emit(e.handler) }
+	}
+
+	ticket := e.takeEventTurn()
+	return func() {
+		var events []event
+		emit(eventHandler{emit: func(ev event) {
+			events = append(events, ev.buffered())
+		}})
+		e.events.submit(ticket, nil, func() {
+			for i := range events {
+				e.passEvent(&events[i])
+			}
+		})
+	}
+}
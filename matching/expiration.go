@@ -0,0 +1,134 @@
+package matching
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiration is the scheduled expiration of GTD order.
+type expiration struct {
+	time    int64 // unix time in nanoseconds
+	orderID uint64
+}
+
+// expirationQueue is a min-heap of scheduled expirations ordered by time and order ID.
+// Each order has at most one entry, it is removed when the order is deleted from the order book.
+type expirationQueue struct {
+	items     []expiration
+	scheduled map[uint64]int // heap index of the scheduled expiration by order ID
+}
+
+func (q *expirationQueue) Len() int { return len(q.items) }
+
+func (q *expirationQueue) Less(i, j int) bool {
+	if q.items[i].time != q.items[j].time {
+		return q.items[i].time < q.items[j].time
+	}
+	return q.items[i].orderID < q.items[j].orderID
+}
+
+func (q *expirationQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.scheduled[q.items[i].orderID] = i
+	q.scheduled[q.items[j].orderID] = j
+}
+
+func (q *expirationQueue) Push(x any) {
+	item := x.(expiration)
+	q.scheduled[item.orderID] = len(q.items)
+	q.items = append(q.items, item)
+}
+
+func (q *expirationQueue) Pop() any {
+	n := len(q.items)
+	item := q.items[n-1]
+	q.items = q.items[:n-1]
+	delete(q.scheduled, item.orderID)
+	return item
+}
+
+// schedule schedules the expiration of GTD order or moves the already scheduled one.
+func (q *expirationQueue) schedule(order *Order) {
+	if !order.IsGTD() {
+		return
+	}
+	if q.scheduled == nil {
+		q.scheduled = make(map[uint64]int)
+	}
+	t := order.expireTime.UnixNano()
+	if i, ok := q.scheduled[order.id]; ok {
+		if q.items[i].time != t {
+			q.items[i].time = t
+			heap.Fix(q, i)
+		}
+		return
+	}
+	heap.Push(q, expiration{time: t, orderID: order.id})
+}
+
+// remove removes the scheduled expiration of the order if any.
+func (q *expirationQueue) remove(orderID uint64) {
+	if i, ok := q.scheduled[orderID]; ok {
+		heap.Remove(q, i)
+	}
+}
+
+// first returns the time of the earliest scheduled expiration.
+func (q *expirationQueue) first() (int64, bool) {
+	if len(q.items) == 0 {
+		return 0, false
+	}
+	return q.items[0].time, true
+}
+
+// next removes and returns ID of the next order scheduled to expire at the given time.
+func (q *expirationQueue) next(now time.Time) (uint64, bool) {
+	if t, ok := q.first(); !ok || t > now.UnixNano() {
+		return 0, false
+	}
+	return heap.Pop(q).(expiration).orderID, true
+}
+
+////////////////////////////////////////////////////////////////
+
+// expireOrders deletes GTD orders of the order book expired at the order book time.
+func (e *Engine) expireOrders(ob *OrderBook) error {
+	for {
+		orderID, ok := ob.expirations.next(ob.now)
+		if !ok {
+			return nil
+		}
+
+		// Order could be changed since scheduled
+		order := ob.Order(orderID)
+		if order == nil || !order.isExpired(ob.now) {
+			continue
+		}
+
+		if err := e.cancelOrder(ob, order, OrderReasonExpired); err != nil {
+			return err
+		}
+	}
+}
+
+// expireOrdersInBackground performs ExpireOrders() without blocking the caller,
+// so the task loop of the order book could request it when the clock reaches the earliest expiration.
+// The command is journaled as usual, it waits for the journal replay in progress
+// and it is not performed once the engine is stopped.
+func (e *Engine) expireOrdersInBackground() {
+	go func() {
+		e.mxReplay.Lock()
+		defer e.mxReplay.Unlock()
+
+		e.lockCommands()
+		if e.stopped {
+			e.unlockCommands()
+			return
+		}
+		e.background.Add(1)
+		e.unlockCommands()
+		defer e.background.Done()
+
+		e.ExpireOrders()
+	}()
+}
@@ -1,6 +1,18 @@
 package matching
 
-//go:generate mockgen -destination=mocks/interfaces.go -package=mockmatching . Handler
+//go:generate mockgen -destination=mocks/interfaces.go -package=mockmatching . Handler,TradeHandler,SequenceHandler
+
+// Handler receives events of the engine. Each event gets the next number of the engine-wide sequence,
+// the number of the handled event is passed to SequenceHandler or returned by Engine.EventSequence().
+// Events are handled one by one in the order commands are accepted, so handler methods are never called concurrently.
+// NOTE: In single-thread mode events are passed from the goroutine performing the command and orders passed
+// to the handler are orders of the order book, they should not be retained after the handler returns.
+// In multithread mode order books buffer events while they perform tasks and events are passed from whichever
+// goroutine holds the turn of the command: the goroutine of the order book, the goroutine of another order book
+// finishing the previous turn or the caller of the command. Orders passed to the handler are copies made when
+// events are emitted, but the order book itself could be changed by further commands while its events are handled.
+// The slow handler holds up order books: each of them waits for the turn before the next task when events
+// of the maximum number of its tasks are pending (see WithMaxPendingEvents()).
 type Handler interface {
 
 	// Order book handlers
@@ -19,10 +31,29 @@ type Handler interface {
 	OnUpdateOrder(orderBook *OrderBook, order *Order)
 	OnDeleteOrder(orderBook *OrderBook, order *Order)
 
+	// Rejected orders handler, called for new orders rejected with the reason before they are added
+	// (order book is nil if it is not found). Errors of orders rejected by the order book
+	// are passed to OnError() handler too. Post-only and fill-or-kill orders rejected by matching
+	// are passed after OnDeleteOrder() with the order in the rejected status.
+	OnRejectOrder(orderBook *OrderBook, order *Order, reason error)
+
 	// Matching handlers
 	OnExecuteOrder(orderBook *OrderBook, orderID uint64, price Uint, quantity Uint, quoteQuantity Uint)
 	OnExecuteTrade(orderBook *OrderBook, makerOrderUpdate OrderUpdate, takerOrderUpdate OrderUpdate, price Uint, quantity Uint, quoteQuantity Uint)
 
-	// Errors handler
+	// Errors handler (order book is nil for errors not related to the single order book)
 	OnError(orderBook *OrderBook, err error)
 }
+
+// TradeHandler is the optional interface of the handler receiving trades with IDs, time, sides and owners
+// of orders. If the handler implements it, OnTrade() is called instead of Handler.OnExecuteTrade().
+type TradeHandler interface {
+	OnTrade(orderBook *OrderBook, trade Trade)
+}
+
+// SequenceHandler is the optional interface of the handler receiving numbers of the engine-wide gap-free sequence.
+// If the handler implements it, OnSequence() is called with the number of each event right before the event
+// is passed to the handler from the same goroutine, so consumers can detect gaps and deduplicate events.
+type SequenceHandler interface {
+	OnSequence(sequence uint64)
+}
@@ -0,0 +1,311 @@
+package matching
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// maxJournalRecordSize limits the size of the single journal record to detect corrupted journals.
+const maxJournalRecordSize = 1 << 20
+
+// Journal is a write-ahead log of commands changing the engine state.
+// Each command is appended to the journal before it is performed by the engine,
+// so the engine state can be rebuilt later with Replay() method.
+// If the underlying writer has Sync() method (like *os.File) it is called
+// after every appended command to guarantee durability.
+// NOTE: Journal is thread-safe.
+type Journal struct {
+	mx      sync.Mutex
+	w       io.Writer
+	enc     *encoder
+	payload bytes.Buffer
+	penc    *encoder
+}
+
+// NewJournal creates and returns new Journal instance appending commands to the given writer.
+func NewJournal(w io.Writer) *Journal {
+	j := &Journal{
+		w:   w,
+		enc: newEncoder(w),
+	}
+	j.penc = newEncoder(&j.payload)
+	return j
+}
+
+// append writes the command as a single size prefixed record.
+func (j *Journal) append(cmd *command) error {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	j.payload.Reset()
+	j.penc.writeCommand(cmd)
+	if err := j.penc.flush(); err != nil {
+		return err
+	}
+
+	j.enc.writeUint32(uint32(j.payload.Len()))
+	j.enc.write(j.payload.Bytes())
+	if err := j.enc.flush(); err != nil {
+		return err
+	}
+
+	if s, ok := j.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+// Replaying journal
+////////////////////////////////////////////////////////////////
+
+// Replay reads commands from the journal and performs them in the same order,
+// so the handler receives the same sequence of callbacks as during the original run.
+// Commands of orders rejected by the validation are journaled as well, so rejects are replayed too.
+// Errors of appending commands to the journal are not replayed.
+// Commands already included into the restored snapshot are skipped, so the engine
+// can be recovered with Restore() of the latest snapshot followed by Replay() of the journal.
+// Replayed commands are not appended to the engine journal.
+// ErrJournalTruncated is returned if the last record is incomplete, all preceding
+// commands are performed in this case.
+// NOTE: In multithread mode replayed commands are performed asynchronously as usual.
+func (e *Engine) Replay(r io.Reader) error {
+	e.mxReplay.Lock()
+	defer e.mxReplay.Unlock()
+	defer e.setReplayed(nil)
+
+	dec := newDecoder(r)
+	var payload []byte
+	for {
+		size := dec.readUint32()
+		if dec.err != nil {
+			if errors.Is(dec.err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("%w: %w", ErrJournalTruncated, dec.err)
+		}
+
+		if size > maxJournalRecordSize {
+			return ErrInvalidJournal
+		}
+		if cap(payload) < int(size) {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		dec.read(payload)
+		if dec.err != nil {
+			return fmt.Errorf("%w: %w", ErrJournalTruncated, dec.err)
+		}
+
+		cmd, err := readCommand(newDecoder(bytes.NewReader(payload)))
+		if err != nil {
+			return err
+		}
+
+		// Commands included into the restored snapshot are already performed
+		if cmd.sequence <= e.restoredSequence {
+			continue
+		}
+
+		// Errors of replayed commands are the same as errors of the original ones
+		e.setReplayed(cmd)
+		_ = e.performJournaledCommand(cmd)
+		atomic.StoreUint64(&e.sequence, cmd.sequence)
+	}
+}
+
+// setReplayed sets the command replayed from the journal, commands read it with locked commands.
+func (e *Engine) setReplayed(cmd *command) {
+	e.lockCommands()
+	e.replayed = cmd
+	e.unlockCommands()
+}
+
+// performJournaledCommand calls the engine method corresponding to the command.
+func (e *Engine) performJournaledCommand(cmd *command) error {
+	switch cmd.kind {
+	case CommandKindAddOrderBook:
+		_, err := e.AddOrderBook(cmd.symbol, cmd.marketPrice, cmd.spModesConfig, WithMatchingPolicy(cmd.policy))
+		return err
+	case CommandKindDeleteOrderBook:
+		_, err := e.DeleteOrderBook(cmd.symbolID)
+		return err
+	case CommandKindSetIndexMarkPrices:
+		return e.SetIndexMarkPricesForOrderBook(cmd.symbolID, cmd.indexPrice, cmd.markPrice, cmd.iterate)
+	case CommandKindSetMarkPrice:
+		return e.SetMarkPriceForOrderBook(cmd.symbolID, cmd.markPrice, cmd.iterate)
+	case CommandKindSetIndexPrice:
+		return e.SetIndexPriceForOrderBook(cmd.symbolID, cmd.indexPrice, cmd.iterate)
+	case CommandKindAddOrder:
+		return e.AddOrder(cmd.order)
+	case CommandKindAddOrdersPair:
+		return e.AddOrdersPair(cmd.order, cmd.linkedOrder)
+	case CommandKindAddTPSL:
+		return e.AddTPSL(cmd.order, cmd.linkedOrder)
+	case CommandKindAddTPSLMarket:
+		return e.AddTPSLMarket(cmd.order, cmd.linkedOrder)
+	case CommandKindReduceOrder:
+		return e.ReduceOrder(cmd.symbolID, cmd.orderID, cmd.quantity)
+	case CommandKindModifyOrder:
+		return e.ModifyOrder(cmd.symbolID, cmd.orderID, cmd.price, cmd.quantity)
+	case CommandKindMitigateOrder:
+		return e.MitigateOrder(cmd.symbolID, cmd.orderID, cmd.price, cmd.quantity, cmd.amount)
+	case CommandKindReplaceOrder:
+		return e.ReplaceOrder(cmd.symbolID, cmd.orderID, cmd.newOrderID, cmd.price, cmd.quantity)
+	case CommandKindDeleteOrder:
+		return e.DeleteOrder(cmd.symbolID, cmd.orderID)
+	case CommandKindExecuteOrder:
+		return e.ExecuteOrder(cmd.symbolID, cmd.orderID, cmd.quantity)
+	case CommandKindExecuteOrderByPrice:
+		return e.ExecuteOrderByPrice(cmd.symbolID, cmd.orderID, cmd.price, cmd.quantity)
+	case CommandKindEnableMatching:
+		e.EnableMatching()
+	case CommandKindDisableMatching:
+		e.DisableMatching()
+	case CommandKindMatch:
+		e.Match()
+	case CommandKindExpireOrders:
+		e.ExpireOrders()
+	case CommandKindStartAuction:
+		return e.StartAuction(cmd.symbolID)
+	case CommandKindStopAuction:
+		return e.StopAuction(cmd.symbolID)
+	case CommandKindSetTradingState:
+		return e.SetTradingState(cmd.symbolID, cmd.tradingState)
+	case CommandKindSetMatchingPolicy:
+		return e.SetMatchingPolicy(cmd.symbolID, cmd.policy)
+	case CommandKindMassCancel:
+		_, err := e.MassCancel(cmd.filter)
+		return err
+	case CommandKindUpdateSymbol:
+		return e.UpdateSymbol(cmd.symbol)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+// Commands encoding
+////////////////////////////////////////////////////////////////
+
+func (enc *encoder) writeCommand(cmd *command) {
+	enc.writeUint8(uint8(cmd.kind))
+	enc.writeUint64(cmd.sequence)
+	enc.writeUint64(uint64(cmd.time))
+
+	switch cmd.kind {
+	case CommandKindAddOrderBook:
+		enc.writeSymbol(cmd.symbol)
+		enc.writeUint(cmd.marketPrice)
+		enc.writeStopPriceModeConfig(cmd.spModesConfig)
+		enc.writeMatchingPolicy(cmd.policy)
+		enc.writeUint64(cmd.seed)
+	case CommandKindDeleteOrderBook, CommandKindStartAuction, CommandKindStopAuction:
+		enc.writeUint32(cmd.symbolID)
+	case CommandKindSetTradingState:
+		enc.writeUint32(cmd.symbolID)
+		enc.writeUint8(uint8(cmd.tradingState))
+	case CommandKindSetMatchingPolicy:
+		enc.writeUint32(cmd.symbolID)
+		enc.writeMatchingPolicy(cmd.policy)
+	case CommandKindMassCancel:
+		enc.writeMassCancelFilter(cmd.filter)
+	case CommandKindUpdateSymbol:
+		enc.writeSymbol(cmd.symbol)
+	case CommandKindSetIndexMarkPrices, CommandKindSetMarkPrice, CommandKindSetIndexPrice:
+		enc.writeUint32(cmd.symbolID)
+		enc.writeUint(cmd.indexPrice)
+		enc.writeUint(cmd.markPrice)
+		enc.writeBool(cmd.iterate)
+	case CommandKindAddOrder:
+		enc.writeOrder(&cmd.order)
+	case CommandKindAddOrdersPair, CommandKindAddTPSL, CommandKindAddTPSLMarket:
+		enc.writeOrder(&cmd.order)
+		enc.writeOrder(&cmd.linkedOrder)
+	case
+		CommandKindReduceOrder,
+		CommandKindModifyOrder,
+		CommandKindMitigateOrder,
+		CommandKindReplaceOrder,
+		CommandKindDeleteOrder,
+		CommandKindExecuteOrder,
+		CommandKindExecuteOrderByPrice:
+		enc.writeUint32(cmd.symbolID)
+		enc.writeUint64(cmd.orderID)
+		enc.writeUint64(cmd.newOrderID)
+		enc.writeUint(cmd.price)
+		enc.writeUint(cmd.quantity)
+		enc.writeUint(cmd.amount)
+	}
+}
+
+func readCommand(dec *decoder) (*command, error) {
+	cmd := &command{
+		kind:     CommandKind(dec.readUint8()),
+		sequence: dec.readUint64(),
+		time:     int64(dec.readUint64()),
+	}
+
+	switch cmd.kind {
+	case CommandKindAddOrderBook:
+		cmd.symbol = dec.readSymbol()
+		cmd.symbolID = cmd.symbol.id
+		cmd.marketPrice = dec.readUint()
+		cmd.spModesConfig = dec.readStopPriceModeConfig()
+		cmd.policy = dec.readMatchingPolicy()
+		cmd.seed = dec.readUint64()
+	case CommandKindDeleteOrderBook, CommandKindStartAuction, CommandKindStopAuction:
+		cmd.symbolID = dec.readUint32()
+	case CommandKindSetTradingState:
+		cmd.symbolID = dec.readUint32()
+		cmd.tradingState = TradingState(dec.readUint8())
+	case CommandKindSetMatchingPolicy:
+		cmd.symbolID = dec.readUint32()
+		cmd.policy = dec.readMatchingPolicy()
+	case CommandKindMassCancel:
+		cmd.filter = dec.readMassCancelFilter()
+		cmd.symbolID = cmd.filter.SymbolID
+	case CommandKindUpdateSymbol:
+		cmd.symbol = dec.readSymbol()
+		cmd.symbolID = cmd.symbol.id
+	case CommandKindSetIndexMarkPrices, CommandKindSetMarkPrice, CommandKindSetIndexPrice:
+		cmd.symbolID = dec.readUint32()
+		cmd.indexPrice = dec.readUint()
+		cmd.markPrice = dec.readUint()
+		cmd.iterate = dec.readBool()
+	case CommandKindAddOrder:
+		dec.readOrder(&cmd.order)
+		cmd.symbolID = cmd.order.symbolID
+	case CommandKindAddOrdersPair, CommandKindAddTPSL, CommandKindAddTPSLMarket:
+		dec.readOrder(&cmd.order)
+		dec.readOrder(&cmd.linkedOrder)
+		cmd.symbolID = cmd.order.symbolID
+	case
+		CommandKindReduceOrder,
+		CommandKindModifyOrder,
+		CommandKindMitigateOrder,
+		CommandKindReplaceOrder,
+		CommandKindDeleteOrder,
+		CommandKindExecuteOrder,
+		CommandKindExecuteOrderByPrice:
+		cmd.symbolID = dec.readUint32()
+		cmd.orderID = dec.readUint64()
+		cmd.newOrderID = dec.readUint64()
+		cmd.price = dec.readUint()
+		cmd.quantity = dec.readUint()
+		cmd.amount = dec.readUint()
+	case CommandKindEnableMatching, CommandKindDisableMatching, CommandKindMatch, CommandKindExpireOrders:
+	default:
+		return nil, ErrInvalidJournal
+	}
+
+	if dec.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJournal, dec.err)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,191 @@
+package matching
+
+import (
+	"fmt"
+)
+
+// MassCancelFilter selects orders deleted by the mass cancel.
+// The order book is selected by its symbol ID or all order books are selected explicitly,
+// zero value of each other field matches all orders.
+type MassCancelFilter struct {
+	// Symbol ID of the order book, ignored if all order books are selected.
+	SymbolID uint32
+
+	// Select orders of all order books.
+	AllSymbols bool
+
+	// Side of orders, zero means both sides.
+	Side OrderSide
+
+	// Delete only not activated stop orders (including stop-limit and trailing stop orders).
+	StopOnly bool
+
+	// Price range of orders including bounds, zero max price means no upper bound.
+	// Limit orders are matched by their price and stop orders by their stop price.
+	MinPrice Uint
+	MaxPrice Uint
+
+	// Owner of orders, zero means all owners.
+	OwnerID uint64
+}
+
+// Valid returns true if the filter side is known.
+func (f MassCancelFilter) Valid() bool {
+	return f.Side == 0 || f.Side == OrderSideBuy || f.Side == OrderSideSell
+}
+
+// matches returns true if the order is selected by the filter.
+func (f MassCancelFilter) matches(order *Order) bool {
+	if f.Side != 0 && order.side != f.Side {
+		return false
+	}
+	if f.OwnerID != 0 && order.ownerID != f.OwnerID {
+		return false
+	}
+
+	stop := order.IsStop() || order.IsStopLimit() || order.IsTrailingStop() || order.IsTrailingStopLimit()
+	if f.StopOnly && !stop {
+		return false
+	}
+
+	price := order.price
+	if stop {
+		price = order.stopPrice
+	}
+	if price.LessThan(f.MinPrice) || !f.MaxPrice.IsZero() && price.GreaterThan(f.MaxPrice) {
+		return false
+	}
+
+	return true
+}
+
+// MassCancel deletes all orders selected by the filter and returns the number of deleted orders.
+// Orders of each order book are deleted by a single task and the order book is matched
+// only once after all deletions. Linked orders of deleted orders are deleted too.
+func (e *Engine) MassCancel(filter MassCancelFilter) (int, error) {
+	if !filter.Valid() {
+		return 0, ErrInvalidOrderSide
+	}
+
+	// Get the valid order book for the filter
+	if !filter.AllSymbols && e.OrderBook(filter.SymbolID) == nil {
+		return 0, ErrOrderBookNotFound
+	}
+
+	type result struct {
+		id      uint32
+		deleted *int
+		done    <-chan error
+	}
+
+	cmd := &command{
+		kind:     CommandKindMassCancel,
+		symbolID: filter.SymbolID,
+		filter:   filter,
+	}
+
+	// Schedule tasks with locked commands, so all order books perform the same journaled command
+	e.lockCommands()
+	if err := e.journalCommand(cmd); err != nil {
+		e.unlockCommands()
+		return 0, err
+	}
+	results := make([]result, 0, e.orderBooksCount)
+	enqueues := make([]func(), 0, e.orderBooksCount)
+	for i, c := 0, len(e.orderBooks); i < c; i++ {
+		if e.orderBooks[i] == nil || !filter.AllSymbols && uint32(i) != filter.SymbolID {
+			continue
+		}
+
+		deleted := new(int)
+		task := e.commandTask(cmd, func(ob *OrderBook) error {
+			var err error
+			*deleted, err = e.massCancel(ob, filter)
+			return err
+		})
+		enqueue, done := e.scheduleOrderBookTaskAsync(e.orderBooks[i], e.eventTask(func(ob *OrderBook) error {
+			err := task(ob)
+			if err != nil {
+				// Call the corresponding handler
+				e.handler.OnError(ob, err)
+			}
+			return err
+		}))
+		enqueues = append(enqueues, enqueue)
+		results = append(results, result{
+			id:      uint32(i),
+			deleted: deleted,
+			done:    done,
+		})
+	}
+	e.unlockCommands()
+
+	for _, enqueue := range enqueues {
+		enqueue()
+	}
+
+	count := 0
+	var err error
+	for _, r := range results {
+		// Wait for all tasks even if some of them failed
+		if taskErr := <-r.done; taskErr != nil && err == nil {
+			err = fmt.Errorf("failed to cancel orders of order book (id: %d): %w", r.id, taskErr)
+		}
+		count += *r.deleted
+	}
+
+	return count, err
+}
+
+// massCancel deletes orders of the order book selected by the filter in the price-time priority order.
+func (e *Engine) massCancel(ob *OrderBook, filter MassCancelFilter) (int, error) {
+	// Check the order book trading state
+	if !ob.state.canDeleteOrders() {
+		return 0, validationError(ErrForbiddenTradingState)
+	}
+
+	// Collect orders first, so trees are not changed while iterating
+	orders := make([]*Order, 0)
+	for _, tree := range ob.trees() {
+		tree.IterateInOrder(func(priceLevel *PriceLevelL3) bool {
+			it := priceLevel.Iterator()
+			for it.Next() {
+				if order := it.Current().Value; filter.matches(order) {
+					orders = append(orders, order)
+				}
+			}
+			return false
+		})
+	}
+
+	size := ob.Size()
+	for _, order := range orders {
+		// Order could be already deleted as the linked order
+		if ob.Order(order.id) != order {
+			continue
+		}
+
+		// Delete linked order if it exists
+		err := e.deleteLinkedOrder(ob, order, true)
+		if err != nil {
+			return size - ob.Size(), fmt.Errorf("failed to delete linked order (id: %d): %w", order.ID(), err)
+		}
+
+		// Delete the order
+		err = e.deleteOrder(ob, order, OrderReasonUserCancel, true)
+		if err != nil {
+			return size - ob.Size(), fmt.Errorf("failed to delete order (id: %d): %w", order.ID(), err)
+		}
+	}
+	deleted := size - ob.Size()
+
+	// Automatic order matching
+	if ob.isMatching() && deleted > 0 {
+		err := e.match(ob)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to match: %w", err)
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,193 @@
+package matching
+
+// MatchingPolicy allocates the taker quantity between resting orders of the single price level.
+// Price levels are always matched in the price priority, the policy defines the priority
+// of orders inside the price level. Order book without the policy uses strict price-time FIFO.
+type MatchingPolicy interface {
+	// Allocate returns quantities allocated to the given resting orders for the taker quantity.
+	// Resting orders are provided in the time priority with their executable quantities.
+	// Allocations are executed in the returned order and the total allocated quantity
+	// should not exceed the taker quantity. Allocated quantities are multiples of the lot size.
+	Allocate(orders []Allocation, quantity Uint, lotSize Uint) []Allocation
+}
+
+// Allocation contains the quantity allocated to the resting order.
+type Allocation struct {
+	Order    *Order
+	Quantity Uint
+}
+
+////////////////////////////////////////////////////////////////
+// FIFO
+////////////////////////////////////////////////////////////////
+
+// FIFOPolicy allocates the taker quantity to orders in the time priority (price-time FIFO).
+type FIFOPolicy struct{}
+
+// Allocate implements MatchingPolicy interface.
+func (FIFOPolicy) Allocate(orders []Allocation, quantity Uint, lotSize Uint) []Allocation {
+	allocated := newAllocations(orders)
+	allocated.fifo(quantity)
+	return allocated.result()
+}
+
+////////////////////////////////////////////////////////////////
+// Pro-rata
+////////////////////////////////////////////////////////////////
+
+// ProRataPolicy allocates the taker quantity to orders proportionally to their quantities.
+// Allocations less than the minimum allocation are dropped, and the quantity remaining
+// after rounding to the lot size is allocated in the time priority.
+type ProRataPolicy struct {
+	MinAllocation Uint
+}
+
+// Allocate implements MatchingPolicy interface.
+func (p ProRataPolicy) Allocate(orders []Allocation, quantity Uint, lotSize Uint) []Allocation {
+	allocated := newAllocations(orders)
+	quantity = allocated.proRata(quantity, lotSize, p.MinAllocation)
+	allocated.fifo(quantity)
+	return allocated.result()
+}
+
+////////////////////////////////////////////////////////////////
+// Hybrid
+////////////////////////////////////////////////////////////////
+
+// HybridPolicy allocates the taker quantity in the following steps:
+//  1. the market makers share is allocated to orders of designated market makers in the time priority;
+//  2. the FIFO share is allocated to all orders in the time priority;
+//  3. the rest of the quantity is allocated pro-rata (see ProRataPolicy).
+//
+// Shares are in percents with 0.01% precision (1 means 0.01%, 10000 means 100%).
+type HybridPolicy struct {
+	MarketMakers     []uint64 // owner IDs of designated market makers
+	MarketMakerShare uint64
+	FIFOShare        uint64
+	MinAllocation    Uint
+}
+
+// Valid returns true if shares of the policy are valid.
+func (p HybridPolicy) Valid() bool {
+	return p.MarketMakerShare+p.FIFOShare <= 10000
+}
+
+// Allocate implements MatchingPolicy interface.
+func (p HybridPolicy) Allocate(orders []Allocation, quantity Uint, lotSize Uint) []Allocation {
+	allocated := newAllocations(orders)
+
+	// Market makers share
+	if p.MarketMakerShare > 0 && len(p.MarketMakers) > 0 {
+		share := ApplySteps(quantity.Mul64(p.MarketMakerShare).Div64(10000), lotSize)
+		rest := allocated.fifoFiltered(share, func(order *Order) bool {
+			for _, ownerID := range p.MarketMakers {
+				if order.ownerID == ownerID {
+					return true
+				}
+			}
+			return false
+		})
+		quantity = quantity.Sub(share).Add(rest)
+	}
+
+	// FIFO share
+	if p.FIFOShare > 0 {
+		share := ApplySteps(quantity.Mul64(p.FIFOShare).Div64(10000), lotSize)
+		rest := allocated.fifo(share)
+		quantity = quantity.Sub(share).Add(rest)
+	}
+
+	// Pro-rata and the residual FIFO
+	quantity = allocated.proRata(quantity, lotSize, p.MinAllocation)
+	allocated.fifo(quantity)
+
+	return allocated.result()
+}
+
+////////////////////////////////////////////////////////////////
+// Allocation helpers
+////////////////////////////////////////////////////////////////
+
+// allocations accumulates quantities allocated to orders by several allocation steps.
+type allocations struct {
+	orders    []Allocation
+	allocated []Uint
+}
+
+func newAllocations(orders []Allocation) *allocations {
+	allocated := make([]Uint, len(orders))
+	for i := range allocated {
+		allocated[i] = NewZeroUint()
+	}
+	return &allocations{orders: orders, allocated: allocated}
+}
+
+// available returns the quantity of the order which is not allocated yet.
+func (a *allocations) available(i int) Uint {
+	return a.orders[i].Quantity.Sub(a.allocated[i])
+}
+
+// fifo allocates the quantity in the time priority and returns the unallocated rest.
+func (a *allocations) fifo(quantity Uint) Uint {
+	return a.fifoFiltered(quantity, func(*Order) bool { return true })
+}
+
+// fifoFiltered allocates the quantity to filtered orders in the time priority and returns the unallocated rest.
+func (a *allocations) fifoFiltered(quantity Uint, filter func(order *Order) bool) Uint {
+	for i := range a.orders {
+		if quantity.IsZero() {
+			break
+		}
+		if !filter(a.orders[i].Order) {
+			continue
+		}
+		qty := Min(quantity, a.available(i))
+		a.allocated[i] = a.allocated[i].Add(qty)
+		quantity = quantity.Sub(qty)
+	}
+	return quantity
+}
+
+// proRata allocates the quantity proportionally to unallocated quantities of orders
+// and returns the unallocated rest.
+func (a *allocations) proRata(quantity Uint, lotSize Uint, minAllocation Uint) Uint {
+	total := NewZeroUint()
+	for i := range a.orders {
+		total = total.Add(a.available(i))
+	}
+	if total.IsZero() || quantity.IsZero() {
+		return quantity
+	}
+
+	// Enough quantity to allocate everything
+	if total.LessThanOrEqualTo(quantity) {
+		for i := range a.orders {
+			a.allocated[i] = a.orders[i].Quantity
+		}
+		return quantity.Sub(total)
+	}
+
+	rest := quantity
+	for i := range a.orders {
+		available := a.available(i)
+		qty := quantity.MulQuo(available, total)
+		qty = Min(ApplySteps(qty, lotSize), available)
+		if qty.IsZero() || qty.LessThan(minAllocation) {
+			continue
+		}
+		a.allocated[i] = a.allocated[i].Add(qty)
+		rest = rest.Sub(qty)
+	}
+	return rest
+}
+
+// result returns non-zero allocations in the time priority.
+func (a *allocations) result() []Allocation {
+	result := make([]Allocation, 0, len(a.orders))
+	for i := range a.orders {
+		if !a.allocated[i].IsZero() {
+			result = append(result, Allocation{Order: a.orders[i].Order, Quantity: a.allocated[i]})
+		}
+	}
+	return result
+}
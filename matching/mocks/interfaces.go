@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/cryptonstudio/crypton-matching-engine/matching (interfaces: Handler)
+// Source: github.com/cryptonstudio/crypton-matching-engine/matching (interfaces: Handler,TradeHandler,SequenceHandler)
 
 // Package mockmatching is a generated GoMock package.
 package mockmatching
@@ -154,6 +154,18 @@ func (mr *MockHandlerMockRecorder) OnExecuteTrade(arg0, arg1, arg2, arg3, arg4,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnExecuteTrade", reflect.TypeOf((*MockHandler)(nil).OnExecuteTrade), arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
+// OnRejectOrder mocks base method.
+func (m *MockHandler) OnRejectOrder(arg0 *matching.OrderBook, arg1 *matching.Order, arg2 error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnRejectOrder", arg0, arg1, arg2)
+}
+
+// OnRejectOrder indicates an expected call of OnRejectOrder.
+func (mr *MockHandlerMockRecorder) OnRejectOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnRejectOrder", reflect.TypeOf((*MockHandler)(nil).OnRejectOrder), arg0, arg1, arg2)
+}
+
 // OnUpdateOrder mocks base method.
 func (m *MockHandler) OnUpdateOrder(arg0 *matching.OrderBook, arg1 *matching.Order) {
 	m.ctrl.T.Helper()
@@ -189,3 +201,73 @@ func (mr *MockHandlerMockRecorder) OnUpdatePriceLevel(arg0, arg1 interface{}) *g
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnUpdatePriceLevel", reflect.TypeOf((*MockHandler)(nil).OnUpdatePriceLevel), arg0, arg1)
 }
+
+// MockTradeHandler is a mock of TradeHandler interface.
+type MockTradeHandler struct {
+	ctrl     *gomock.Controller
+	recorder *MockTradeHandlerMockRecorder
+}
+
+// MockTradeHandlerMockRecorder is the mock recorder for MockTradeHandler.
+type MockTradeHandlerMockRecorder struct {
+	mock *MockTradeHandler
+}
+
+// NewMockTradeHandler creates a new mock instance.
+func NewMockTradeHandler(ctrl *gomock.Controller) *MockTradeHandler {
+	mock := &MockTradeHandler{ctrl: ctrl}
+	mock.recorder = &MockTradeHandlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTradeHandler) EXPECT() *MockTradeHandlerMockRecorder {
+	return m.recorder
+}
+
+// OnTrade mocks base method.
+func (m *MockTradeHandler) OnTrade(arg0 *matching.OrderBook, arg1 matching.Trade) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnTrade", arg0, arg1)
+}
+
+// OnTrade indicates an expected call of OnTrade.
+func (mr *MockTradeHandlerMockRecorder) OnTrade(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnTrade", reflect.TypeOf((*MockTradeHandler)(nil).OnTrade), arg0, arg1)
+}
+
+// MockSequenceHandler is a mock of SequenceHandler interface.
+type MockSequenceHandler struct {
+	ctrl     *gomock.Controller
+	recorder *MockSequenceHandlerMockRecorder
+}
+
+// MockSequenceHandlerMockRecorder is the mock recorder for MockSequenceHandler.
+type MockSequenceHandlerMockRecorder struct {
+	mock *MockSequenceHandler
+}
+
+// NewMockSequenceHandler creates a new mock instance.
+func NewMockSequenceHandler(ctrl *gomock.Controller) *MockSequenceHandler {
+	mock := &MockSequenceHandler{ctrl: ctrl}
+	mock.recorder = &MockSequenceHandlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSequenceHandler) EXPECT() *MockSequenceHandlerMockRecorder {
+	return m.recorder
+}
+
+// OnSequence mocks base method.
+func (m *MockSequenceHandler) OnSequence(arg0 uint64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnSequence", arg0)
+}
+
+// OnSequence indicates an expected call of OnSequence.
+func (mr *MockSequenceHandlerMockRecorder) OnSequence(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnSequence", reflect.TypeOf((*MockSequenceHandler)(nil).OnSequence), arg0)
+}
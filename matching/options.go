@@ -0,0 +1,122 @@
+package matching
+
+////////////////////////////////////////////////////////////////
+// Engine options
+////////////////////////////////////////////////////////////////
+
+// EngineOption configures the engine created with NewEngine().
+type EngineOption func(*engineConfig)
+
+// engineConfig contains engine-wide settings, they are neither journaled nor stored in snapshots.
+type engineConfig struct {
+	expectedOrderBooks int
+	maxPendingEvents   int               // maximum number of tasks of the order book with pending events
+	orderBookOpts      []OrderBookOption // default options of all order books
+}
+
+func newEngineConfig(opts []EngineOption) engineConfig {
+	config := engineConfig{
+		expectedOrderBooks: defaultReservedOrderBookSlots,
+		maxPendingEvents:   defaultMaxPendingEvents,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithExpectedOrderBooks sets the initial size of the array storing order books by symbol ID.
+// Non-positive count is ignored and the default size is used.
+func WithExpectedOrderBooks(count int) EngineOption {
+	return func(config *engineConfig) {
+		if count > 0 {
+			config.expectedOrderBooks = count
+		}
+	}
+}
+
+// WithMaxPendingEvents sets the maximum number of tasks of the order book events of which wait for their turn
+// in multithread mode. The order book waits for the turn of the task before the next one if the limit is reached,
+// so memory used by events is bounded if the handler is slower than order books.
+// Non-positive count is ignored and the default count is used.
+func WithMaxPendingEvents(count int) EngineOption {
+	return func(config *engineConfig) {
+		if count > 0 {
+			config.maxPendingEvents = count
+		}
+	}
+}
+
+// WithOrderBookDefaults sets options applied to all order books of the engine, including restored ones.
+// Options passed to AddOrderBook() are applied after them, so they take precedence.
+func WithOrderBookDefaults(opts ...OrderBookOption) EngineOption {
+	return func(config *engineConfig) {
+		config.orderBookOpts = append(config.orderBookOpts, opts...)
+	}
+}
+
+////////////////////////////////////////////////////////////////
+// Order book options
+////////////////////////////////////////////////////////////////
+
+// OrderBookOption configures the order book added to the engine.
+type OrderBookOption func(*orderBookConfig)
+
+// orderBookConfig contains settings of the order book. Only the matching policy is part
+// of the order book state, other settings are neither journaled nor stored in snapshots.
+type orderBookConfig struct {
+	taskQueueSize  int
+	expectedOrders int
+	usePool        bool
+	policy         MatchingPolicy
+}
+
+func newOrderBookConfig(opts ...[]OrderBookOption) orderBookConfig {
+	config := orderBookConfig{
+		taskQueueSize:  defaultOrderBookTaskQueueSize,
+		expectedOrders: defaultReservedOrderSlots,
+		usePool:        true,
+	}
+	for _, group := range opts {
+		for _, opt := range group {
+			opt(&config)
+		}
+	}
+	return config
+}
+
+// WithTaskQueueSize sets the capacity of the task queue of the order book used in multithread mode.
+// Non-positive size is ignored and the default size is used.
+func WithTaskQueueSize(size int) OrderBookOption {
+	return func(config *orderBookConfig) {
+		if size > 0 {
+			config.taskQueueSize = size
+		}
+	}
+}
+
+// WithExpectedOrders sets the initial size of the hashmap storing orders of the order book by order ID.
+// Non-positive count is ignored and the default size is used.
+func WithExpectedOrders(count int) OrderBookOption {
+	return func(config *orderBookConfig) {
+		if count > 0 {
+			config.expectedOrders = count
+		}
+	}
+}
+
+// WithAllocatorPool sets whether the allocator of the order book reuses released
+// orders and tree nodes from pools. Pools are used by default.
+func WithAllocatorPool(usePool bool) OrderBookOption {
+	return func(config *orderBookConfig) {
+		config.usePool = usePool
+	}
+}
+
+// WithMatchingPolicy sets the initial matching policy of the order book, nil policy means FIFO matching.
+// Unlike other options the policy is journaled with the order book, so it is restored by the replay.
+func WithMatchingPolicy(policy MatchingPolicy) OrderBookOption {
+	return func(config *orderBookConfig) {
+		config.policy = policy
+	}
+}
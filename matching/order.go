@@ -2,6 +2,7 @@ package matching
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
 	"github.com/cryptonstudio/crypton-matching-engine/types/list"
@@ -14,6 +15,7 @@ import (
 // venue via direct market access.
 type Order struct {
 	id          uint64
+	ownerID     uint64
 	symbolID    uint32
 	orderType   OrderType
 	side        OrderSide
@@ -39,6 +41,29 @@ type Order struct {
 	// Supported only for limit and stop-limit orders!
 	maxVisible Uint
 
+	// Iceberg order visible slice.
+	// The rest of the current visible slice is stored in visible, when the slice is exhausted
+	// it is refreshed with maxVisible quantity randomized within visibleVariance
+	// and the order is moved to the back of the price level queue (loses its time priority).
+	visible         Uint
+	visibleVariance Uint
+
+	// Pegged order reference price, offset in price steps (negative offset lowers the price)
+	// and the cap price limiting the pegged price (zero means no cap).
+	// Pegged order is repriced when the top of the order book changes.
+	// Supported only for limit orders!
+	pegReference PegReference
+	pegOffset    int64
+	pegCap       Uint
+
+	// Minimum quantity which should be executed immediately when the order is added,
+	// otherwise the order is not executed at all (zero means no minimum).
+	// All-or-none order is executed only completely, as a resting order it is skipped
+	// by incoming orders which cannot execute it completely without losing its time priority.
+	// Supported only for limit orders!
+	minQuantity Uint
+	allOrNone   bool
+
 	// Market order slippage.
 	// Slippage is useful to protect market order from executions at prices
 	// which are too far from the best price. If the slippage is provided
@@ -78,6 +103,27 @@ type Order struct {
 	// Linked order in OCO order pair (used for OCO orders only)
 	linkedOrderID uint64
 
+	// Self-trade prevention mode of the order.
+	// Zero value means the mode of the order book symbol is used.
+	selfTradePrevention SelfTradePrevention
+
+	// Status of the order lifecycle
+	status OrderStatus
+
+	// Reason of the last order change (for example, why the order is deleted)
+	reason OrderReason
+
+	// Expiration time of the order (used for GTD orders only)
+	expireTime time.Time
+
+	// Placement sequence of the order in the order book, the order with the lower value has come earlier
+	placement uint64
+
+	// Engine time of the order lifecycle events
+	acceptTime   time.Time // the order is accepted by the order book
+	updateTime   time.Time // the last change of the order (including the final one)
+	activateTime time.Time // the stop order is activated (used for stop orders only)
+
 	// Pointer to the price level where the order is placed.
 	priceLevel *avl.Node[Uint, *PriceLevelL3]
 
@@ -92,6 +138,12 @@ func (o *Order) ID() uint64 {
 	return o.id
 }
 
+// OwnerID returns the ID of the order owner (account).
+// Zero value means the order has no owner, so self-trade prevention is not applied.
+func (o *Order) OwnerID() uint64 {
+	return o.ownerID
+}
+
 // SymbolID returns the symbol ID of the order.
 func (o *Order) SymbolID() uint32 {
 	return o.symbolID
@@ -114,6 +166,12 @@ func (o *Order) IsMarket() bool {
 	return o.orderType == OrderTypeMarket
 }
 
+// IsMarketToLimit returns true if market-to-limit order.
+// The order becomes the limit one once its rest is placed to the order book.
+func (o *Order) IsMarketToLimit() bool {
+	return o.orderType == OrderTypeMarketToLimit
+}
+
 // IsStop returns true if stop order.
 func (o *Order) IsStop() bool {
 	return o.orderType == OrderTypeStop
@@ -193,6 +251,26 @@ func (o *Order) IsFOK() bool {
 	return o.timeInForce == OrderTimeInForceFOK
 }
 
+// IsPostOnly returns true if 'Post-Only' order (both rejecting and sliding).
+func (o *Order) IsPostOnly() bool {
+	return o.timeInForce == OrderTimeInForcePostOnly || o.timeInForce == OrderTimeInForcePostOnlySlide
+}
+
+// IsGTD returns true if 'Good-Till-Date' order.
+func (o *Order) IsGTD() bool {
+	return o.timeInForce == OrderTimeInForceGTD
+}
+
+// isResting returns true if the order remaining part is placed into the order book.
+func (o *Order) isResting() bool {
+	return o.IsGTC() || o.IsGTD() || o.IsPostOnly()
+}
+
+// isExpired returns true if GTD order is expired at the given time.
+func (o *Order) isExpired(now time.Time) bool {
+	return o.IsGTD() && !o.expireTime.After(now)
+}
+
 ////////////////////////////////////////////////////////////////
 
 // MaxVisibleQuantity returns maximum visible in an order book quantity of the order.
@@ -210,6 +288,85 @@ func (o *Order) IsIceberg() bool {
 	return !o.IsHidden() && !o.maxVisible.IsZero()
 }
 
+// IsPegged returns true if the order price is pegged to the top of the order book.
+func (o *Order) IsPegged() bool {
+	return o.pegReference != 0
+}
+
+// PegReference returns the reference price of the pegged order.
+func (o *Order) PegReference() PegReference {
+	return o.pegReference
+}
+
+// PegOffset returns the offset of the pegged order price from the reference price in price steps.
+func (o *Order) PegOffset() int64 {
+	return o.pegOffset
+}
+
+// PegCap returns the cap price of the pegged order, zero means no cap.
+func (o *Order) PegCap() Uint {
+	return o.pegCap
+}
+
+// IcebergVariance returns the maximum deviation of the refreshed visible slice of the iceberg order.
+func (o *Order) IcebergVariance() Uint {
+	return o.visibleVariance
+}
+
+// SetIcebergVariance sets the maximum deviation of the refreshed visible slice of the iceberg order,
+// so each refreshed slice is randomized in range [maxVisible - variance, maxVisible + variance].
+// Variance must be less than the max visible quantity.
+func (o *Order) SetIcebergVariance(variance Uint) {
+	o.visibleVariance = variance
+}
+
+// executeVisible reduces the current visible slice of the iceberg order by the executed quantity.
+func (o *Order) executeVisible(qty Uint) {
+	o.visible = o.visible.Sub(Min(o.visible, qty))
+}
+
+// isSliceExhausted returns true if the visible slice of the resting iceberg order should be refreshed.
+func (o *Order) isSliceExhausted() bool {
+	return o.IsIceberg() && o.visible.IsZero() && !o.IsExecuted() && o.priceLevel != nil
+}
+
+////////////////////////////////////////////////////////////////
+
+// MinQuantity returns the minimum quantity which should be executed immediately when the order is added.
+func (o *Order) MinQuantity() Uint {
+	return o.minQuantity
+}
+
+// SetMinQuantity sets the minimum quantity which should be executed immediately when the order is added.
+// Minimum quantity must not be greater than the order quantity.
+func (o *Order) SetMinQuantity(quantity Uint) {
+	o.minQuantity = quantity
+}
+
+// IsAllOrNone returns true if the order can be executed only completely.
+func (o *Order) IsAllOrNone() bool {
+	return o.allOrNone
+}
+
+// SetAllOrNone sets whether the order can be executed only completely.
+func (o *Order) SetAllOrNone(allOrNone bool) {
+	o.allOrNone = allOrNone
+}
+
+// minExecution returns the minimum quantity of the next execution of the order.
+// All-or-none order requires the whole rest quantity, the minimum quantity
+// is required only while the order is not executed at all.
+func (o *Order) minExecution() Uint {
+	switch {
+	case o.allOrNone:
+		return o.restQuantity
+	case o.executedQuantity.IsZero():
+		return Min(o.minQuantity, o.restQuantity)
+	default:
+		return NewZeroUint()
+	}
+}
+
 ////////////////////////////////////////////////////////////////
 
 // MarketSlippage returns the slippage specified for the market order.
@@ -315,16 +472,17 @@ func (o *Order) IsExecuted() bool {
 }
 
 // VisibleQuantity returns order remaining visible quantity.
+// Iceberg orders show only the rest of the current visible slice.
 func (o *Order) VisibleQuantity() Uint {
+	if o.IsIceberg() {
+		return Min(o.restQuantity, o.visible)
+	}
 	return Min(o.restQuantity, o.maxVisible)
 }
 
 // HiddenQuantity returns order remaining hidden quantity.
 func (o *Order) HiddenQuantity() Uint {
-	if o.restQuantity.GreaterThan(o.maxVisible) {
-		return o.restQuantity.Sub(o.maxVisible)
-	}
-	return Uint{}
+	return o.restQuantity.Sub(o.VisibleQuantity())
 }
 
 // Linked order in OCO order pair (used for OCO orders only)
@@ -332,6 +490,58 @@ func (o *Order) LinkedOrderID() uint64 {
 	return o.linkedOrderID
 }
 
+// SelfTradePrevention returns the self-trade prevention mode of the order.
+func (o *Order) SelfTradePrevention() SelfTradePrevention {
+	return o.selfTradePrevention
+}
+
+// SetSelfTradePrevention sets the self-trade prevention mode of the order
+// overriding the mode of the order book symbol.
+func (o *Order) SetSelfTradePrevention(mode SelfTradePrevention) {
+	o.selfTradePrevention = mode
+}
+
+// Status returns the status of the order lifecycle.
+// Zero value means the order is not added to the engine yet.
+func (o *Order) Status() OrderStatus {
+	return o.status
+}
+
+// Reason returns the reason of the last order change.
+// It is set for deleted orders which are not fully executed and for orders changed
+// by the engine itself (for example, by self-trade prevention).
+func (o *Order) Reason() OrderReason {
+	return o.reason
+}
+
+// ExpireTime returns the expiration time of GTD order.
+func (o *Order) ExpireTime() time.Time {
+	return o.expireTime
+}
+
+// SetExpireTime sets the expiration time of GTD order.
+func (o *Order) SetExpireTime(expireTime time.Time) {
+	o.expireTime = expireTime
+}
+
+// AcceptTime returns the engine time the order is accepted by the order book.
+// Zero time means the order is not accepted yet.
+func (o *Order) AcceptTime() time.Time {
+	return o.acceptTime
+}
+
+// UpdateTime returns the engine time of the last change of the order
+// including its acceptance, activation, execution, deletion or reject.
+func (o *Order) UpdateTime() time.Time {
+	return o.updateTime
+}
+
+// ActivateTime returns the engine time the stop order is activated.
+// Zero time means the order is not activated.
+func (o *Order) ActivateTime() time.Time {
+	return o.activateTime
+}
+
 ////////////////////////////////////////////////////////////////
 
 // Validate returns error if the order fails to pass validation so can be used safely.
@@ -349,6 +559,7 @@ func (o *Order) Validate(ob *OrderBook) error {
 	case OrderTypeStopLimit:
 	case OrderTypeTrailingStop:
 	case OrderTypeTrailingStopLimit:
+	case OrderTypeMarketToLimit:
 	default:
 		return ErrInvalidOrderType
 	}
@@ -360,9 +571,79 @@ func (o *Order) Validate(ob *OrderBook) error {
 		return ErrInvalidOrderSide
 	}
 
+	// Validate post-only time in force, only orders with limit price can be post-only
+	if o.IsPostOnly() {
+		switch o.orderType {
+		case OrderTypeLimit, OrderTypeStopLimit, OrderTypeTrailingStopLimit:
+		default:
+			return ErrInvalidOrderTimeInForce
+		}
+	}
+
+	// Validate market-to-limit time in force, the rest of the order is good-till-cancelled
+	if o.orderType == OrderTypeMarketToLimit && !o.IsGTC() {
+		return ErrInvalidOrderTimeInForce
+	}
+
+	// Validate good-till-date time in force, only orders with limit price can be GTD
+	if o.IsGTD() {
+		switch o.orderType {
+		case OrderTypeLimit, OrderTypeStopLimit, OrderTypeTrailingStopLimit:
+		default:
+			return ErrInvalidOrderTimeInForce
+		}
+		if o.expireTime.IsZero() {
+			return ErrInvalidOrderExpireTime
+		}
+	}
+
+	// Validate iceberg variance, the refreshed slice should not be empty
+	if !o.visibleVariance.IsZero() && (!o.IsIceberg() || o.visibleVariance.GreaterThanOrEqualTo(o.maxVisible)) {
+		return ErrInvalidIcebergVariance
+	}
+
+	// Validate minimum quantity, iceberg order cannot guarantee the minimum execution
+	if !o.minQuantity.IsZero() {
+		if o.orderType != OrderTypeLimit || o.IsIceberg() || o.minQuantity.GreaterThan(o.quantity) {
+			return ErrInvalidOrderMinQuantity
+		}
+		if _, rem := o.minQuantity.QuoRem(ob.symbol.lotSizeLimits.Step); !rem.IsZero() {
+			return ErrInvalidOrderMinQuantity
+		}
+	}
+
+	// Validate all-or-none order, iceberg order cannot be executed completely at once
+	if o.allOrNone && (o.orderType != OrderTypeLimit || o.IsIceberg()) {
+		return ErrInvalidOrderAllOrNone
+	}
+
+	// Validate self-trade prevention mode
+	if o.selfTradePrevention > SelfTradePreventionDecrementAndCancel {
+		return ErrInvalidSelfTradePrevention
+	}
+
+	// Validate pegged order, its price is calculated from the top of the order book
+	if o.IsPegged() {
+		if !o.pegReference.Valid() || o.orderType != OrderTypeLimit || !o.isResting() {
+			return ErrInvalidOrderPeg
+		}
+		if _, rem := o.pegCap.QuoRem(ob.symbol.priceLimits.Step); !rem.IsZero() {
+			return ErrInvalidOrderPeg
+		}
+		// Offset cannot be wider than the range of price limits
+		limits := ob.symbol.priceLimits
+		steps, _ := limits.Max.Sub(limits.Min).QuoRem(limits.Step)
+		if NewUint(pegOffsetSteps(o.pegOffset)).GreaterThan(steps) {
+			return ErrInvalidOrderPeg
+		}
+	}
+
 	// Validate price (if necessary)
 	switch o.orderType {
 	case OrderTypeLimit, OrderTypeStopLimit, OrderTypeTrailingStopLimit:
+		if o.IsPegged() {
+			break
+		}
 		if o.price.LessThan(ob.symbol.priceLimits.Min) {
 			return ErrInvalidOrderPrice
 		}
@@ -550,7 +831,7 @@ func (o *Order) RestoreExecution(executed, executedQuote, restQuantity Uint) {
 ////////////////////////////////////////////////////////////////
 
 func (o *Order) Activated() bool {
-	return o.Type() == OrderTypeLimit || o.Type() == OrderTypeMarket
+	return o.Type() == OrderTypeLimit || o.Type() == OrderTypeMarket || o.Type() == OrderTypeMarketToLimit
 }
 
 func (o *Order) PartiallyExecuted() bool {
@@ -559,9 +840,23 @@ func (o *Order) PartiallyExecuted() bool {
 
 ////////////////////////////////////////////////////////////////
 
+// placedBefore returns true if the order has been placed to the order book before the other order.
+func (o *Order) placedBefore(other *Order) bool {
+	return o.placement < other.placement
+}
+
+// view returns the copy of the order without links to the order book internals.
+func (o *Order) view() Order {
+	view := *o
+	view.priceLevel = nil
+	view.orderQueued = nil
+	return view
+}
+
 // Clean cleans the order, use before put order to the pool
 func (o *Order) Clean() {
 	o.id = 0
+	o.ownerID = 0
 	o.symbolID = 0
 	o.orderType = 0
 	o.side = 0
@@ -573,6 +868,13 @@ func (o *Order) Clean() {
 	o.quantity = NewZeroUint()
 	o.quoteQuantity = NewZeroUint()
 	o.maxVisible = NewZeroUint()
+	o.visible = NewZeroUint()
+	o.visibleVariance = NewZeroUint()
+	o.pegReference = 0
+	o.pegOffset = 0
+	o.pegCap = NewZeroUint()
+	o.minQuantity = NewZeroUint()
+	o.allOrNone = false
 	o.marketSlippage = NewZeroUint()
 	o.trailingDistance = NewZeroUint()
 	o.trailingStep = NewZeroUint()
@@ -583,6 +885,14 @@ func (o *Order) Clean() {
 	o.executedQuoteQuantity = NewZeroUint()
 	o.marketQuoteMode = false
 	o.linkedOrderID = 0
+	o.selfTradePrevention = 0
+	o.status = 0
+	o.reason = OrderReasonNone
+	o.expireTime = time.Time{}
+	o.placement = 0
+	o.acceptTime = time.Time{}
+	o.updateTime = time.Time{}
+	o.activateTime = time.Time{}
 	o.priceLevel = nil
 	o.orderQueued = nil
 }
@@ -1,8 +1,11 @@
 package matching
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/tidwall/hashmap"
 
@@ -42,6 +45,9 @@ type OrderBook struct {
 	// Index price
 	indexPrice Uint
 
+	// Reference price of the static price band
+	referencePrice Uint
+
 	lastBidPrice     Uint
 	lastAskPrice     Uint
 	matchingBidPrice Uint
@@ -52,6 +58,39 @@ type OrderBook struct {
 	// Last used update ID
 	lastUpdateID uint64
 
+	// Last used trade ID
+	lastTradeID uint64
+
+	// Last used placement sequence of orders
+	lastPlacement uint64
+
+	// Secret seed of randomized iceberg slices (journaled and stored in snapshots)
+	seed uint64
+
+	// Events of the performed task buffered in multithread mode
+	events []event
+
+	// Automatic matching (applied to the order book in order with other tasks)
+	matching bool
+
+	// Trading state (applied to the order book in order with other tasks)
+	state TradingState
+
+	// Matching policy of orders inside price levels, nil means price-time FIFO
+	policy MatchingPolicy
+
+	// Time of the currently performed command
+	now time.Time
+
+	// Scheduled expirations of GTD orders
+	expirations expirationQueue
+
+	// Pegged orders repriced on the top of the order book changes
+	pegs peggedOrders
+
+	// Trades recorded for the acknowledgement of the currently performed command
+	trades *[]Trade
+
 	// Orders storage is internal for each order book
 	orders *hashmap.Map[uint64, *Order]
 
@@ -60,17 +99,26 @@ type OrderBook struct {
 	chanTasks chan func(*OrderBook) error
 
 	// Synchronization stuff
+	enqueueTurns   enqueueTurns  // order of enqueueing tasks scheduled with locked commands
+	dequeued       dequeueSignal // signaled when a task is taken from the queue
 	chanForcedStop chan struct{} // for forced stop
 	wg             sync.WaitGroup
 }
 
 // NewOrderBook creates and returns new OrderBook instance.
 func NewOrderBook(symbol Symbol, spModesConfig StopPriceModeConfig, taskQueueSize int) *OrderBook {
+	config := newOrderBookConfig()
+	config.taskQueueSize = taskQueueSize
+	return newOrderBook(symbol, spModesConfig, config)
+}
+
+// newOrderBook creates and returns new OrderBook instance configured with the given config.
+func newOrderBook(symbol Symbol, spModesConfig StopPriceModeConfig, config orderBookConfig) *OrderBook {
 	// Prepare allocator
 	// TODO: Test how GC behaves in both cases (with/without pool)
-	allocator := NewAllocator(true)
+	allocator := NewAllocator(config.usePool)
 
-	return &OrderBook{
+	ob := &OrderBook{
 		allocator:        allocator,
 		symbol:           symbol,
 		bids:             allocator.NewPriceLevelReversedTree(),
@@ -81,17 +129,23 @@ func NewOrderBook(symbol Symbol, spModesConfig StopPriceModeConfig, taskQueueSiz
 		trailingBuyStop:  allocator.NewPriceLevelReversedTree(),
 		trailingSellStop: allocator.NewPriceLevelTree(),
 		marketPrice:      NewZeroUint(),
+		referencePrice:   NewZeroUint(),
 		lastBidPrice:     NewZeroUint(),
 		lastAskPrice:     NewMaxUint(),
 		matchingBidPrice: NewZeroUint(),
 		matchingAskPrice: NewMaxUint(),
 		trailingBidPrice: NewZeroUint(),
 		trailingAskPrice: NewMaxUint(),
-		orders:           hashmap.New[uint64, *Order](defaultReservedOrderSlots),
-		chanTasks:        make(chan func(*OrderBook) error, taskQueueSize),
+		state:            TradingStateTrading,
+		orders:           hashmap.New[uint64, *Order](config.expectedOrders),
+		policy:           config.policy,
+		chanTasks:        make(chan func(*OrderBook) error, config.taskQueueSize),
 		chanForcedStop:   make(chan struct{}),
 		wg:               sync.WaitGroup{},
 	}
+	ob.enqueueTurns.cond.L = &ob.enqueueTurns.mx
+
+	return ob
 }
 
 // Clean releases all internally used tree nodes and cleans whole order book state.
@@ -118,6 +172,10 @@ func (ob *OrderBook) Symbol() Symbol {
 	return ob.symbol
 }
 
+// UpdateSymbol updates the symbol of the order book directly.
+//
+// Deprecated: The change is neither journaled nor synchronized with order book tasks,
+// use Engine.UpdateSymbol() instead.
 func (ob *OrderBook) UpdateSymbol(sym Symbol) error {
 	if ob.symbol.id != sym.id {
 		return ErrInvalidSymbol
@@ -153,6 +211,123 @@ func (ob *OrderBook) Order(id uint64) *Order {
 	return nil
 }
 
+// checkOrdersPairDuplicate checks IDs of the OCO pair orders are not used by each other or by orders of the order book.
+func (ob *OrderBook) checkOrdersPairDuplicate(first *Order, second *Order) error {
+	if first.id == second.id || ob.Order(first.id) != nil || ob.Order(second.id) != nil {
+		return ErrOrderDuplicate
+	}
+	return nil
+}
+
+// TaskQueueDepth returns the number of tasks waiting in the queue of the order book.
+// Tasks are queued only in multithread mode, so zero is always returned in single-thread mode.
+func (ob *OrderBook) TaskQueueDepth() int {
+	return len(ob.chanTasks)
+}
+
+// TaskQueueSize returns the capacity of the task queue of the order book.
+func (ob *OrderBook) TaskQueueSize() int {
+	return cap(ob.chanTasks)
+}
+
+// enqueueTurns lets tasks of the order book be enqueued in the order they are scheduled with locked commands,
+// while callers wait for the space in the queue with unlocked commands.
+type enqueueTurns struct {
+	mx    sync.Mutex
+	cond  sync.Cond
+	taken uint64 // number of taken tickets
+	turn  uint64 // ticket allowed to enqueue its task
+}
+
+// take returns the next ticket to enqueue the task.
+// NOTE: Should be called with locked commands.
+func (t *enqueueTurns) take() uint64 {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.taken++
+	return t.taken - 1
+}
+
+// idle returns true if there are no tickets waiting for their turns.
+func (t *enqueueTurns) idle() bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.turn == t.taken
+}
+
+// wait waits until the turn of the given ticket comes.
+func (t *enqueueTurns) wait(ticket uint64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for t.turn != ticket {
+		t.cond.Wait()
+	}
+}
+
+// done passes the turn to the next ticket.
+func (t *enqueueTurns) done(ticket uint64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.turn = ticket + 1
+	t.cond.Broadcast()
+}
+
+// dequeueSignal wakes up all callers waiting for the space in the task queue of the order book.
+// The channel is closed and replaced on every signal, so no waiting caller misses the wakeup.
+type dequeueSignal struct {
+	mx sync.Mutex
+	ch chan struct{}
+}
+
+// wait returns the channel closed when the next task is taken from the queue.
+// NOTE: Should be called before checking the queue, so the wakeup after the check is not missed.
+func (s *dequeueSignal) wait() <-chan struct{} {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+// signal wakes up all callers waiting for the dequeued task.
+func (s *dequeueSignal) signal() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// TradingState returns the trading state of the order book.
+func (ob *OrderBook) TradingState() TradingState {
+	return ob.state
+}
+
+// Time returns the engine time of the command performed by the order book, so all events
+// of the command passed to the handler are stamped with the same time.
+// NOTE: Should be called from handlers called by the order book (use UpdateTime() of orders
+// rejected by the validation), otherwise the time of the last performed command is returned.
+func (ob *OrderBook) Time() time.Time {
+	return ob.now
+}
+
+// MatchingPolicy returns the matching policy of the order book or nil if price-time FIFO is used.
+func (ob *OrderBook) MatchingPolicy() MatchingPolicy {
+	return ob.policy
+}
+
+// InAuction returns true if the order book is in the auction phase.
+func (ob *OrderBook) InAuction() bool {
+	return ob.state == TradingStateAuction
+}
+
+// isMatching returns true if crossed orders of the order book are matched automatically.
+func (ob *OrderBook) isMatching() bool {
+	return ob.matching && ob.state.canMatch()
+}
+
 ////////////////////////////////////////////////////////////////
 // Top price levels getters
 ////////////////////////////////////////////////////////////////
@@ -313,6 +488,11 @@ func (ob *OrderBook) addOrder(tree *avl.Tree[Uint, *PriceLevelL3], order *Order)
 
 	priceLevel := node.Value()
 
+	// Show the first visible slice of the iceberg order
+	if order.IsIceberg() && order.visible.IsZero() {
+		ob.refreshVisible(order)
+	}
+
 	if !order.IsVirtualOB() {
 		// Update the price level volume
 		priceLevel.volume = priceLevel.volume.Add(order.restQuantity)
@@ -321,10 +501,19 @@ func (ob *OrderBook) addOrder(tree *avl.Tree[Uint, *PriceLevelL3], order *Order)
 
 	// Enqueue the new order to the order queue of the price level
 	order.orderQueued = priceLevel.queue.PushBack(order)
+	order.placement = ob.nextPlacement()
 
 	// Cache the price level in the given order
 	order.priceLevel = node
 
+	// Schedule the expiration of GTD order
+	ob.expirations.schedule(order)
+
+	// Register the pegged order for repricing
+	if order.IsPegged() {
+		ob.pegs.add(order.id)
+	}
+
 	// Price level was changed so prepare update object
 	update = PriceLevelUpdate{
 		Kind:    update.Kind,
@@ -339,6 +528,91 @@ func (ob *OrderBook) addOrder(tree *avl.Tree[Uint, *PriceLevelL3], order *Order)
 	return
 }
 
+// requeueOrder refreshes the visible slice of the iceberg order
+// and moves the order to the back of the price level queue.
+func (ob *OrderBook) requeueOrder(tree *avl.Tree[Uint, *PriceLevelL3], order *Order) (update PriceLevelUpdate, err error) {
+	update.Kind = PriceLevelUpdateKindUpdate
+
+	// Ensure the tree is specified
+	if tree == nil {
+		err = ErrOrderTreeNotFound
+		return
+	}
+
+	// Find the price level for the order
+	node := order.priceLevel
+	if node == nil {
+		err = ErrPriceLevelNotFound
+		return
+	}
+
+	priceLevel := node.Value()
+
+	// Show the next visible slice of the order
+	visible := order.VisibleQuantity()
+	ob.refreshVisible(order)
+	if !order.IsVirtualOB() {
+		priceLevel.visible = priceLevel.visible.Sub(visible).Add(order.VisibleQuantity())
+	}
+
+	// Refreshed order loses its time priority
+	priceLevel.queue.MoveToBack(order.orderQueued)
+	order.placement = ob.nextPlacement()
+
+	// Price level was changed so prepare update object
+	update = PriceLevelUpdate{
+		Kind:    update.Kind,
+		Side:    order.side,
+		Price:   priceLevel.Price(),
+		Volume:  priceLevel.Volume(),
+		Visible: priceLevel.Visible(),
+		Orders:  priceLevel.Orders(),
+		Top:     tree.MostLeft() != nil && node.Key().Equals(tree.MostLeft().Key()),
+	}
+
+	return
+}
+
+// nextPlacement returns the next placement sequence defining the time priority of orders.
+func (ob *OrderBook) nextPlacement() uint64 {
+	ob.lastPlacement++
+	return ob.lastPlacement
+}
+
+// newOrderBookSeed returns the random secret seed of the new order book.
+func newOrderBookSeed() uint64 {
+	var seed [8]byte
+	_, _ = rand.Read(seed[:]) // never returns an error
+	return binary.LittleEndian.Uint64(seed[:])
+}
+
+// refreshVisible sets the new visible slice of the iceberg order. The slice is randomized
+// within the iceberg variance and rounded to the lot size step. Random deviation is derived
+// from the secret seed of the order book and the order state, so slices can't be predicted
+// by other participants, but the replayed journal shows exactly the same slices.
+func (ob *OrderBook) refreshVisible(order *Order) {
+	step := ob.symbol.lotSizeLimits.Step
+
+	slice := order.maxVisible
+	if !order.visibleVariance.IsZero() && order.visibleVariance.LessThan(slice) {
+		_, deviation := NewUint(mix64(ob.seed ^ mix64(order.id^order.executedQuantity.v.Lo))).QuoRem(order.visibleVariance.Mul64(2).Add64(1))
+		slice = slice.Sub(order.visibleVariance).Add(deviation)
+	}
+	if !step.IsZero() {
+		slice = Max(ApplySteps(slice, step), step)
+	}
+
+	order.visible = Min(slice, order.restQuantity)
+}
+
+// mix64 is the SplitMix64 finalizer used as the deterministic pseudo-random generator.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
 func (ob *OrderBook) reduceOrder(tree *avl.Tree[Uint, *PriceLevelL3], order *Order, quantity Uint, visible Uint) (update PriceLevelUpdate, err error) {
 	update.Kind = PriceLevelUpdateKindUpdate
 
@@ -429,6 +703,9 @@ func (ob *OrderBook) deleteOrder(tree *avl.Tree[Uint, *PriceLevelL3], order *Ord
 	// Clear the price level cache in the given order
 	order.priceLevel = nil
 
+	// Drop the scheduled expiration of GTD order
+	ob.expirations.remove(order.id)
+
 	// Price level was changed so prepare update object
 	update = PriceLevelUpdate{
 		Kind:    update.Kind,
@@ -487,7 +764,7 @@ func (ob *OrderBook) treeForOrder(order *Order) *avl.Tree[Uint, *PriceLevelL3] {
 		} else {
 			return &ob.asks
 		}
-	case OrderTypeMarket:
+	case OrderTypeMarket, OrderTypeMarketToLimit:
 	case OrderTypeStop, OrderTypeStopLimit:
 		if order.IsBuy() {
 			return &ob.buyStop
@@ -504,6 +781,42 @@ func (ob *OrderBook) treeForOrder(order *Order) *avl.Tree[Uint, *PriceLevelL3] {
 	return nil
 }
 
+// selfTradePrevention returns the self-trade prevention mode for crossed orders
+// or zero if the orders can be matched with each other.
+func (ob *OrderBook) selfTradePrevention(newest *Order, oldest *Order) SelfTradePrevention {
+	if newest.ownerID == 0 || newest.ownerID != oldest.ownerID {
+		return 0
+	}
+	if newest.selfTradePrevention != 0 {
+		return newest.selfTradePrevention
+	}
+	return ob.symbol.selfTradePrevention
+}
+
+// ReferencePrice returns the reference price of the static price band.
+func (ob *OrderBook) ReferencePrice() Uint {
+	return ob.referencePrice
+}
+
+// priceBandReference returns the current reference price of the price band.
+func (ob *OrderBook) priceBandReference(band PriceBand) Uint {
+	if band.Reference == PriceBandReferenceMark {
+		return ob.markPrice
+	}
+	return ob.marketPrice
+}
+
+// anchorStaticPriceBand anchors the static price band to the current reference price.
+func (ob *OrderBook) anchorStaticPriceBand() {
+	ob.referencePrice = ob.priceBandReference(ob.symbol.staticPriceBand)
+}
+
+// priceInBands returns true if the price is within the static and the dynamic price bands of the symbol.
+func (ob *OrderBook) priceInBands(price Uint) bool {
+	return ob.symbol.staticPriceBand.contains(ob.referencePrice, price) &&
+		ob.symbol.dynamicPriceBand.contains(ob.priceBandReference(ob.symbol.dynamicPriceBand), price)
+}
+
 // Debugging printer
 func (ob *OrderBook) Debug() {
 	fmt.Printf("\n\nDebugging: order book state\n\n")
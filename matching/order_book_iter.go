@@ -0,0 +1,117 @@
+package matching
+
+import (
+	"iter"
+	"slices"
+
+	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
+)
+
+// RestingOrders returns the iterator over resting orders of the given side of the order book
+// in strict price-time priority: from the best price level and from the oldest order in each level.
+// Copies of orders are yielded, so changing them does not affect the order book.
+// Zero side yields bids followed by asks.
+// NOTE: Not thread-safe, the order book must not be changed while iterating.
+// Use Engine.RestingOrders() method in multithread mode.
+func (ob *OrderBook) RestingOrders(side OrderSide) iter.Seq[Order] {
+	switch side {
+	case OrderSideBuy:
+		return treeOrders(&ob.bids)
+	case OrderSideSell:
+		return treeOrders(&ob.asks)
+	case 0:
+		return concatOrders(treeOrders(&ob.bids), treeOrders(&ob.asks))
+	}
+	return concatOrders()
+}
+
+// StopOrders returns the iterator over not activated stop and stop-limit orders of the given side
+// from the top of the stop book (see TopBuyStop() and TopSellStop()) and from the oldest order in each level.
+// Copies of orders are yielded, so changing them does not affect the order book.
+// Zero side yields buy orders followed by sell orders.
+// NOTE: Not thread-safe, the order book must not be changed while iterating.
+func (ob *OrderBook) StopOrders(side OrderSide) iter.Seq[Order] {
+	switch side {
+	case OrderSideBuy:
+		return treeOrders(&ob.buyStop)
+	case OrderSideSell:
+		return treeOrders(&ob.sellStop)
+	case 0:
+		return concatOrders(treeOrders(&ob.buyStop), treeOrders(&ob.sellStop))
+	}
+	return concatOrders()
+}
+
+// TrailingStopOrders returns the iterator over not activated trailing stop and trailing stop-limit
+// orders of the given side in the same order as StopOrders() method.
+// NOTE: Not thread-safe, the order book must not be changed while iterating.
+func (ob *OrderBook) TrailingStopOrders(side OrderSide) iter.Seq[Order] {
+	switch side {
+	case OrderSideBuy:
+		return treeOrders(&ob.trailingBuyStop)
+	case OrderSideSell:
+		return treeOrders(&ob.trailingSellStop)
+	case 0:
+		return concatOrders(treeOrders(&ob.trailingBuyStop), treeOrders(&ob.trailingSellStop))
+	}
+	return concatOrders()
+}
+
+// RestingOrders returns copies of resting orders of the given side of the order book
+// in strict price-time priority like OrderBook.RestingOrders() method.
+// In multithread mode orders are collected by the order book task,
+// so they include all commands accepted before the call.
+func (e *Engine) RestingOrders(symbolID uint32, side OrderSide) ([]Order, error) {
+	// Get the valid order book
+	ob := e.OrderBook(symbolID)
+	if ob == nil {
+		return nil, ErrOrderBookNotFound
+	}
+
+	var orders []Order
+	task := func(ob *OrderBook) error {
+		orders = slices.Collect(ob.RestingOrders(side))
+		return nil
+	}
+
+	// The task is performed after all accepted commands
+	if err := <-e.performOrderBookTaskAsync(ob, task); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// treeOrders returns the iterator over copies of orders of all price levels of the tree in its order.
+func treeOrders(tree *avl.Tree[Uint, *PriceLevelL3]) iter.Seq[Order] {
+	return func(yield func(Order) bool) {
+		stopped := false
+		tree.IterateInOrder(func(priceLevel *PriceLevelL3) bool {
+			// Iteration is stopped only in the current subtree, so ancestors are skipped here as well
+			if stopped {
+				return true
+			}
+			it := priceLevel.Iterator()
+			for it.Next() {
+				if !yield(it.Current().Value.view()) {
+					stopped = true
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// concatOrders returns the iterator yielding orders of all given iterators one by one.
+func concatOrders(seqs ...iter.Seq[Order]) iter.Seq[Order] {
+	return func(yield func(Order) bool) {
+		for _, seq := range seqs {
+			for order := range seq {
+				if !yield(order) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package matching
+
+// OrderReason is an enumeration of possible reasons of order changes and deletions.
+type OrderReason uint8
+
+const (
+	// OrderReasonNone means the order is changed by the regular flow (by the modification request or by the trade),
+	// so the order deleted without the reason is fully executed.
+	OrderReasonNone OrderReason = iota
+
+	// OrderReasonSelfTradePrevention means the order is canceled or decremented
+	// to prevent the trade with another order of the same owner.
+	OrderReasonSelfTradePrevention
+
+	// OrderReasonPostOnly means the post-only order is canceled because it would take liquidity.
+	OrderReasonPostOnly
+
+	// OrderReasonPostOnlySlide means the post-only order is repriced to one price step
+	// away from the opposite top of the book because it would take liquidity.
+	OrderReasonPostOnlySlide
+
+	// OrderReasonExpired means the good-till-date order is deleted at its expiration time.
+	OrderReasonExpired
+
+	// OrderReasonUserCancel means the order is deleted by the request of the owner
+	// (including mass cancel and reducing the order to zero quantity).
+	OrderReasonUserCancel
+
+	// OrderReasonIOCRemainder means the rest of the immediate-or-cancel or market order
+	// is deleted because it is not executed immediately.
+	OrderReasonIOCRemainder
+
+	// OrderReasonFOKUnfillable means the fill-or-kill order is deleted because it cannot be fully executed.
+	OrderReasonFOKUnfillable
+
+	// OrderReasonDustRemainder means the rest of the order is deleted because it is less than the lot size step.
+	OrderReasonDustRemainder
+
+	// OrderReasonOCOSibling means the order is deleted because the linked order of the same pair
+	// is executed, activated or deleted.
+	OrderReasonOCOSibling
+
+	// OrderReasonInsufficientLocked means the rest of the order is deleted because its locked amount
+	// is not enough to execute the next lot.
+	OrderReasonInsufficientLocked
+
+	// OrderReasonReplaced means the order is deleted because it is replaced by the order with new ID.
+	OrderReasonReplaced
+
+	// OrderReasonPriceOutOfBand means the rest of the order is deleted because its price
+	// is outside of price bands of the symbol.
+	OrderReasonPriceOutOfBand
+
+	// OrderReasonCircuitBreaker means the rest of the taker order is deleted because its execution
+	// outside of price bands has halted the order book.
+	OrderReasonCircuitBreaker
+)
+
+func (r OrderReason) String() string {
+	switch r {
+	case OrderReasonNone:
+		return "none"
+	case OrderReasonSelfTradePrevention:
+		return "self-trade-prevention"
+	case OrderReasonPostOnly:
+		return "post-only"
+	case OrderReasonPostOnlySlide:
+		return "post-only-slide"
+	case OrderReasonExpired:
+		return "expired"
+	case OrderReasonUserCancel:
+		return "user-cancel"
+	case OrderReasonIOCRemainder:
+		return "ioc-remainder"
+	case OrderReasonFOKUnfillable:
+		return "fok-unfillable"
+	case OrderReasonDustRemainder:
+		return "dust-remainder"
+	case OrderReasonOCOSibling:
+		return "oco-sibling"
+	case OrderReasonInsufficientLocked:
+		return "insufficient-locked"
+	case OrderReasonReplaced:
+		return "replaced"
+	case OrderReasonPriceOutOfBand:
+		return "price-out-of-band"
+	case OrderReasonCircuitBreaker:
+		return "circuit-breaker"
+	default:
+		return "unknown"
+	}
+}
+
+// deletedStatus returns the final status of the order deleted for the reason.
+func (r OrderReason) deletedStatus() OrderStatus {
+	switch r {
+	case OrderReasonNone:
+		return OrderStatusFilled
+	case OrderReasonExpired:
+		return OrderStatusExpired
+	case OrderReasonPostOnly, OrderReasonFOKUnfillable:
+		return OrderStatusRejected
+	default:
+		return OrderStatusCancelled
+	}
+}
+
+// rejectError returns the error the order deleted for the reason is rejected with
+// or nil if the order is not rejected.
+func (r OrderReason) rejectError() error {
+	switch r {
+	case OrderReasonPostOnly:
+		return ErrPostOnlyWouldTake
+	case OrderReasonFOKUnfillable:
+		return ErrFOKUnfillable
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package matching
+
+// OrderStatus is an enumeration of possible states of the order lifecycle.
+type OrderStatus uint8
+
+const (
+	// OrderStatusNew means the order is added and not executed yet.
+	OrderStatusNew OrderStatus = iota + 1
+
+	// OrderStatusPartiallyFilled means the order is partially executed and its rest is still active.
+	OrderStatusPartiallyFilled
+
+	// OrderStatusFilled means the order is fully executed and deleted.
+	OrderStatusFilled
+
+	// OrderStatusCancelled means the order is deleted before it is fully executed,
+	// the reason of the order tells why (for example, by the request of the owner).
+	OrderStatusCancelled
+
+	// OrderStatusExpired means the good-till-date order is deleted at its expiration time.
+	OrderStatusExpired
+
+	// OrderStatusTriggered means the stop order is activated and not executed yet.
+	OrderStatusTriggered
+
+	// OrderStatusRejected means the order is deleted without placing it into the order book,
+	// because it would break its execution conditions (fill-or-kill or post-only).
+	OrderStatusRejected
+)
+
+func (s OrderStatus) String() string {
+	switch s {
+	case OrderStatusNew:
+		return "new"
+	case OrderStatusPartiallyFilled:
+		return "partially-filled"
+	case OrderStatusFilled:
+		return "filled"
+	case OrderStatusCancelled:
+		return "cancelled"
+	case OrderStatusExpired:
+		return "expired"
+	case OrderStatusTriggered:
+		return "triggered"
+	case OrderStatusRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
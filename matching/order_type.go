@@ -49,6 +49,11 @@ const (
 	// A trailing stop-limit order is similar to a trailing stop order.
 	// Instead of selling at market price when triggered, the order becomes a limit order.
 	OrderTypeTrailingStopLimit
+
+	// A market-to-limit order is executed as a market order at the best available prices.
+	// Unfilled part of the order becomes a good-till-cancelled limit order at the price
+	// of its last execution. The order is cancelled if it is not executed at all.
+	OrderTypeMarketToLimit
 )
 
 func (ot OrderType) String() string {
@@ -65,6 +70,8 @@ func (ot OrderType) String() string {
 		return "trailing-stop"
 	case OrderTypeTrailingStopLimit:
 		return "trailing-stop-limit"
+	case OrderTypeMarketToLimit:
+		return "market-to-limit"
 	default:
 		return "unknown"
 	}
@@ -85,6 +92,15 @@ const (
 	// be executed immediately in its entirety; otherwise, the entire order will be cancelled
 	// (i.e., no partial execution of the order is allowed).
 	OrderTimeInForceFOK
+	// Post-Only - A post-only order is an order which is never executed immediately, so it
+	// only adds liquidity to the order book. If the order would take liquidity, it is rejected.
+	OrderTimeInForcePostOnly
+	// Post-Only-Slide - A post-only order which is repriced to one price step away from the
+	// opposite top of the book instead of rejection, if it would take liquidity.
+	OrderTimeInForcePostOnlySlide
+	// Good-Till-Date (GTD) - A GTD order is an order which lasts until the order is completed,
+	// cancelled or expired at the specified expiration time.
+	OrderTimeInForceGTD
 )
 
 func (ot OrderTimeInForce) String() string {
@@ -95,6 +111,12 @@ func (ot OrderTimeInForce) String() string {
 		return "immediate-or-cancel"
 	case OrderTimeInForceFOK:
 		return "fill-or-kill"
+	case OrderTimeInForcePostOnly:
+		return "post-only"
+	case OrderTimeInForcePostOnlySlide:
+		return "post-only-slide"
+	case OrderTimeInForceGTD:
+		return "good-till-date"
 	default:
 		return "unknown"
 	}
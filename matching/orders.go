@@ -4,6 +4,7 @@ package matching
 func NewLimitOrder(
 	symbolID uint32,
 	orderID uint64,
+	ownerID uint64,
 	side OrderSide,
 	direction OrderDirection,
 	timeInForce OrderTimeInForce,
@@ -14,6 +15,7 @@ func NewLimitOrder(
 ) Order {
 	return Order{
 		id:           orderID,
+		ownerID:      ownerID,
 		symbolID:     symbolID,
 		orderType:    OrderTypeLimit,
 		side:         side,
@@ -27,10 +29,46 @@ func NewLimitOrder(
 	}
 }
 
+// NewPeggedOrder creates new limit order with the price pegged to the top of the order book.
+// Price of the order is calculated from the reference price with the offset in price steps
+// and limited by the cap price (zero cap means no limit).
+func NewPeggedOrder(
+	symbolID uint32,
+	orderID uint64,
+	ownerID uint64,
+	side OrderSide,
+	direction OrderDirection,
+	timeInForce OrderTimeInForce,
+	reference PegReference,
+	offset int64,
+	capPrice Uint,
+	quantity Uint,
+	maxVisible Uint,
+	restLocked Uint,
+) Order {
+	return Order{
+		id:           orderID,
+		ownerID:      ownerID,
+		symbolID:     symbolID,
+		orderType:    OrderTypeLimit,
+		side:         side,
+		direction:    direction,
+		timeInForce:  timeInForce,
+		pegReference: reference,
+		pegOffset:    offset,
+		pegCap:       capPrice,
+		quantity:     quantity,
+		maxVisible:   maxVisible,
+		available:    restLocked,
+		restQuantity: quantity,
+	}
+}
+
 // NewMarketOrder creates new market order.
 func NewMarketOrder(
 	symbolID uint32,
 	orderID uint64,
+	ownerID uint64,
 	side OrderSide,
 	direction OrderDirection,
 	timeInForce OrderTimeInForce,
@@ -41,6 +79,7 @@ func NewMarketOrder(
 ) Order {
 	return Order{
 		id:                orderID,
+		ownerID:           ownerID,
 		symbolID:          symbolID,
 		orderType:         OrderTypeMarket,
 		side:              side,
@@ -56,10 +95,43 @@ func NewMarketOrder(
 	}
 }
 
+// NewMarketToLimitOrder creates new market-to-limit order.
+// Unfilled part of the order rests in the order book as GTC limit order at the price of its last execution.
+func NewMarketToLimitOrder(
+	symbolID uint32,
+	orderID uint64,
+	ownerID uint64,
+	side OrderSide,
+	direction OrderDirection,
+	quantity Uint,
+	quoteQuantity Uint,
+	slippage Uint,
+	restLocked Uint,
+) Order {
+	return Order{
+		id:                orderID,
+		ownerID:           ownerID,
+		symbolID:          symbolID,
+		orderType:         OrderTypeMarketToLimit,
+		side:              side,
+		direction:         direction,
+		timeInForce:       OrderTimeInForceGTC,
+		quantity:          quantity,
+		quoteQuantity:     quoteQuantity,
+		maxVisible:        NewMaxUint(),
+		marketSlippage:    slippage,
+		available:         restLocked,
+		restQuantity:      quantity,
+		restQuoteQuantity: quoteQuantity,
+		marketQuoteMode:   quantity.IsZero() && !quoteQuantity.IsZero(),
+	}
+}
+
 // NewStopOrder creates new stop order.
 func NewStopOrder(
 	symbolID uint32,
 	orderID uint64,
+	ownerID uint64,
 	side OrderSide,
 	direction OrderDirection,
 	timeInForce OrderTimeInForce,
@@ -72,6 +144,7 @@ func NewStopOrder(
 ) Order {
 	return Order{
 		id:                orderID,
+		ownerID:           ownerID,
 		symbolID:          symbolID,
 		orderType:         OrderTypeStop,
 		side:              side,
@@ -93,6 +166,7 @@ func NewStopOrder(
 func NewStopLimitOrder(
 	symbolID uint32,
 	orderID uint64,
+	ownerID uint64,
 	side OrderSide,
 	direction OrderDirection,
 	timeInForce OrderTimeInForce,
@@ -105,6 +179,7 @@ func NewStopLimitOrder(
 ) Order {
 	return Order{
 		id:            orderID,
+		ownerID:       ownerID,
 		symbolID:      symbolID,
 		orderType:     OrderTypeStopLimit,
 		side:          side,
@@ -124,6 +199,7 @@ func NewStopLimitOrder(
 func NewTrailingStopOrder(
 	symbolID uint32,
 	orderID uint64,
+	ownerID uint64,
 	side OrderSide,
 	direction OrderDirection,
 	timeInForce OrderTimeInForce,
@@ -138,6 +214,7 @@ func NewTrailingStopOrder(
 ) Order {
 	return Order{
 		id:               orderID,
+		ownerID:          ownerID,
 		symbolID:         symbolID,
 		orderType:        OrderTypeTrailingStop,
 		side:             side,
@@ -159,6 +236,7 @@ func NewTrailingStopOrder(
 func NewTrailingStopLimitOrder(
 	symbolID uint32,
 	orderID uint64,
+	ownerID uint64,
 	side OrderSide,
 	direction OrderDirection,
 	timeInForce OrderTimeInForce,
@@ -173,6 +251,7 @@ func NewTrailingStopLimitOrder(
 ) Order {
 	return Order{
 		id:               orderID,
+		ownerID:          ownerID,
 		symbolID:         symbolID,
 		orderType:        OrderTypeTrailingStopLimit,
 		side:             side,
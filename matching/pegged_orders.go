@@ -0,0 +1,166 @@
+package matching
+
+import (
+	"slices"
+
+	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
+)
+
+// PegReference is an enumeration of possible reference prices of pegged orders.
+type PegReference uint8
+
+const (
+	// Primary peg - the order is pegged to the top of the same side of the order book
+	// (the best bid for buy orders and the best ask for sell orders).
+	PegReferencePrimary PegReference = iota + 1
+	// Market peg - the order is pegged to the top of the opposite side of the order book
+	// (the best ask for buy orders and the best bid for sell orders).
+	PegReferenceMarket
+	// Midpoint peg - the order is pegged to the middle between the best bid and the best ask.
+	PegReferenceMidpoint
+)
+
+func (pr PegReference) String() string {
+	switch pr {
+	case PegReferencePrimary:
+		return "primary"
+	case PegReferenceMarket:
+		return "market"
+	case PegReferenceMidpoint:
+		return "midpoint"
+	default:
+		return "unknown"
+	}
+}
+
+// Valid returns true if the peg reference is known.
+func (pr PegReference) Valid() bool {
+	return pr >= PegReferencePrimary && pr <= PegReferenceMidpoint
+}
+
+////////////////////////////////////////////////////////////////
+// Pegged orders registry
+////////////////////////////////////////////////////////////////
+
+// peggedOrders keeps IDs of pegged orders of the order book sorted in ascending order,
+// so pegged orders are always repriced in the same order (also after the snapshot restore).
+// IDs are not removed when orders are deleted, so each ID is checked against the actual order.
+type peggedOrders struct {
+	ids []uint64
+
+	// Reference prices used by the last repricing
+	bid, ask Uint
+}
+
+// add registers the pegged order unless it is already registered.
+func (p *peggedOrders) add(orderID uint64) {
+	if i, found := slices.BinarySearch(p.ids, orderID); !found {
+		p.ids = slices.Insert(p.ids, i, orderID)
+	}
+}
+
+// remove unregisters the pegged order.
+func (p *peggedOrders) remove(orderID uint64) {
+	if i, found := slices.BinarySearch(p.ids, orderID); found {
+		p.ids = slices.Delete(p.ids, i, i+1)
+	}
+}
+
+////////////////////////////////////////////////////////////////
+// Pegged prices
+////////////////////////////////////////////////////////////////
+
+// pegReferencePrices returns the best bid and ask prices used as references of pegged orders.
+// Price levels which contain only pegged orders are skipped, so pegged orders never follow each other.
+// Zero bid and max ask are returned for the empty side of the order book.
+func (ob *OrderBook) pegReferencePrices() (Uint, Uint) {
+	bid, ok := pegTopPrice(&ob.bids)
+	if !ok {
+		bid = NewZeroUint()
+	}
+	ask, ok := pegTopPrice(&ob.asks)
+	if !ok {
+		ask = NewMaxUint()
+	}
+	return bid, ask
+}
+
+// pegTopPrice returns the price of the top price level of the tree with not pegged orders.
+// Price levels are walked from the top one, so only levels of pegged orders are passed.
+func pegTopPrice(tree *avl.Tree[Uint, *PriceLevelL3]) (Uint, bool) {
+	for node := tree.MostLeft(); node != nil; node = node.NextRight() {
+		for it := node.Value().Iterator(); it.Next(); {
+			if !it.Current().Value.IsPegged() {
+				return node.Key(), true
+			}
+		}
+	}
+	return NewZeroUint(), false
+}
+
+// pegOffsetSteps returns the absolute offset of the pegged order in price steps.
+func pegOffsetSteps(offset int64) uint64 {
+	if offset < 0 {
+		return uint64(-offset)
+	}
+	return uint64(offset)
+}
+
+// pegPrice returns the price of the pegged order for given reference prices.
+// False is returned if the reference price is not available or the pegged price
+// is outside of the symbol price limits.
+func (ob *OrderBook) pegPrice(order *Order, bid Uint, ask Uint) (Uint, bool) {
+	step := ob.symbol.priceLimits.Step
+	hasBid, hasAsk := !bid.IsZero(), !ask.IsMax()
+
+	// Reference price rounded to the price step in the passive direction
+	var price Uint
+	switch {
+	case order.pegReference == PegReferencePrimary && order.IsBuy() && hasBid,
+		order.pegReference == PegReferenceMarket && order.IsSell() && hasBid:
+		price = bid
+	case order.pegReference == PegReferencePrimary && order.IsSell() && hasAsk,
+		order.pegReference == PegReferenceMarket && order.IsBuy() && hasAsk:
+		price = ask
+	case order.pegReference == PegReferenceMidpoint && hasBid && hasAsk:
+		low, high := Min(bid, ask), Max(bid, ask)
+		price = low.Add(high.Sub(low).Div64(2))
+	default:
+		return NewZeroUint(), false
+	}
+	if _, rem := price.QuoRem(step); !rem.IsZero() {
+		price = ApplySteps(price, step)
+		if order.IsSell() {
+			price = price.Add(step)
+		}
+	}
+
+	// Offset in price steps, it is validated against price limits, so it does not overflow by itself
+	offset := step.Mul64(pegOffsetSteps(order.pegOffset))
+	if order.pegOffset < 0 {
+		if price.LessThanOrEqualTo(offset) {
+			return NewZeroUint(), false
+		}
+		price = price.Sub(offset)
+	} else {
+		if NewMaxUint().Sub(price).LessThan(offset) {
+			return NewZeroUint(), false
+		}
+		price = price.Add(offset)
+	}
+
+	// Cap price limits the pegged price
+	if !order.pegCap.IsZero() {
+		if order.IsBuy() {
+			price = Min(price, order.pegCap)
+		} else {
+			price = Max(price, order.pegCap)
+		}
+	}
+
+	if price.LessThan(ob.symbol.priceLimits.Min) || price.GreaterThan(ob.symbol.priceLimits.Max) {
+		return NewZeroUint(), false
+	}
+
+	return price, true
+}
@@ -0,0 +1,94 @@
+package matching
+
+// PriceBandReference is an enumeration of possible reference prices of the price band.
+type PriceBandReference uint8
+
+const (
+	// PriceBandReferenceMarket uses the last trade price (market price) as the reference price.
+	PriceBandReferenceMarket PriceBandReference = iota + 1
+	// PriceBandReferenceMark uses the mark price as the reference price.
+	PriceBandReferenceMark
+)
+
+func (r PriceBandReference) String() string {
+	switch r {
+	case PriceBandReferenceMarket:
+		return "market"
+	case PriceBandReferenceMark:
+		return "mark"
+	default:
+		return "unknown"
+	}
+}
+
+// PriceBandKind is an enumeration of possible kinds of the price band distance.
+type PriceBandKind uint8
+
+const (
+	// PriceBandKindAbsolute means the distance is the absolute price difference from the reference price.
+	PriceBandKindAbsolute PriceBandKind = iota + 1
+	// PriceBandKindPercent means the distance is the percentage of the reference price
+	// with 0.01% precision (1 means 0.01%, 10000 means 100%).
+	PriceBandKindPercent
+)
+
+func (k PriceBandKind) String() string {
+	switch k {
+	case PriceBandKindAbsolute:
+		return "absolute"
+	case PriceBandKindPercent:
+		return "percent"
+	default:
+		return "unknown"
+	}
+}
+
+// PriceBand limits prices of orders and trades by the distance from the reference price.
+// Static price band is anchored to the reference price when the continuous trading is started,
+// dynamic price band follows the current reference price.
+// Zero distance means the price band is disabled.
+type PriceBand struct {
+	Reference PriceBandReference
+	Kind      PriceBandKind
+
+	// Distance from the reference price interpreted according to the kind.
+	Distance Uint
+}
+
+// Enabled returns true if the price band is enabled.
+func (b PriceBand) Enabled() bool {
+	return !b.Distance.IsZero()
+}
+
+// Valid returns true if the price band is disabled or has the known reference price and kind.
+func (b PriceBand) Valid() bool {
+	if !b.Enabled() {
+		return true
+	}
+
+	return (b.Reference == PriceBandReferenceMarket || b.Reference == PriceBandReferenceMark) &&
+		(b.Kind == PriceBandKindAbsolute || b.Kind == PriceBandKindPercent)
+}
+
+// contains returns true if the price is within the price band around the given reference price.
+// Any price is within the disabled price band or the band without the reference price.
+func (b PriceBand) contains(reference Uint, price Uint) bool {
+	if !b.Enabled() || reference.IsZero() {
+		return true
+	}
+
+	// Convert percentage distance into absolute one
+	distance := b.Distance
+	if b.Kind == PriceBandKindPercent {
+		distance = distance.Mul(reference).Div64(10000)
+	}
+
+	if reference.GreaterThan(distance) && price.LessThan(reference.Sub(distance)) {
+		return false
+	}
+	if reference.LessThan(NewMaxUint().Sub(distance)) && price.GreaterThan(reference.Add(distance)) {
+		return false
+	}
+
+	return true
+}
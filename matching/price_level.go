@@ -4,10 +4,12 @@ import (
 	"github.com/cryptonstudio/crypton-matching-engine/types/list"
 )
 
-// PriceLevelL2 contains price and visible volume in order bool.
+// PriceLevelL2 contains price, total/visible volume and amount of orders of the price level in order book.
 type PriceLevelL2 struct {
-	Price  uint64
-	Volume uint64
+	Price   Uint // price of the price level
+	Volume  Uint // total volume of the price level
+	Visible Uint // visible volume of the price level
+	Orders  int  // amount of orders queued in the price level
 }
 
 // PriceLevelL3 contains price and total/visible volume in order bool and encapsulates order queue management.
@@ -62,6 +64,16 @@ func (pl *PriceLevelL3) Iterator() list.Iterator[*Order] {
 	return pl.queue.Iterator()
 }
 
+// L2 returns the aggregated state of the price level.
+func (pl *PriceLevelL3) L2() PriceLevelL2 {
+	return PriceLevelL2{
+		Price:   pl.price,
+		Volume:  pl.volume,
+		Visible: pl.visible,
+		Orders:  pl.queue.Len(),
+	}
+}
+
 // Clean cleans the price level by removing all queued orders.
 func (pl *PriceLevelL3) Clean() {
 	pl.price = NewZeroUint()
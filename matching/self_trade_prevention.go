@@ -0,0 +1,35 @@
+package matching
+
+// SelfTradePrevention is an enumeration of possible self-trade prevention modes.
+// Self-trade prevention is applied when two crossed orders have the same non-zero owner,
+// instead of the trade the orders are canceled according to the mode of the newest order
+// (or the mode of the order book symbol if the newest order has no own mode).
+// Zero value means self-trades are allowed.
+type SelfTradePrevention uint8
+
+const (
+	// SelfTradePreventionCancelNewest cancels the newest (incoming) order.
+	SelfTradePreventionCancelNewest SelfTradePrevention = iota + 1
+	// SelfTradePreventionCancelOldest cancels the oldest (resting) order.
+	SelfTradePreventionCancelOldest
+	// SelfTradePreventionCancelBoth cancels both orders.
+	SelfTradePreventionCancelBoth
+	// SelfTradePreventionDecrementAndCancel decrements both orders by the smaller
+	// quantity, so the smaller order is canceled and the larger one is reduced.
+	SelfTradePreventionDecrementAndCancel
+)
+
+func (stp SelfTradePrevention) String() string {
+	switch stp {
+	case SelfTradePreventionCancelNewest:
+		return "cancel-newest"
+	case SelfTradePreventionCancelOldest:
+		return "cancel-oldest"
+	case SelfTradePreventionCancelBoth:
+		return "cancel-both"
+	case SelfTradePreventionDecrementAndCancel:
+		return "decrement-and-cancel"
+	default:
+		return "none"
+	}
+}
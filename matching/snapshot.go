@@ -0,0 +1,337 @@
+package matching
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/cryptonstudio/crypton-matching-engine/types/avl"
+)
+
+const (
+	// snapshotMagic marks the beginning of the engine snapshot.
+	snapshotMagic uint32 = 0x50534d43 // "CMSP"
+
+	// snapshotVersion is the version of the engine snapshot binary format.
+	snapshotVersion uint32 = 1
+)
+
+// Snapshot writes binary representation of the whole engine state to the given writer.
+// Every order book is serialized with its symbol, prices and all resting orders
+// (including stop and trailing stop orders) in the price-time priority order,
+// so the state can be restored later with Restore() method.
+// The snapshot contains the sequence number of the last included command, so the
+// journal tail can be replayed over the restored engine with Replay() method.
+// In multithread mode each order book is serialized by its own goroutine after
+// all previously enqueued tasks are performed.
+func (e *Engine) Snapshot(w io.Writer) error {
+	type result struct {
+		id   uint32
+		buf  *bytes.Buffer
+		done <-chan error
+	}
+
+	// Schedule snapshot tasks with locked commands, so all order books are
+	// serialized exactly after the command with the stored sequence number
+	e.lockCommands()
+	sequence := e.Sequence()
+	now := e.Time()
+	matching := e.matching
+	eventTicket := e.takeEventTurn()
+	results := make([]result, 0, e.orderBooksCount)
+	enqueues := make([]func(), 0, e.orderBooksCount)
+	for i, c := 0, len(e.orderBooks); i < c; i++ {
+		if e.orderBooks[i] == nil {
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		task := func(ob *OrderBook) error {
+			return ob.snapshot(buf)
+		}
+		enqueue, done := e.scheduleOrderBookTaskAsync(e.orderBooks[i], task)
+		enqueues = append(enqueues, enqueue)
+		results = append(results, result{
+			id:   uint32(i),
+			buf:  buf,
+			done: done,
+		})
+	}
+	e.unlockCommands()
+
+	for _, enqueue := range enqueues {
+		enqueue()
+	}
+
+	var err error
+	for _, r := range results {
+		// Wait for all tasks even if some of them failed
+		if taskErr := <-r.done; taskErr != nil && err == nil {
+			err = fmt.Errorf("failed to snapshot order book (id: %d): %w", r.id, taskErr)
+		}
+	}
+
+	// Events of all included commands are passed before the turn of the snapshot
+	var eventSequence uint64
+	e.inEventTurn(eventTicket, func() {
+		eventSequence = e.EventSequence()
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := newEncoder(w)
+	enc.writeUint32(snapshotMagic)
+	enc.writeUint32(snapshotVersion)
+	enc.writeUint64(sequence)
+	enc.writeTime(now)
+	enc.writeUint64(eventSequence)
+	enc.writeBool(matching)
+	enc.writeUint32(uint32(len(results)))
+	for _, r := range results {
+		enc.write(r.buf.Bytes())
+	}
+
+	return enc.flush()
+}
+
+// Restore reads binary representation of the engine state written by Snapshot() method
+// and adds all stored order books to the engine. Restored orders keep their positions
+// in price level queues. Handler is not called while restoring.
+// The engine sequence, time, event sequence and automatic matching flag are restored as well.
+func (e *Engine) Restore(r io.Reader) error {
+	dec := newDecoder(r)
+	magic := dec.readUint32()
+	version := dec.readUint32()
+	if dec.err != nil {
+		return fmt.Errorf("failed to read snapshot header: %w", dec.err)
+	}
+	if magic != snapshotMagic || version != snapshotVersion {
+		return ErrInvalidSnapshot
+	}
+	sequence := dec.readUint64()
+	now := dec.readTime()
+	eventSequence := dec.readUint64()
+	matching := dec.readBool()
+	count := dec.readUint32()
+	if dec.err != nil {
+		return fmt.Errorf("failed to read snapshot header: %w", dec.err)
+	}
+
+	// Order books are added only after the whole snapshot is read, so the engine
+	// is left untouched by broken snapshots
+	orderBooks := make([]*OrderBook, 0, min(count, 1024))
+	clean := func() {
+		for _, ob := range orderBooks {
+			ob.Clean()
+		}
+	}
+	ids := make(map[uint32]struct{}, min(count, 1024))
+	for range count {
+		ob, err := restoreOrderBook(dec, newOrderBookConfig(e.orderBookOpts))
+		if err != nil {
+			clean()
+			return err
+		}
+		orderBooks = append(orderBooks, ob)
+
+		id := ob.symbol.id
+		if _, ok := ids[id]; ok {
+			clean()
+			return ErrOrderBookDuplicate
+		}
+		ids[id] = struct{}{}
+	}
+	if dec.err != nil {
+		clean()
+		return fmt.Errorf("failed to read snapshot: %w", dec.err)
+	}
+
+	// Order books are added with locked commands, so no commands are performed in between
+	e.lockCommands()
+	defer e.unlockCommands()
+
+	prevMatching := e.matching
+	e.matching = matching
+	for i, ob := range orderBooks {
+		if err := e.insertOrderBook(ob); err != nil {
+			// Roll back already added order books, no tasks could be enqueued for them
+			for _, inserted := range orderBooks[:i] {
+				e.orderBooks[inserted.symbol.id] = nil
+				e.orderBooksCount--
+				if e.multithread {
+					close(inserted.chanTasks)
+					inserted.wg.Wait()
+				}
+			}
+			e.matching = prevMatching
+			clean()
+			return err
+		}
+	}
+
+	e.restoredSequence = sequence
+	if sequence > e.Sequence() {
+		atomic.StoreUint64(&e.sequence, sequence)
+	}
+	if now.UnixNano() > atomic.LoadInt64(&e.time) {
+		atomic.StoreInt64(&e.time, now.UnixNano())
+	}
+	if eventSequence > e.EventSequence() {
+		atomic.StoreUint64(&e.eventSequence, eventSequence)
+	}
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+// Order book snapshot
+////////////////////////////////////////////////////////////////
+
+func (ob *OrderBook) snapshot(w io.Writer) error {
+	enc := newEncoder(w)
+
+	enc.writeSymbol(ob.symbol)
+	enc.writeStopPriceModeConfig(stopPriceModeConfig(ob.spModes))
+	enc.writeUint(ob.marketPrice)
+	enc.writeUint(ob.markPrice)
+	enc.writeUint(ob.indexPrice)
+	enc.writeUint(ob.lastBidPrice)
+	enc.writeUint(ob.lastAskPrice)
+	enc.writeUint(ob.matchingBidPrice)
+	enc.writeUint(ob.matchingAskPrice)
+	enc.writeUint(ob.trailingBidPrice)
+	enc.writeUint(ob.trailingAskPrice)
+	enc.writeUint64(ob.lastUpdateID)
+	enc.writeUint64(ob.lastTradeID)
+	enc.writeUint64(ob.lastPlacement)
+	enc.writeUint64(ob.seed)
+	enc.writeTime(ob.now)
+	enc.writeUint8(uint8(ob.state))
+	enc.writeUint(ob.referencePrice)
+	enc.writeMatchingPolicy(ob.policy)
+
+	// Orders are stored in the price-time priority order for each tree
+	trees := ob.trees()
+	orders := make([]*Order, 0, ob.orders.Len())
+	for _, tree := range trees {
+		tree.IterateInOrder(func(priceLevel *PriceLevelL3) bool {
+			it := priceLevel.Iterator()
+			for it.Next() {
+				orders = append(orders, it.Current().Value)
+			}
+			return false
+		})
+	}
+
+	enc.writeUint32(uint32(len(orders)))
+	for _, order := range orders {
+		enc.writeOrder(order)
+	}
+
+	return enc.flush()
+}
+
+func restoreOrderBook(dec *decoder, config orderBookConfig) (*OrderBook, error) {
+	symbol := dec.readSymbol()
+	spModesConfig := dec.readStopPriceModeConfig()
+	if dec.err != nil {
+		return nil, fmt.Errorf("failed to read order book: %w", dec.err)
+	}
+	if !symbol.Valid() {
+		return nil, ErrInvalidSymbol
+	}
+
+	ob := newOrderBook(symbol, spModesConfig, config)
+	ob.marketPrice = dec.readUint()
+	ob.markPrice = dec.readUint()
+	ob.indexPrice = dec.readUint()
+	ob.lastBidPrice = dec.readUint()
+	ob.lastAskPrice = dec.readUint()
+	ob.matchingBidPrice = dec.readUint()
+	ob.matchingAskPrice = dec.readUint()
+	ob.trailingBidPrice = dec.readUint()
+	ob.trailingAskPrice = dec.readUint()
+	ob.lastUpdateID = dec.readUint64()
+	ob.lastTradeID = dec.readUint64()
+	lastPlacement := dec.readUint64()
+	ob.seed = dec.readUint64()
+	ob.now = dec.readTime()
+	ob.state = TradingState(dec.readUint8())
+	ob.referencePrice = dec.readUint()
+	ob.policy = dec.readMatchingPolicy()
+	if dec.err != nil {
+		ob.Clean()
+		return nil, fmt.Errorf("failed to read order book: %w", dec.err)
+	}
+	if !ob.state.Valid() {
+		ob.Clean()
+		return nil, ErrInvalidSnapshot
+	}
+
+	count := dec.readUint32()
+	if dec.err != nil {
+		ob.Clean()
+		return nil, fmt.Errorf("failed to read order book: %w", dec.err)
+	}
+	for range count {
+		order := ob.allocator.GetOrder()
+		dec.readOrder(order)
+		if dec.err != nil {
+			ob.Clean()
+			return nil, fmt.Errorf("failed to read order: %w", dec.err)
+		}
+		if order.symbolID != symbol.id {
+			ob.Clean()
+			return nil, ErrInvalidSnapshot
+		}
+		if _, ok := ob.orders.Get(order.id); ok {
+			ob.Clean()
+			return nil, ErrOrderDuplicate
+		}
+
+		// Orders are enqueued in the stored order, so queues keep the priority
+		placement := order.placement
+		ob.orders.Set(order.id, order)
+		if _, err := ob.addOrder(ob.treeForOrder(order), order); err != nil {
+			ob.Clean()
+			return nil, fmt.Errorf("failed to restore order (id: %d): %w", order.id, err)
+		}
+		order.placement = placement
+	}
+	ob.lastPlacement = lastPlacement
+
+	return ob, nil
+}
+
+////////////////////////////////////////////////////////////////
+// Internal helpers
+////////////////////////////////////////////////////////////////
+
+// trees returns all order book trees in the fixed order.
+func (ob *OrderBook) trees() []*avl.Tree[Uint, *PriceLevelL3] {
+	return []*avl.Tree[Uint, *PriceLevelL3]{
+		&ob.bids,
+		&ob.asks,
+		&ob.buyStop,
+		&ob.sellStop,
+		&ob.trailingBuyStop,
+		&ob.trailingSellStop,
+	}
+}
+
+func stopPriceModeConfig(modes []StopPriceMode) StopPriceModeConfig {
+	var config StopPriceModeConfig
+	for _, mode := range modes {
+		switch mode {
+		case StopPriceModeMarket:
+			config.Market = true
+		case StopPriceModeMark:
+			config.Mark = true
+		case StopPriceModeIndex:
+			config.Index = true
+		}
+	}
+	return config
+}
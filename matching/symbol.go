@@ -7,6 +7,13 @@ type Symbol struct {
 	priceLimits        Limits
 	lotSizeLimits      Limits
 	quoteLotSizeLimits Limits
+
+	// Default self-trade prevention mode for orders of the symbol
+	selfTradePrevention SelfTradePrevention
+
+	// Price bands limiting prices of limit orders and trades
+	staticPriceBand  PriceBand
+	dynamicPriceBand PriceBand
 }
 
 // NewSymbol creates new symbol with specified ID and name.
@@ -47,8 +54,39 @@ func (s Symbol) LotSizeLimits() Limits {
 	return s.lotSizeLimits
 }
 
+// SelfTradePrevention returns the default self-trade prevention mode of the symbol.
+func (s Symbol) SelfTradePrevention() SelfTradePrevention {
+	return s.selfTradePrevention
+}
+
+// SetSelfTradePrevention sets the default self-trade prevention mode for orders of the symbol.
+// The mode is used for orders without their own self-trade prevention mode.
+func (s *Symbol) SetSelfTradePrevention(mode SelfTradePrevention) {
+	s.selfTradePrevention = mode
+}
+
+// StaticPriceBand returns the static price band of the symbol.
+func (s Symbol) StaticPriceBand() PriceBand {
+	return s.staticPriceBand
+}
+
+// DynamicPriceBand returns the dynamic price band of the symbol.
+func (s Symbol) DynamicPriceBand() PriceBand {
+	return s.dynamicPriceBand
+}
+
+// SetPriceBands sets the static and the dynamic price bands of the symbol.
+// Limit orders outside of price bands are rejected, and the order book is halted
+// instead of the execution outside of price bands (circuit breaker).
+func (s *Symbol) SetPriceBands(static PriceBand, dynamic PriceBand) {
+	s.staticPriceBand = static
+	s.dynamicPriceBand = dynamic
+}
+
 func (s Symbol) Valid() bool {
-	return s.priceLimits.Valid() && s.lotSizeLimits.Valid()
+	return s.priceLimits.Valid() && s.lotSizeLimits.Valid() &&
+		s.selfTradePrevention <= SelfTradePreventionDecrementAndCancel &&
+		s.staticPriceBand.Valid() && s.dynamicPriceBand.Valid()
 }
 
 func (s Symbol) CalcQtyWithLimits(quoteQty, price Uint) Uint {
@@ -0,0 +1,198 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestAuction(t *testing.T) {
+	trade := func(maker, taker, p, qty uint64) tradeArgs {
+		return tradeBetween(maker, taker).with(price(p), price(qty), price(p*qty))
+	}
+
+	t.Run("maximum volume", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, trade(1, 4, 11, 2), trade(2, 5, 11, 2))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 12, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 11, 3)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(4, matching.OrderSideSell, 10, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(5, matching.OrderSideSell, 11, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(6, matching.OrderSideSell, 13, 1)))
+
+		// Orders are accumulated without matching
+		require.Equal(t, 6, ob.Size())
+
+		clearingPrice, volume := ob.AuctionPrice()
+		require.True(t, clearingPrice.Equals(price(11)))
+		require.True(t, volume.Equals(price(4)))
+
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.False(t, ob.InAuction())
+		require.Equal(t, 3, ob.Size())
+		require.True(t, ob.Order(2).RestQuantity().Equals(price(1)))
+		require.True(t, ob.GetMarketPrice().Equals(price(11)))
+	})
+
+	t.Run("minimum imbalance", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, trade(1, 2, 11, 2))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 12)
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 12, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 12, 1)))
+
+		clearingPrice, volume := ob.AuctionPrice()
+		require.True(t, clearingPrice.Equals(price(11)))
+		require.True(t, volume.Equals(price(2)))
+
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.NotNil(t, ob.Order(3))
+	})
+
+	t.Run("reference price", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, trade(1, 2, 11, 1))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 11)
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 12, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 10, 1)))
+
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("all-or-none order at the top", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, trade(2, 3, 12, 2))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 12)
+		require.NoError(t, engine.StartAuction(symbolID))
+		order := limitOrder(1, matching.OrderSideBuy, 12, 5)
+		order.SetAllOrNone(true)
+		require.NoError(t, engine.AddOrder(order))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 12, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 11, 2)))
+
+		// All-or-none order is skipped, other crossed orders are still executed by the uncrossing
+		engine.DisableMatching()
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.Equal(t, 1, ob.Size())
+		require.NotNil(t, ob.Order(1))
+		require.Nil(t, ob.TopAsk())
+	})
+
+	t.Run("all-or-none orders at several levels", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, trade(2, 5, 11, 1), trade(4, 5, 11, 1))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 12)
+		require.NoError(t, engine.StartAuction(symbolID))
+		order := limitOrder(1, matching.OrderSideBuy, 12, 5)
+		order.SetAllOrNone(true)
+		require.NoError(t, engine.AddOrder(order))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 12, 1)))
+		order = limitOrder(3, matching.OrderSideBuy, 11, 4)
+		order.SetAllOrNone(true)
+		require.NoError(t, engine.AddOrder(order))
+		require.NoError(t, engine.AddOrder(limitOrder(4, matching.OrderSideBuy, 11, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(5, matching.OrderSideSell, 10, 2)))
+
+		// Skipped all-or-none orders are not included into the executable volume
+		clearingPrice, volume := ob.AuctionPrice()
+		require.True(t, clearingPrice.Equals(price(11)))
+		require.True(t, volume.Equals(price(2)))
+
+		engine.DisableMatching()
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.Equal(t, 2, ob.Size())
+		require.NotNil(t, ob.Order(1))
+		require.NotNil(t, ob.Order(3))
+	})
+
+	t.Run("only all-or-none orders crossed", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 12)
+		require.NoError(t, engine.StartAuction(symbolID))
+		order := limitOrder(1, matching.OrderSideBuy, 12, 5)
+		order.SetAllOrNone(true)
+		require.NoError(t, engine.AddOrder(order))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+
+		_, volume := ob.AuctionPrice()
+		require.True(t, volume.IsZero())
+
+		engine.DisableMatching()
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("not crossed", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+
+		_, volume := ob.AuctionPrice()
+		require.True(t, volume.IsZero())
+
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("invalid transitions", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrAuctionStarted))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrAuctionNotStarted))
+		setupMockHandler(t, handler)
+
+		engine, _, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.ErrorIs(t, engine.StartAuction(symbolID), matching.ErrAuctionStarted)
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.ErrorIs(t, engine.StopAuction(symbolID), matching.ErrAuctionNotStarted)
+		require.ErrorIs(t, engine.StartAuction(symbolID+1), matching.ErrOrderBookNotFound)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler)
+
+		engine, _, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 12, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 10, 1)))
+
+		var buf bytes.Buffer
+		require.NoError(t, engine.Snapshot(&buf))
+
+		restoredHandler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, restoredHandler, trade(1, 2, 10, 1))
+
+		restored := matching.NewEngine(restoredHandler, false)
+		require.NoError(t, restored.Restore(&buf))
+		ob := restored.OrderBook(symbolID)
+		require.True(t, ob.InAuction())
+		require.Equal(t, 2, ob.Size())
+
+		require.NoError(t, restored.StopAuction(symbolID))
+		require.Equal(t, 0, ob.Size())
+	})
+}
@@ -0,0 +1,110 @@
+package matching_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestAcknowledgements(t *testing.T) {
+	// Command time is kept in unix nanoseconds, so it is compared in the local location
+	now := time.Unix(0, testTime.UnixNano())
+
+	for _, multithread := range []bool{false, true} {
+		t.Run(fmt.Sprintf("trades multithread=%t", multithread), func(t *testing.T) {
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			setupMockHandler(t, handler)
+			engine, _, _ := newTestEngine(t, handler, multithread, 100)
+			ctx := context.Background()
+
+			trades, err := engine.AddOrderWait(ctx, limitOrder(1, matching.OrderSideSell, 100, 5))
+			require.NoError(t, err)
+			require.Empty(t, trades)
+			trades, err = engine.AddOrderWait(ctx, limitOrder(2, matching.OrderSideSell, 101, 5))
+			require.NoError(t, err)
+			require.Empty(t, trades)
+
+			ack := engine.AddOrderAsync(limitOrder(10, matching.OrderSideBuy, 101, 8))
+			require.NoError(t, ack.Wait(ctx))
+			require.True(t, ack.Accepted())
+			require.Equal(t, engine.Sequence(), ack.Sequence())
+			require.Equal(t, []matching.Trade{
+				{
+					ID: 1, Time: now, AggressorSide: matching.OrderSideBuy,
+					MakerOrderID: 1, MakerSide: matching.OrderSideSell, TakerOrderID: 10, TakerSide: matching.OrderSideBuy,
+					Price: price(100), Quantity: price(5), QuoteQuantity: price(500),
+				},
+				{
+					ID: 2, Time: now, AggressorSide: matching.OrderSideBuy,
+					MakerOrderID: 2, MakerSide: matching.OrderSideSell, TakerOrderID: 10, TakerSide: matching.OrderSideBuy,
+					Price: price(101), Quantity: price(3), QuoteQuantity: price(303),
+				},
+			}, ack.Trades())
+		})
+
+		t.Run(fmt.Sprintf("rejects multithread=%t", multithread), func(t *testing.T) {
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrInvalidOrderPrice))
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrOrderDuplicate))
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(2), errorIs(matching.ErrInvalidOrderSide))
+			// Errors are still passed to the handler
+			handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderDuplicate))
+			setupMockHandler(t, handler)
+			engine, _, _ := newTestEngine(t, handler, multithread, 100)
+			ctx := context.Background()
+
+			// Rejected by the validation, the rejected command is journaled
+			ack := engine.AddOrderAsync(limitOrder(1, matching.OrderSideSell, 0, 5))
+			<-ack.Done()
+			require.ErrorIs(t, ack.Err(), matching.ErrInvalidOrderPrice)
+			require.False(t, ack.Accepted())
+			require.NotZero(t, ack.Sequence())
+			require.Equal(t, engine.Sequence(), ack.Sequence())
+
+			// Rejected by the order book
+			_, err := engine.AddOrderWait(ctx, limitOrder(1, matching.OrderSideSell, 100, 5))
+			require.NoError(t, err)
+			ack = engine.AddOrderAsync(limitOrder(1, matching.OrderSideSell, 100, 5))
+			require.ErrorIs(t, ack.Wait(ctx), matching.ErrOrderDuplicate)
+			require.NotZero(t, ack.Sequence())
+			require.Empty(t, ack.Trades())
+
+			_, err = engine.AddOrderWait(ctx, limitOrder(2, 0xff, 100, 5))
+			require.ErrorIs(t, err, matching.ErrInvalidOrderSide)
+		})
+	}
+
+	t.Run("journal failure", func(t *testing.T) {
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		engine.SetJournal(matching.NewJournal(failingWriter{}))
+
+		// Commands which are not journaled have no sequence number, both valid and invalid ones
+		ack := engine.AddOrderAsync(limitOrder(1, matching.OrderSideSell, 100, 5))
+		require.Error(t, ack.Err())
+		require.Zero(t, ack.Sequence())
+		ack = engine.AddOrderAsync(limitOrder(2, matching.OrderSideSell, 0, 5))
+		require.Error(t, ack.Err())
+		require.NotErrorIs(t, ack.Err(), matching.ErrInvalidOrderPrice)
+		require.Zero(t, ack.Sequence())
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// The result is preferred over the context error if the command is already performed
+		trades, err := engine.AddOrderWait(ctx, limitOrder(1, matching.OrderSideSell, 100, 5))
+		require.NoError(t, err)
+		require.Empty(t, trades)
+	})
+}
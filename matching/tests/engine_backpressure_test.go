@@ -0,0 +1,167 @@
+package matching_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestOrderBookTaskQueue(t *testing.T) {
+	t.Run("queue size", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, true, 100, matching.WithTaskQueueSize(8))
+		require.Equal(t, 8, ob.TaskQueueSize())
+
+		// The event of the added order book is passed by its task
+		_, err := engine.Depth(symbolID, 1)
+		require.NoError(t, err)
+		require.Equal(t, 0, ob.TaskQueueDepth())
+
+		_, ob, _ = newTestEngine(t, handler, true, 100, matching.WithTaskQueueSize(0))
+		require.Equal(t, 256, ob.TaskQueueSize())
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		blocked, released := make(chan struct{}), make(chan struct{})
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddOrder(gomock.Any(), orderWithID(1)).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				close(blocked)
+				<-released
+			})
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, true, 100, matching.WithTaskQueueSize(1))
+
+		// The first task blocks the order book and the second one fills the queue
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		<-blocked
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 98, 1)))
+		require.Equal(t, 1, ob.TaskQueueDepth())
+
+		// Rejected commands are not journaled
+		sequence := engine.Sequence()
+		require.ErrorIs(t, engine.TryAddOrder(limitOrder(3, matching.OrderSideBuy, 97, 1)), matching.ErrQueueFull)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, engine.AddOrderContext(ctx, limitOrder(3, matching.OrderSideBuy, 97, 1)), context.DeadlineExceeded)
+		require.Equal(t, sequence, engine.Sequence())
+
+		// The waiting caller proceeds as soon as the queue has space
+		done := make(chan error, 1)
+		go func() {
+			done <- engine.AddOrderContext(context.Background(), limitOrder(3, matching.OrderSideBuy, 97, 1))
+		}()
+		close(released)
+		require.NoError(t, <-done)
+
+		engine.Stop(false)
+		require.Equal(t, 3, ob.Size())
+		require.Equal(t, sequence+1, engine.Sequence())
+	})
+
+	t.Run("several waiting callers", func(t *testing.T) {
+		blocked, released := make(chan struct{}), make(chan struct{})
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddOrder(gomock.Any(), orderWithID(1)).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				close(blocked)
+				<-released
+			})
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, true, 100, matching.WithTaskQueueSize(1))
+
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		<-blocked
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 98, 1)))
+
+		// All callers waiting for the space in the full queue are woken up
+		done := make(chan error, 4)
+		for id := uint64(3); id < 7; id++ {
+			go func() {
+				done <- engine.AddOrderContext(context.Background(), limitOrder(id, matching.OrderSideBuy, 100-id, 1))
+			}()
+		}
+		close(released)
+		for range 4 {
+			select {
+			case err := <-done:
+				require.NoError(t, err)
+			case <-time.After(time.Second):
+				t.Fatal("waiting caller is not woken up")
+			}
+		}
+
+		engine.Stop(false)
+		require.Equal(t, 6, ob.Size())
+	})
+
+	t.Run("other order books", func(t *testing.T) {
+		blocked, released := make(chan struct{}), make(chan struct{})
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddOrder(gomock.Any(), orderWithID(1)).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				close(blocked)
+				<-released
+			})
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, true, 100, matching.WithTaskQueueSize(1))
+		_, err := engine.AddOrderBook(matching.NewSymbol(symbolID+1, "ETH-USDT"), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		<-blocked
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 98, 1)))
+
+		// The caller waiting for the space in the full queue does not block commands of other order books
+		sequence := engine.Sequence()
+		waiting := make(chan error, 1)
+		go func() {
+			waiting <- engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 97, 1))
+		}()
+		require.Eventually(t, func() bool { return engine.Sequence() == sequence+1 }, time.Second, time.Millisecond)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- engine.AddOrder(newLimitOrder(symbolID+1, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 1))
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			close(released)
+			t.Fatal("command of other order book is blocked")
+		}
+
+		close(released)
+		require.NoError(t, <-waiting)
+		engine.Stop(false)
+		require.Equal(t, 3, ob.Size())
+	})
+
+	t.Run("single-thread mode", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(3), errorIs(matching.ErrOrderDuplicate))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderDuplicate))
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 100, matching.WithTaskQueueSize(1))
+		require.NoError(t, engine.TryAddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.TryAddOrder(limitOrder(2, matching.OrderSideBuy, 98, 1)))
+		require.NoError(t, engine.AddOrderContext(context.Background(), limitOrder(3, matching.OrderSideBuy, 97, 1)))
+		require.Equal(t, 3, ob.Size())
+
+		// Validation errors are returned as is
+		require.ErrorIs(t, engine.TryAddOrder(limitOrder(3, matching.OrderSideBuy, 97, 1)), matching.ErrOrderDuplicate)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, engine.AddOrderContext(ctx, limitOrder(4, matching.OrderSideBuy, 96, 1)), context.Canceled)
+		require.Nil(t, ob.Order(4))
+	})
+}
@@ -10,9 +10,10 @@ import (
 	"testing"
 	"unsafe"
 
-	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
 )
 
 func FuzzChainOrders(f *testing.F) {
@@ -380,25 +381,25 @@ func parseOrderSequence(side matching.OrderSide, startID uint64, inp []byte) ([]
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{matching.NewLimitOrder(
-					symbolID, id, side, dir, tif, price, quantity, visible, restLocked,
+					symbolID, id, 0, side, dir, tif, price, quantity, visible, restLocked,
 				)}})
 		case matching.OrderTypeStopLimit:
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{matching.NewStopLimitOrder(
-					symbolID, id, side, dir, tif, price, priceMode, stopPrice, quantity, visible, restLocked,
+					symbolID, id, 0, side, dir, tif, price, priceMode, stopPrice, quantity, visible, restLocked,
 				)}})
 		case matching.OrderTypeMarket:
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{matching.NewMarketOrder(
-					symbolID, id, side, dir, matching.OrderTimeInForceIOC, modQQ(modQuote, 0, quantity),
+					symbolID, id, 0, side, dir, matching.OrderTimeInForceIOC, modQQ(modQuote, 0, quantity),
 					modQQ(modQuote, 1, quantity), slippage, restLocked,
 				)}})
 		case matching.OrderTypeStop:
 			result = append(result, sequenceItem{
 				typ,
-				[]matching.Order{matching.NewStopOrder(symbolID, id, side, dir, matching.OrderTimeInForceIOC,
+				[]matching.Order{matching.NewStopOrder(symbolID, id, 0, side, dir, matching.OrderTimeInForceIOC,
 					priceMode, stopPrice, modQQ(modQuote, 0, quantity),
 					modQQ(modQuote, 1, quantity), slippage, restLocked,
 				)}})
@@ -409,11 +410,11 @@ func parseOrderSequence(side matching.OrderSide, startID uint64, inp []byte) ([]
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{
-					matching.NewStopLimitOrder(symbolID, id1, side, dir, tif, price,
+					matching.NewStopLimitOrder(symbolID, id1, 0, side, dir, tif, price,
 						priceMode, stopPrice,
 						quantity, visible, matching.NewZeroUint(),
 					),
-					matching.NewLimitOrder(symbolID, id2, side, dir, tif, price,
+					matching.NewLimitOrder(symbolID, id2, 0, side, dir, tif, price,
 						quantity, visible, restLocked,
 					),
 				}})
@@ -427,11 +428,11 @@ func parseOrderSequence(side matching.OrderSide, startID uint64, inp []byte) ([]
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{
-					matching.NewStopLimitOrder(symbolID, id1, side, dir, tif, tpPrice,
+					matching.NewStopLimitOrder(symbolID, id1, 0, side, dir, tif, tpPrice,
 						tpMode, u16U(dt.TpStopPrice),
 						quantity, visible, restLocked,
 					),
-					matching.NewStopLimitOrder(symbolID, id2, side, dir, tif, slPrice,
+					matching.NewStopLimitOrder(symbolID, id2, 0, side, dir, tif, slPrice,
 						slMode, u16U(dt.SlStopPrice),
 						quantity, visible, matching.NewZeroUint(),
 					),
@@ -445,13 +446,13 @@ func parseOrderSequence(side matching.OrderSide, startID uint64, inp []byte) ([]
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{
-					matching.NewStopOrder(symbolID, id1, side, dir, matching.OrderTimeInForceIOC,
+					matching.NewStopOrder(symbolID, id1, 0, side, dir, matching.OrderTimeInForceIOC,
 						tpMode, u16U(dt.TpStopPrice),
 						modQQ(modQuote, 0, u16U(dt.TpQuantity)),
 						modQQ(modQuote, 1, u16U(dt.TpQuantity)),
 						u16U(dt.TpSlippage), restLocked,
 					),
-					matching.NewStopOrder(symbolID, id2, side, dir, matching.OrderTimeInForceIOC,
+					matching.NewStopOrder(symbolID, id2, 0, side, dir, matching.OrderTimeInForceIOC,
 						slMode, u16U(dt.SlStopPrice),
 						modQQ(modQuote, 0, u16U(dt.SlQuantity)),
 						modQQ(modQuote, 1, u16U(dt.SlQuantity)),
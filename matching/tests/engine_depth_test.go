@@ -0,0 +1,108 @@
+package matching_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestOrderBookDepth(t *testing.T) {
+	prices := func(levels []matching.PriceLevelL2) []uint64 {
+		result := []uint64{}
+		for _, level := range levels {
+			result = append(result, level.Price.Div64(matching.UintPrecision).ToUint128().Lo)
+		}
+		return result
+	}
+
+	t.Run("levels", func(t *testing.T) {
+		// The depth is followed by price level updates starting from its ID
+		updates := uint64(0)
+		count := func(*matching.OrderBook, matching.PriceLevelUpdate) { updates++ }
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddPriceLevel(gomock.Any(), gomock.Any()).Do(count).AnyTimes()
+		handler.EXPECT().OnUpdatePriceLevel(gomock.Any(), gomock.Any()).Do(count).AnyTimes()
+		handler.EXPECT().OnDeletePriceLevel(gomock.Any(), gomock.Any()).Do(count).AnyTimes()
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		for i := uint64(0); i < 5; i++ {
+			require.NoError(t, engine.AddOrder(limitOrder(1+i, matching.OrderSideBuy, 95+i, 2)))
+			require.NoError(t, engine.AddOrder(limitOrder(11+i, matching.OrderSideSell, 101+i, 2)))
+		}
+		require.NoError(t, engine.AddOrder(newIcebergOrder(symbolID, 20, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 10, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(21, matching.OrderSideSell, 107, 1)))
+
+		// Stop orders are not included
+		require.NoError(t, engine.AddOrder(matching.NewStopOrder(
+			symbolID, 30, 0, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			matching.StopPriceModeMarket,
+			price(110), price(1), price(0),
+			price(1000000), price(1000000),
+		)))
+
+		depth := ob.Depth(3)
+		require.Equal(t, []uint64{99, 98, 97}, prices(depth.Bids))
+		require.Equal(t, []uint64{101, 102, 103}, prices(depth.Asks))
+		require.Equal(t, matching.PriceLevelL2{
+			Price:   price(99),
+			Volume:  price(12),
+			Visible: price(3),
+			Orders:  2,
+		}, depth.Bids[0])
+
+		require.Equal(t, updates, depth.UpdateID)
+
+		depth = ob.Depth(0)
+		require.Equal(t, []uint64{99, 98, 97, 96, 95}, prices(depth.Bids))
+		require.Equal(t, []uint64{101, 102, 103, 104, 105, 107}, prices(depth.Asks))
+	})
+
+	t.Run("many levels", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		for i, p := range rand.Perm(90) {
+			require.NoError(t, engine.AddOrder(limitOrder(uint64(i+1), matching.OrderSideSell, uint64(p+101), 1)))
+		}
+		for range 30 {
+			require.NoError(t, engine.DeleteOrder(symbolID, ob.TopAsk().Value().Queue().Front().Value.ID()))
+		}
+
+		asks := prices(ob.Depth(0).Asks)
+		require.Len(t, asks, 60)
+		for i, p := range asks {
+			require.Equal(t, uint64(131+i), p)
+		}
+		require.Equal(t, asks[:10], prices(ob.Depth(10).Asks))
+	})
+
+	t.Run("engine", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			setupMockHandler(t, handler)
+			engine, _, _ := newTestEngine(t, handler, multithread, 100)
+			require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 2)))
+			require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 2)))
+
+			depth, err := engine.Depth(symbolID, 1)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{99}, prices(depth.Bids))
+			require.Equal(t, []uint64{101}, prices(depth.Asks))
+			require.True(t, depth.Asks[0].Volume.Equals(price(2)))
+			require.Equal(t, 1, depth.Asks[0].Orders)
+
+			_, err = engine.Depth(2, 1)
+			require.ErrorIs(t, err, matching.ErrOrderBookNotFound)
+
+			engine.Stop(false)
+		}
+	})
+}
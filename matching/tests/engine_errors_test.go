@@ -0,0 +1,77 @@
+package matching_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestCommandErrors(t *testing.T) {
+	t.Run("rejected command", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			errs := []error{}
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			handler.EXPECT().OnError(gomock.Any(), gomock.Any()).Do(
+				func(ob *matching.OrderBook, err error) {
+					errs = append(errs, err)
+				}).AnyTimes()
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrOrderDuplicate))
+			setupMockHandler(t, handler)
+			engine, _, _ := newTestEngine(t, handler, multithread, 100)
+			require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 100, 1)))
+			err := engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 100, 1))
+			sequence := engine.Sequence()
+			engine.Stop(false)
+
+			require.Len(t, errs, 1)
+			var cmdErr *matching.CommandError
+			require.ErrorAs(t, errs[0], &cmdErr)
+			require.ErrorIs(t, cmdErr, matching.ErrOrderDuplicate)
+			require.Equal(t, matching.CommandKindAddOrder, cmdErr.Kind)
+			require.Equal(t, sequence, cmdErr.Sequence)
+			require.Equal(t, uint32(symbolID), cmdErr.SymbolID)
+			require.Equal(t, []uint64{1}, cmdErr.OrderIDs)
+			require.Equal(t, matching.ErrorPhaseValidate, cmdErr.Phase)
+			require.False(t, cmdErr.Internal())
+
+			// In single-thread mode the same error is returned to the caller
+			if !multithread {
+				require.Equal(t, errs[0], err)
+			}
+		}
+	})
+
+	t.Run("order book commands", func(t *testing.T) {
+		errs := []error{}
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnError(gomock.Any(), gomock.Any()).Do(
+			func(ob *matching.OrderBook, err error) {
+				errs = append(errs, err)
+			}).AnyTimes()
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 100, 1)))
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateClosed))
+
+		require.Error(t, engine.DeleteOrder(symbolID, 1))
+		_, err := engine.MassCancel(matching.MassCancelFilter{AllSymbols: true})
+		require.Error(t, err)
+
+		require.Len(t, errs, 2)
+		var cmdErr *matching.CommandError
+		require.True(t, errors.As(errs[0], &cmdErr))
+		require.Equal(t, matching.CommandKindDeleteOrder, cmdErr.Kind)
+		require.Equal(t, []uint64{1}, cmdErr.OrderIDs)
+		require.Equal(t, matching.ErrorPhaseValidate, cmdErr.Phase)
+		require.True(t, errors.As(errs[1], &cmdErr))
+		require.Equal(t, matching.CommandKindMassCancel, cmdErr.Kind)
+		require.Empty(t, cmdErr.OrderIDs)
+		require.ErrorIs(t, cmdErr, matching.ErrForbiddenTradingState)
+		require.Equal(t, matching.ErrorPhaseValidate, cmdErr.Phase)
+	})
+}
@@ -0,0 +1,94 @@
+package matching_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+)
+
+func TestExecutedOrders(t *testing.T) {
+	// deletes returns the number of deletions of the order
+	deletes := func(handler *recordingHandler, id uint64) int {
+		n := 0
+		for _, event := range handler.events() {
+			if strings.HasPrefix(event, fmt.Sprintf("delete order %d ", id)) {
+				n++
+			}
+		}
+		return n
+	}
+	// checkReleased checks that executed orders are released once, so new orders get their own instances
+	checkReleased := func(t *testing.T, engine *matching.Engine, ob *matching.OrderBook) {
+		require.Equal(t, 0, ob.Size())
+		require.NoError(t, engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 90, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(11, matching.OrderSideBuy, 91, 1)))
+		require.Equal(t, uint64(10), ob.Order(10).ID())
+		require.Equal(t, uint64(11), ob.Order(11).ID())
+		require.NotSame(t, ob.Order(10), ob.Order(11))
+	}
+
+	t.Run("modify", func(t *testing.T) {
+		// The ask 1 and the bid 3 both of quantity 2
+		handler := newRecordingHandler()
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 99, 2)))
+		require.NoError(t, engine.ModifyOrder(symbolID, 3, price(101), price(2)))
+
+		require.Equal(t, 1, deletes(handler, 1))
+		require.Equal(t, 1, deletes(handler, 3))
+		checkReleased(t, engine, ob)
+	})
+
+	t.Run("mitigate", func(t *testing.T) {
+		handler := newRecordingHandler()
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 99, 2)))
+		require.NoError(t, engine.MitigateOrder(symbolID, 3, price(101), price(2), matching.NewZeroUint()))
+
+		require.Equal(t, 1, deletes(handler, 1))
+		require.Equal(t, 1, deletes(handler, 3))
+		checkReleased(t, engine, ob)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		handler := newRecordingHandler()
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 99, 2)))
+		require.NoError(t, engine.ReplaceOrder(symbolID, 3, 5, price(101), price(2)))
+
+		require.Equal(t, 1, deletes(handler, 1))
+		require.Equal(t, 1, deletes(handler, 3))
+		require.Equal(t, 1, deletes(handler, 5))
+		checkReleased(t, engine, ob)
+	})
+
+	t.Run("market order in quote mode", func(t *testing.T) {
+		handler := newRecordingHandler()
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 99, 2)))
+		require.NoError(t, engine.DeleteOrder(symbolID, 3))
+		require.NoError(t, engine.AddOrder(matching.NewMarketOrder(
+			symbolID, 5,
+			0,
+			matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			matching.NewZeroUint(),
+			price(202),
+			matching.NewMaxUint(),
+			price(1000000),
+		)))
+
+		require.Equal(t, 1, deletes(handler, 1))
+		require.Equal(t, 1, deletes(handler, 5))
+		checkReleased(t, engine, ob)
+	})
+}
@@ -426,25 +426,25 @@ func parseBytesToData(inp []byte) (allDataForFuzz, error) {
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
 				[]matching.Order{matching.NewLimitOrder(
-					symbolID, id, dt.Side, dt.Direction, dt.TIF, price, quantity, visible, restLocked,
+					symbolID, id, 0, dt.Side, dt.Direction, dt.TIF, price, quantity, visible, restLocked,
 				)}})
 		case matching.OrderTypeStopLimit:
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
 				[]matching.Order{matching.NewStopLimitOrder(
-					symbolID, id, dt.Side, dt.Direction, dt.TIF, price, dt.PriceMode, stopPrice, quantity, visible, restLocked,
+					symbolID, id, 0, dt.Side, dt.Direction, dt.TIF, price, dt.PriceMode, stopPrice, quantity, visible, restLocked,
 				)}})
 		case matching.OrderTypeMarket:
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
 				[]matching.Order{matching.NewMarketOrder(
-					symbolID, id, dt.Side, dt.Direction, matching.OrderTimeInForceIOC, modQQ(dt.ModQuote, 0, quantity),
+					symbolID, id, 0, dt.Side, dt.Direction, matching.OrderTimeInForceIOC, modQQ(dt.ModQuote, 0, quantity),
 					modQQ(dt.ModQuote, 1, quantity), slippage, restLocked,
 				)}})
 		case matching.OrderTypeStop:
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
-				[]matching.Order{matching.NewStopOrder(symbolID, id, dt.Side, dt.Direction, matching.OrderTimeInForceIOC,
+				[]matching.Order{matching.NewStopOrder(symbolID, id, 0, dt.Side, dt.Direction, matching.OrderTimeInForceIOC,
 					dt.PriceMode, stopPrice, modQQ(dt.ModQuote, 0, quantity),
 					modQQ(dt.ModQuote, 1, quantity), slippage, restLocked,
 				)}})
@@ -455,11 +455,11 @@ func parseBytesToData(inp []byte) (allDataForFuzz, error) {
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
 				[]matching.Order{
-					matching.NewStopLimitOrder(symbolID, id1, dt.Side, dt.Direction, dt.TIF, price,
+					matching.NewStopLimitOrder(symbolID, id1, 0, dt.Side, dt.Direction, dt.TIF, price,
 						dt.PriceMode, stopPrice,
 						quantity, visible, matching.NewZeroUint(),
 					),
-					matching.NewLimitOrder(symbolID, id2, dt.Side, dt.Direction, dt.TIF, price,
+					matching.NewLimitOrder(symbolID, id2, 0, dt.Side, dt.Direction, dt.TIF, price,
 						quantity, visible, restLocked,
 					),
 				}})
@@ -473,11 +473,11 @@ func parseBytesToData(inp []byte) (allDataForFuzz, error) {
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
 				[]matching.Order{
-					matching.NewStopLimitOrder(symbolID, id1, dt.Side, dt.Direction, dt.TIF, tpPrice,
+					matching.NewStopLimitOrder(symbolID, id1, 0, dt.Side, dt.Direction, dt.TIF, tpPrice,
 						dt.TpMode, u8U(dt.TpStopPrice),
 						quantity, visible, restLocked,
 					),
-					matching.NewStopLimitOrder(symbolID, id2, dt.Side, dt.Direction, dt.TIF, slPrice,
+					matching.NewStopLimitOrder(symbolID, id2, 0, dt.Side, dt.Direction, dt.TIF, slPrice,
 						dt.SlMode, u8U(dt.SlStopPrice),
 						quantity, visible, matching.NewZeroUint(),
 					),
@@ -491,13 +491,13 @@ func parseBytesToData(inp []byte) (allDataForFuzz, error) {
 			result.ordersSequence = append(result.ordersSequence, sequenceItem{
 				dt.Type,
 				[]matching.Order{
-					matching.NewStopOrder(symbolID, id1, dt.Side, dt.Direction, matching.OrderTimeInForceIOC,
+					matching.NewStopOrder(symbolID, id1, 0, dt.Side, dt.Direction, matching.OrderTimeInForceIOC,
 						dt.TpMode, u8U(dt.TpStopPrice),
 						modQQ(dt.ModQuote, 0, u8U(dt.TpQuantity)),
 						modQQ(dt.ModQuote, 1, u8U(dt.TpQuantity)),
 						u8U(dt.TpSlippage), restLocked,
 					),
-					matching.NewStopOrder(symbolID, id2, dt.Side, dt.Direction, matching.OrderTimeInForceIOC,
+					matching.NewStopOrder(symbolID, id2, 0, dt.Side, dt.Direction, matching.OrderTimeInForceIOC,
 						dt.SlMode, u8U(dt.SlStopPrice),
 						modQQ(dt.ModQuote, 0, u8U(dt.SlQuantity)),
 						modQQ(dt.ModQuote, 1, u8U(dt.SlQuantity)),
@@ -612,6 +612,8 @@ func (fs *fuzzStorage) OnAddOrder(orderBook *matching.OrderBook, order *matching
 func (fs *fuzzStorage) OnActivateOrder(orderBook *matching.OrderBook, order *matching.Order) {}
 func (fs *fuzzStorage) OnUpdateOrder(orderBook *matching.OrderBook, order *matching.Order)   {}
 func (fs *fuzzStorage) OnDeleteOrder(orderBook *matching.OrderBook, order *matching.Order)   {}
+func (fs *fuzzStorage) OnRejectOrder(orderBook *matching.OrderBook, order *matching.Order, reason error) {
+}
 
 func (fs *fuzzStorage) OnExecuteOrder(orderBook *matching.OrderBook, orderID uint64, price matching.Uint, quantity matching.Uint, quoteQuantity matching.Uint) {
 }
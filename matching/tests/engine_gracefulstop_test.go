@@ -34,7 +34,7 @@ func TestWriteAfterStop(t *testing.T) {
 		require.NoError(t, err)
 
 		for i := range 100 {
-			engine.AddOrder(matching.NewLimitOrder(1, uint64(i+1), //nolint:errcheck
+			engine.AddOrder(matching.NewLimitOrder(1, uint64(i+1), 0, //nolint:errcheck
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -43,7 +43,7 @@ func TestWriteAfterStop(t *testing.T) {
 				matching.NewMaxUint(),
 				matching.NewMaxUint(),
 			))
-			engine.AddOrder(matching.NewLimitOrder(1, uint64(1000*i+1), //nolint:errcheck
+			engine.AddOrder(matching.NewLimitOrder(1, uint64(1000*i+1), 0, //nolint:errcheck
 				matching.OrderSideSell,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -120,6 +120,10 @@ func (wh *watchHandler) OnDeleteOrder(orderBook *matching.OrderBook, order *matc
 	wh.inc()
 }
 
+func (wh *watchHandler) OnRejectOrder(orderBook *matching.OrderBook, order *matching.Order, reason error) {
+	wh.inc()
+}
+
 func (wh *watchHandler) OnExecuteOrder(orderBook *matching.OrderBook, orderID uint64, price matching.Uint, quantity matching.Uint, quoteQuantity matching.Uint) {
 	wh.inc()
 }
@@ -0,0 +1,120 @@
+package matching_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+)
+
+func TestJournalReplay(t *testing.T) {
+	// first part of the scenario builds the order book
+	first := func(engine *matching.Engine) {
+		_, _ = engine.AddOrderBook(
+			matching.NewSymbol(symbolID, "BTC-USDT"),
+			price(10),
+			matching.StopPriceModeConfig{Market: true, Mark: true},
+		)
+		engine.EnableMatching()
+		_ = engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 9, 1))
+		_ = engine.AddOrder(limitOrder(11, matching.OrderSideBuy, 9, 2))
+		_ = engine.AddOrder(limitOrder(12, matching.OrderSideBuy, 8, 3))
+		_ = engine.AddOrder(limitOrder(20, matching.OrderSideSell, 11, 2))
+		_ = engine.AddOrder(limitOrder(21, matching.OrderSideSell, 12, 2))
+		_ = engine.SetMarkPriceForOrderBook(symbolID, price(10), false)
+	}
+
+	// second part of the scenario matches and changes orders
+	second := func(engine *matching.Engine) {
+		_ = engine.AddOrder(limitOrder(30, matching.OrderSideSell, 9, 2))
+		_ = engine.ModifyOrder(symbolID, 12, price(7), price(4))
+		_ = engine.ReduceOrder(symbolID, 21, price(1))
+		_ = engine.DeleteOrder(symbolID, 20)
+		_ = engine.DeleteOrder(symbolID, 99) // fails with not found order
+
+		// Rejected orders are journaled too
+		_ = engine.AddOrder(limitOrder(32, matching.OrderSideBuy, 9, 0))
+		_ = engine.AddOrder(matching.NewLimitOrder(
+			symbolID+1, 33, 0, matching.OrderSideBuy,
+			matching.OrderDirectionOpen,
+			matching.OrderTimeInForceGTC,
+			price(9), price(1),
+			matching.NewMaxUint(),
+			price(9),
+		))
+		engine.DisableMatching()
+		_ = engine.AddOrder(limitOrder(31, matching.OrderSideBuy, 12, 1))
+		_ = engine.ExecuteOrder(symbolID, 11, price(1))
+		engine.Match()
+		_ = engine.ReplaceOrder(symbolID, 12, 13, price(8), price(2))
+	}
+
+	for _, multithread := range []bool{false, true} {
+		t.Run(fmt.Sprintf("replay multithread=%t", multithread), func(t *testing.T) {
+			var journal bytes.Buffer
+			handler := newRecordingHandler()
+			source := matching.NewEngine(handler, multithread)
+			source.SetJournal(matching.NewJournal(&journal))
+			first(source)
+			second(source)
+			expected := takeSnapshot(t, source)
+			source.Stop(false)
+
+			replayedHandler := newRecordingHandler()
+			replayed := matching.NewEngine(replayedHandler, multithread)
+			require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+			require.Equal(t, source.Sequence(), replayed.Sequence())
+			require.Equal(t, expected, takeSnapshot(t, replayed))
+			replayed.Stop(false)
+
+			require.NotEmpty(t, handler.trades())
+			require.Contains(t, handler.events(), fmt.Sprintf("reject order 32 reason=%s", matching.ErrInvalidOrderQuantity))
+			require.Contains(t, handler.events(), fmt.Sprintf("reject order 33 reason=%s", matching.ErrOrderBookNotFound))
+			require.Equal(t, handler.events(), replayedHandler.events())
+		})
+
+		t.Run(fmt.Sprintf("snapshot and journal tail multithread=%t", multithread), func(t *testing.T) {
+			var journal bytes.Buffer
+			handler := newRecordingHandler()
+			source := matching.NewEngine(handler, multithread)
+			source.SetJournal(matching.NewJournal(&journal))
+			first(source)
+			data := takeSnapshot(t, source)
+			skipped := len(handler.events())
+			second(source)
+			expected := takeSnapshot(t, source)
+			source.Stop(false)
+
+			replayedHandler := newRecordingHandler()
+			replayed := matching.NewEngine(replayedHandler, multithread)
+			require.NoError(t, replayed.Restore(bytes.NewReader(data)))
+			require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+			require.Equal(t, source.Sequence(), replayed.Sequence())
+			require.Equal(t, expected, takeSnapshot(t, replayed))
+			replayed.Stop(false)
+
+			require.Equal(t, handler.events()[skipped:], replayedHandler.events())
+		})
+	}
+
+	t.Run("truncated journal", func(t *testing.T) {
+		var journal bytes.Buffer
+		source := matching.NewEngine(newRecordingHandler(), false)
+		source.SetJournal(matching.NewJournal(&journal))
+		first(source)
+
+		replayed := matching.NewEngine(newRecordingHandler(), false)
+		err := replayed.Replay(bytes.NewReader(journal.Bytes()[:journal.Len()-3]))
+		require.ErrorIs(t, err, matching.ErrJournalTruncated)
+		require.Equal(t, source.Sequence()-1, replayed.Sequence())
+	})
+
+	t.Run("invalid journal", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), false)
+		err := engine.Replay(bytes.NewReader([]byte{9, 0, 0, 0, 255, 1, 0, 0, 0, 0, 0, 0, 0}))
+		require.ErrorIs(t, err, matching.ErrInvalidJournal)
+	})
+}
@@ -0,0 +1,114 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+)
+
+func TestEngineOptions(t *testing.T) {
+	spModesConfig := matching.StopPriceModeConfig{Market: true}
+
+	t.Run("defaults", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), true)
+		defer engine.Stop(false)
+		ob, err := engine.AddOrderBook(testSymbol(), price(100), spModesConfig)
+		require.NoError(t, err)
+		require.Equal(t, 256, ob.TaskQueueSize())
+		require.Nil(t, ob.MatchingPolicy())
+	})
+
+	t.Run("order book defaults", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), true,
+			matching.WithExpectedOrderBooks(4),
+			matching.WithOrderBookDefaults(
+				matching.WithTaskQueueSize(16),
+				matching.WithExpectedOrders(64),
+				matching.WithAllocatorPool(false),
+				matching.WithMatchingPolicy(matching.ProRataPolicy{}),
+			),
+		)
+		defer engine.Stop(false)
+
+		ob, err := engine.AddOrderBook(testSymbol(), price(100), spModesConfig)
+		require.NoError(t, err)
+		require.Equal(t, 16, ob.TaskQueueSize())
+		require.Equal(t, matching.ProRataPolicy{}, ob.MatchingPolicy())
+
+		// Options of the order book take precedence over defaults
+		ob, err = engine.AddOrderBook(matching.NewSymbol(2, "ETH-USDT"), price(100), spModesConfig,
+			matching.WithTaskQueueSize(32),
+			matching.WithMatchingPolicy(nil),
+		)
+		require.NoError(t, err)
+		require.Equal(t, 32, ob.TaskQueueSize())
+		require.Nil(t, ob.MatchingPolicy())
+
+		// Order books are not limited by the expected count
+		_, err = engine.AddOrderBook(matching.NewSymbol(10, "SOL-USDT"), price(100), spModesConfig)
+		require.NoError(t, err)
+	})
+
+	t.Run("allocator without pool", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), false,
+			matching.WithOrderBookDefaults(matching.WithAllocatorPool(false)),
+		)
+		engine.EnableMatching()
+		sym := testSymbol()
+		sym.SetSelfTradePrevention(matching.SelfTradePreventionCancelBoth)
+		ob, err := engine.AddOrderBook(sym, price(100), spModesConfig)
+		require.NoError(t, err)
+
+		// Released orders are not placed into the order book
+		for id, side := range []matching.OrderSide{matching.OrderSideSell, matching.OrderSideBuy} {
+			require.NoError(t, engine.AddOrder(newLimitOrder(1, uint64(id+1), 1, side, matching.OrderTimeInForceGTC, 100, 1)))
+		}
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("invalid matching policy", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), false)
+		_, err := engine.AddOrderBook(testSymbol(), price(100), spModesConfig,
+			matching.WithMatchingPolicy(matching.HybridPolicy{MarketMakerShare: 6000, FIFOShare: 5000}),
+		)
+		require.ErrorIs(t, err, matching.ErrInvalidMatchingPolicy)
+		require.Nil(t, engine.OrderBook(1))
+		require.Zero(t, engine.Sequence())
+	})
+
+	t.Run("journal replay", func(t *testing.T) {
+		var journal bytes.Buffer
+		policy := matching.HybridPolicy{MarketMakers: []uint64{3}, MarketMakerShare: 5000}
+		source := matching.NewEngine(newRecordingHandler(), false)
+		source.SetJournal(matching.NewJournal(&journal))
+		_, err := source.AddOrderBook(testSymbol(), price(100), spModesConfig, matching.WithMatchingPolicy(policy))
+		require.NoError(t, err)
+
+		// The journaled policy overrides defaults of the replaying engine
+		replayed := matching.NewEngine(newRecordingHandler(), false,
+			matching.WithOrderBookDefaults(matching.WithMatchingPolicy(matching.ProRataPolicy{})),
+		)
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		require.Equal(t, policy, replayed.OrderBook(1).MatchingPolicy())
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), false)
+		_, err := engine.AddOrderBook(testSymbol(), price(100), spModesConfig, matching.WithMatchingPolicy(matching.ProRataPolicy{}))
+		require.NoError(t, err)
+
+		data := takeSnapshot(t, engine)
+
+		// Restored order books use defaults of the engine except the stored matching policy
+		restored := matching.NewEngine(newRecordingHandler(), true,
+			matching.WithOrderBookDefaults(matching.WithTaskQueueSize(8)),
+		)
+		defer restored.Stop(false)
+		require.NoError(t, restored.Restore(bytes.NewReader(data)))
+		require.Equal(t, 8, restored.OrderBook(1).TaskQueueSize())
+		require.Equal(t, matching.ProRataPolicy{}, restored.OrderBook(1).MatchingPolicy())
+	})
+}
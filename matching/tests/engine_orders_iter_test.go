@@ -0,0 +1,135 @@
+package matching_test
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+)
+
+func TestOrderBookOrdersIterators(t *testing.T) {
+	ids := func(orders []matching.Order) []uint64 {
+		result := []uint64{}
+		for _, order := range orders {
+			result = append(result, order.ID())
+		}
+		return result
+	}
+
+	t.Run("price-time priority", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		setupIteratorState(t, engine)
+		require.Equal(t, []uint64{1, 3, 2}, ids(slices.Collect(ob.RestingOrders(matching.OrderSideBuy))))
+		require.Equal(t, []uint64{5, 4, 6}, ids(slices.Collect(ob.RestingOrders(matching.OrderSideSell))))
+		require.Equal(t, []uint64{1, 3, 2, 5, 4, 6}, ids(slices.Collect(ob.RestingOrders(0))))
+		require.Empty(t, slices.Collect(ob.RestingOrders(0xff)))
+	})
+
+	t.Run("stop orders", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		setupIteratorState(t, engine)
+		require.Equal(t, []uint64{7, 8}, ids(slices.Collect(ob.StopOrders(matching.OrderSideBuy))))
+		require.Equal(t, []uint64{7, 8, 9}, ids(slices.Collect(ob.StopOrders(0))))
+		require.Equal(t, []uint64{10}, ids(slices.Collect(ob.TrailingStopOrders(matching.OrderSideSell))))
+		require.Empty(t, slices.Collect(ob.TrailingStopOrders(matching.OrderSideBuy)))
+	})
+
+	t.Run("copies", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		setupIteratorState(t, engine)
+		for order := range ob.RestingOrders(matching.OrderSideBuy) {
+			order.SubRestQuantity(order.RestQuantity())
+		}
+		require.True(t, ob.Order(1).RestQuantity().Equals(price(1)))
+		require.True(t, ob.TopBid().Value().Volume().Equals(price(2)))
+	})
+
+	t.Run("break", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		setupIteratorState(t, engine)
+		orders := []uint64{}
+		for order := range ob.RestingOrders(0) {
+			orders = append(orders, order.ID())
+			if len(orders) == 4 {
+				break
+			}
+		}
+		require.Equal(t, []uint64{1, 3, 2, 5}, orders)
+	})
+
+	t.Run("many levels", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		setupIteratorState(t, engine)
+		for i, p := range rand.Perm(100) {
+			require.NoError(t, engine.AddOrder(limitOrder(uint64(100+i), matching.OrderSideSell, uint64(200+p), 1)))
+		}
+
+		prices := []matching.Uint{}
+		for order := range ob.RestingOrders(matching.OrderSideSell) {
+			prices = append(prices, order.Price())
+		}
+		require.Len(t, prices, 103)
+		require.True(t, slices.IsSortedFunc(prices, func(a, b matching.Uint) int { return a.Cmp(b) }))
+	})
+
+	t.Run("engine", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			engine, _, _ := newTestEngine(t, newRecordingHandler(), multithread, 100)
+			setupIteratorState(t, engine)
+			orders, err := engine.RestingOrders(symbolID, matching.OrderSideSell)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{5, 4, 6}, ids(orders))
+
+			_, err = engine.RestingOrders(2, 0)
+			require.ErrorIs(t, err, matching.ErrOrderBookNotFound)
+			engine.Stop(false)
+		}
+	})
+}
+
+// setupIteratorState adds bids at 98 and 99, asks at 101 and 102 with several orders queued at the same price,
+// stop orders and the trailing stop order.
+func setupIteratorState(t *testing.T, engine *matching.Engine) {
+	for _, order := range []matching.Order{
+		limitOrder(1, matching.OrderSideBuy, 99, 1),
+		limitOrder(2, matching.OrderSideBuy, 98, 1),
+		limitOrder(3, matching.OrderSideBuy, 99, 1),
+		limitOrder(4, matching.OrderSideSell, 102, 1),
+		limitOrder(5, matching.OrderSideSell, 101, 1),
+		limitOrder(6, matching.OrderSideSell, 102, 1),
+	} {
+		require.NoError(t, engine.AddOrder(order))
+	}
+	for _, stop := range []struct {
+		id    uint64
+		side  matching.OrderSide
+		price uint64
+	}{
+		{7, matching.OrderSideBuy, 110},
+		{8, matching.OrderSideBuy, 105},
+		{9, matching.OrderSideSell, 90},
+	} {
+		require.NoError(t, engine.AddOrder(matching.NewStopOrder(
+			symbolID, stop.id, 0, stop.side,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			matching.StopPriceModeMarket,
+			price(stop.price), price(1), price(0),
+			price(1000000), price(1000000),
+		)))
+	}
+	require.NoError(t, engine.AddOrder(matching.NewTrailingStopOrder(
+		symbolID, 10, 0, matching.OrderSideSell,
+		matching.OrderDirectionClose,
+		matching.OrderTimeInForceIOC,
+		matching.StopPriceModeMarket,
+		price(95), price(1), price(0),
+		price(1000000),
+		matching.NewUint(100),
+		matching.NewUint(10),
+		price(1000000),
+	)))
+}
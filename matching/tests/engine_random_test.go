@@ -76,6 +76,10 @@ func TestAllocatorCollisions(t *testing.T) {
 	time.Sleep(time.Second * 1)
 
 	for _, id := range symIDS {
+		// Wait for all accepted commands of the order book
+		_, err := engine.Depth(id, 1)
+		require.NoError(t, err)
+
 		ob := engine.OrderBook(id)
 		if ob.TopAsk() != nil {
 			for orderPtr := ob.TopAsk().Value().Queue().Front(); orderPtr != nil; {
@@ -197,25 +201,25 @@ func generateOrderSequence(id uint64, n int, symbols []uint32) ([]sequenceItem,
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{matching.NewLimitOrder(
-					symbolID, id, side, dir, tif, price, quantity, matching.NewMaxUint(), restLocked,
+					symbolID, id, 0, side, dir, tif, price, quantity, matching.NewMaxUint(), restLocked,
 				)}})
 		case matching.OrderTypeStopLimit:
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{matching.NewStopLimitOrder(
-					symbolID, id, side, dir, tif, price, priceMode, stopPrice, quantity, visible, restLocked,
+					symbolID, id, 0, side, dir, tif, price, priceMode, stopPrice, quantity, visible, restLocked,
 				)}})
 		case matching.OrderTypeMarket:
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{matching.NewMarketOrder(
-					symbolID, id, side, dir, matching.OrderTimeInForceIOC, modQQ(modQuote, 0, quantity),
+					symbolID, id, 0, side, dir, matching.OrderTimeInForceIOC, modQQ(modQuote, 0, quantity),
 					modQQ(modQuote, 1, quantity), slippage, restLocked,
 				)}})
 		case matching.OrderTypeStop:
 			result = append(result, sequenceItem{
 				typ,
-				[]matching.Order{matching.NewStopOrder(symbolID, id, side, dir, matching.OrderTimeInForceIOC,
+				[]matching.Order{matching.NewStopOrder(symbolID, id, 0, side, dir, matching.OrderTimeInForceIOC,
 					priceMode, stopPrice, modQQ(modQuote, 0, quantity),
 					modQQ(modQuote, 1, quantity), slippage, restLocked,
 				)}})
@@ -226,11 +230,11 @@ func generateOrderSequence(id uint64, n int, symbols []uint32) ([]sequenceItem,
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{
-					matching.NewStopLimitOrder(symbolID, id1, side, dir, tif, price,
+					matching.NewStopLimitOrder(symbolID, id1, 0, side, dir, tif, price,
 						priceMode, stopPrice,
 						quantity, visible, matching.NewZeroUint(),
 					),
-					matching.NewLimitOrder(symbolID, id2, side, dir, tif, price,
+					matching.NewLimitOrder(symbolID, id2, 0, side, dir, tif, price,
 						quantity, visible, restLocked,
 					),
 				}})
@@ -241,11 +245,11 @@ func generateOrderSequence(id uint64, n int, symbols []uint32) ([]sequenceItem,
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{
-					matching.NewStopLimitOrder(symbolID, id1, side, dir, tif, tpPrice,
+					matching.NewStopLimitOrder(symbolID, id1, 0, side, dir, tif, tpPrice,
 						tpMode, tpStopPrice,
 						quantity, visible, restLocked,
 					),
-					matching.NewStopLimitOrder(symbolID, id2, side, dir, tif, slPrice,
+					matching.NewStopLimitOrder(symbolID, id2, 0, side, dir, tif, slPrice,
 						slMode, slStopPrice,
 						quantity, visible, matching.NewZeroUint(),
 					),
@@ -259,13 +263,13 @@ func generateOrderSequence(id uint64, n int, symbols []uint32) ([]sequenceItem,
 			result = append(result, sequenceItem{
 				typ,
 				[]matching.Order{
-					matching.NewStopOrder(symbolID, id1, side, dir, matching.OrderTimeInForceIOC,
+					matching.NewStopOrder(symbolID, id1, 0, side, dir, matching.OrderTimeInForceIOC,
 						tpMode, tpStopPrice,
 						modQQ(modQuote, 0, tpQuantity),
 						modQQ(modQuote, 1, tpQuantity),
 						tpSlippage, restLocked,
 					),
-					matching.NewStopOrder(symbolID, id2, side, dir, matching.OrderTimeInForceIOC,
+					matching.NewStopOrder(symbolID, id2, 0, side, dir, matching.OrderTimeInForceIOC,
 						slMode, slStopPrice,
 						modQQ(modQuote, 0, slQuantity),
 						modQQ(modQuote, 1, slQuantity),
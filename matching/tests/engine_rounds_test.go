@@ -63,6 +63,7 @@ func TestCalculationRound(t *testing.T) {
 			order := matching.NewLimitOrder(
 				symbolID,
 				1,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -83,6 +84,7 @@ func TestCalculationRound(t *testing.T) {
 			order := matching.NewStopOrder(
 				symbolID,
 				2,
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceIOC,
@@ -104,6 +106,7 @@ func TestCalculationRound(t *testing.T) {
 			order := matching.NewStopOrder(
 				symbolID,
 				3,
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceIOC,
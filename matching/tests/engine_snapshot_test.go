@@ -0,0 +1,172 @@
+package matching_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, multithread := range []bool{false, true} {
+		t.Run(fmt.Sprintf("multithread=%t", multithread), func(t *testing.T) {
+			source := matching.NewEngine(newRecordingHandler(), multithread)
+			source.SetClock(matching.NewManualClock(testTime))
+			source.EnableMatching()
+			setupSnapshotState(t, source)
+			data := takeSnapshot(t, source)
+
+			// No events are emitted while restoring
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			restored := matching.NewEngine(handler, multithread)
+			restored.SetClock(matching.NewManualClock(testTime))
+			restored.EnableMatching()
+			require.NoError(t, restored.Restore(bytes.NewReader(data)))
+
+			// Snapshot of the restored engine must be the same
+			require.Equal(t, data, takeSnapshot(t, restored))
+			require.Equal(t, source.OrderBooks(), restored.OrderBooks())
+
+			// Matching of the restored engine must respect time priority
+			expectTrades(t, handler, tradeBetween(10, 100), tradeBetween(11, 100), tradeBetween(12, 100))
+			require.NoError(t, restored.AddOrder(matching.NewMarketOrder(
+				symbolID, 100,
+				0,
+				matching.OrderSideSell,
+				matching.OrderDirectionClose,
+				matching.OrderTimeInForceIOC,
+				matching.NewUint(5).Mul64(matching.UintPrecision),
+				matching.NewZeroUint(),
+				matching.NewMaxUint(),
+				matching.NewMaxUint(),
+			)))
+			restored.Stop(false)
+			source.Stop(false)
+		})
+	}
+
+	t.Run("invalid snapshot", func(t *testing.T) {
+		engine := matching.NewEngine(newRecordingHandler(), false)
+		err := engine.Restore(bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}))
+		require.ErrorIs(t, err, matching.ErrInvalidSnapshot)
+	})
+
+	t.Run("truncated snapshot", func(t *testing.T) {
+		source := matching.NewEngine(newRecordingHandler(), false)
+		source.SetClock(matching.NewManualClock(testTime))
+		source.EnableMatching()
+		setupSnapshotState(t, source)
+		data := takeSnapshot(t, source)
+
+		// Order books are not added by any incomplete snapshot
+		for size := 0; size < len(data); size++ {
+			engine := matching.NewEngine(mockmatching.NewMockHandler(gomock.NewController(t)), false)
+			require.Error(t, engine.Restore(bytes.NewReader(data[:size])), "size %d", size)
+			require.Equal(t, 0, engine.OrderBooks(), "size %d", size)
+			require.False(t, engine.IsMatchingEnabled(), "size %d", size)
+		}
+	})
+
+	t.Run("duplicated order book", func(t *testing.T) {
+		source := matching.NewEngine(newRecordingHandler(), false)
+		source.SetClock(matching.NewManualClock(testTime))
+		source.EnableMatching()
+		setupSnapshotState(t, source)
+		data := takeSnapshot(t, source)
+
+		err := source.Restore(bytes.NewReader(data))
+		require.ErrorIs(t, err, matching.ErrOrderBookDuplicate)
+	})
+
+	t.Run("partially added order books", func(t *testing.T) {
+		source := matching.NewEngine(newRecordingHandler(), false)
+		source.SetClock(matching.NewManualClock(testTime))
+		source.EnableMatching()
+		setupSnapshotState(t, source)
+		_, err := source.AddOrderBook(matching.NewSymbol(symbolID+1, "ETH-USDT"), matching.NewZeroUint(), matching.StopPriceModeConfig{})
+		require.NoError(t, err)
+		data := takeSnapshot(t, source)
+
+		// Order books added before the failure are deleted and the engine is left untouched
+		engine := matching.NewEngine(newRecordingHandler(), false)
+		_, err = engine.AddOrderBook(matching.NewSymbol(symbolID+1, "ETH-USDT"), matching.NewZeroUint(), matching.StopPriceModeConfig{})
+		require.NoError(t, err)
+		require.ErrorIs(t, engine.Restore(bytes.NewReader(data)), matching.ErrOrderBookDuplicate)
+		require.Equal(t, 1, engine.OrderBooks())
+		require.Nil(t, engine.OrderBook(symbolID))
+		require.False(t, engine.IsMatchingEnabled())
+	})
+}
+
+// setupSnapshotState adds the order book with resting, iceberg, stop-limit and trailing stop orders,
+// so snapshots include all kinds of order book state.
+func setupSnapshotState(t *testing.T, engine *matching.Engine) {
+	_, err := engine.AddOrderBook(
+		matching.NewSymbol(symbolID, "BTC-USDT"),
+		matching.NewUint(10).Mul64(matching.UintPrecision),
+		matching.StopPriceModeConfig{Market: true, Mark: true},
+	)
+	require.NoError(t, err)
+
+	// Several bids at the same price level to check queue priority
+	for i := range 3 {
+		require.NoError(t, engine.AddOrder(matching.NewLimitOrder(
+			symbolID, uint64(10+i),
+			0,
+			matching.OrderSideBuy,
+			matching.OrderDirectionOpen,
+			matching.OrderTimeInForceGTC,
+			matching.NewUint(9).Mul64(matching.UintPrecision),
+			matching.NewUint(uint64(i+1)).Mul64(matching.UintPrecision),
+			matching.NewMaxUint(),
+			matching.NewMaxUint(),
+		)))
+	}
+	require.NoError(t, engine.AddOrder(matching.NewLimitOrder(
+		symbolID, 20,
+		0,
+		matching.OrderSideSell,
+		matching.OrderDirectionClose,
+		matching.OrderTimeInForceGTC,
+		matching.NewUint(11).Mul64(matching.UintPrecision),
+		matching.NewUint(5).Mul64(matching.UintPrecision),
+		matching.NewUint(1).Mul64(matching.UintPrecision),
+		matching.NewUint(5).Mul64(matching.UintPrecision),
+	)))
+	require.NoError(t, engine.AddOrder(matching.NewStopLimitOrder(
+		symbolID, 30,
+		0,
+		matching.OrderSideSell,
+		matching.OrderDirectionClose,
+		matching.OrderTimeInForceGTC,
+		matching.NewUint(7).Mul64(matching.UintPrecision),
+		matching.StopPriceModeMark,
+		matching.NewUint(8).Mul64(matching.UintPrecision),
+		matching.NewUint(2).Mul64(matching.UintPrecision),
+		matching.NewMaxUint(),
+		matching.NewUint(2).Mul64(matching.UintPrecision),
+	)))
+	require.NoError(t, engine.AddOrder(matching.NewTrailingStopOrder(
+		symbolID, 40,
+		0,
+		matching.OrderSideSell,
+		matching.OrderDirectionClose,
+		matching.OrderTimeInForceIOC,
+		matching.StopPriceModeMarket,
+		matching.NewUint(6).Mul64(matching.UintPrecision),
+		matching.NewUint(1).Mul64(matching.UintPrecision),
+		matching.NewZeroUint(),
+		matching.NewMaxUint(),
+		matching.NewUint(100),
+		matching.NewUint(10),
+		matching.NewUint(1).Mul64(matching.UintPrecision),
+	)))
+
+	// Partially execute the second bid so rest quantities are not trivial
+	require.NoError(t, engine.ReduceOrder(symbolID, 11, matching.NewUint(1).Mul64(matching.UintPrecision/2)))
+}
@@ -1,7 +1,12 @@
 package matching_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -36,6 +41,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -71,6 +77,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -84,6 +91,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID+1,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -119,6 +127,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -132,6 +141,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID+1,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -169,6 +179,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -182,6 +193,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID+1,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -219,6 +231,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -232,6 +245,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID+1,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -267,6 +281,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -303,6 +318,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -348,6 +364,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -396,6 +413,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -444,6 +462,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -489,6 +508,7 @@ func TestBasic(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -534,14 +554,14 @@ func TestBasic(t *testing.T) {
 		price, err := matching.NewUintFromFloatString("1.0001")
 		require.NoError(t, err)
 
-		o1 := matching.NewLimitOrder(symbolID, orderID, matching.OrderSideBuy,
+		o1 := matching.NewLimitOrder(symbolID, orderID, 0, matching.OrderSideBuy,
 			matching.OrderDirectionOpen, matching.OrderTimeInForceGTC,
 			price, matching.NewUint(1).Mul64(matching.UintPrecision),
 			matching.NewMaxUint(),
 			matching.NewUint(1).Mul64(matching.UintPrecision).Add64(1),
 		)
 
-		o2 := matching.NewMarketOrder(symbolID, orderID+1, matching.OrderSideSell,
+		o2 := matching.NewMarketOrder(symbolID, orderID+1, 0, matching.OrderSideSell,
 			matching.OrderDirectionOpen, matching.OrderTimeInForceIOC,
 			matching.NewZeroUint(),
 			matching.NewUint(1).Mul64(matching.UintPrecision).Add64(2),
@@ -578,6 +598,7 @@ func setupMarketState(t *testing.T, engine *matching.Engine, symbolID uint32) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			ps.id,
+			0,
 			ps.side,
 			ps.direction,
 			matching.OrderTimeInForceGTC,
@@ -592,7 +613,116 @@ func setupMarketState(t *testing.T, engine *matching.Engine, symbolID uint32) {
 	require.Equal(t, 4, engine.Orders())
 }
 
+// price returns the value scaled by the precision of prices and quantities.
+func price(v uint64) matching.Uint {
+	return matching.NewUint(v).Mul64(matching.UintPrecision)
+}
+
+// testSymbol returns the symbol with prices and lot sizes from 1 to 1000000 with the step 1.
+func testSymbol() matching.Symbol {
+	limits := matching.Limits{Min: price(1), Max: price(1000000), Step: price(1)}
+	return matching.NewSymbolWithLimits(symbolID, "BTC-USDT", limits, limits)
+}
+
+// limitOrder returns the GTC limit order of the test symbol.
+func limitOrder(id uint64, side matching.OrderSide, p, qty uint64) matching.Order {
+	return newLimitOrder(symbolID, id, 0, side, matching.OrderTimeInForceGTC, p, qty)
+}
+
+// newLimitOrder returns the limit order with the amount locked enough for prices and quantities used by tests.
+func newLimitOrder(symbolID uint32, id, ownerID uint64, side matching.OrderSide, tif matching.OrderTimeInForce, p, qty uint64) matching.Order {
+	return matching.NewLimitOrder(
+		symbolID, id, ownerID, side,
+		matching.OrderDirectionClose,
+		tif,
+		price(p), price(qty),
+		matching.NewMaxUint(),
+		price(1000000),
+	)
+}
+
+// newIcebergOrder creates an iceberg limit order showing at most visible quantity.
+func newIcebergOrder(symbolID uint32, id, ownerID uint64, side matching.OrderSide, tif matching.OrderTimeInForce, p, qty, visible uint64) matching.Order {
+	return matching.NewLimitOrder(
+		symbolID, id, ownerID, side,
+		matching.OrderDirectionClose,
+		tif,
+		price(p), price(qty),
+		price(visible),
+		price(1000000),
+	)
+}
+
+// newPeggedOrder creates a GTC pegged order of the unit quantity.
+func newPeggedOrder(symbolID uint32, id uint64, side matching.OrderSide, reference matching.PegReference, offset int64, capPrice uint64) matching.Order {
+	return matching.NewPeggedOrder(
+		symbolID, id, 0, side,
+		matching.OrderDirectionClose,
+		matching.OrderTimeInForceGTC,
+		reference, offset, price(capPrice),
+		price(1),
+		matching.NewMaxUint(),
+		price(1000000),
+	)
+}
+
+// newMarketToLimitOrder creates a market-to-limit order with the quantity or the quote quantity.
+func newMarketToLimitOrder(symbolID uint32, id uint64, side matching.OrderSide, qty, quoteQty uint64) matching.Order {
+	return matching.NewMarketToLimitOrder(
+		symbolID, id, 0, side,
+		matching.OrderDirectionClose,
+		price(qty), price(quoteQty),
+		price(1000000),
+		price(1000000),
+	)
+}
+
+// askQueue returns IDs of orders at the best ask price level in the queue order.
+func askQueue(ob *matching.OrderBook) []uint64 {
+	ids := []uint64{}
+	it := ob.TopAsk().Value().Iterator()
+	for it.Next() {
+		ids = append(ids, it.Current().Value.ID())
+	}
+	return ids
+}
+
+// testTime is the time manual clocks of test engines start from.
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestEngine creates the engine with enabled matching and the order book of the test symbol.
+// Commands are stamped by the returned manual clock started at the test time.
+func newTestEngine(t *testing.T, handler matching.Handler, multithread bool, marketPrice uint64, opts ...matching.OrderBookOption) (*matching.Engine, *matching.OrderBook, *matching.ManualClock) {
+	clock := matching.NewManualClock(testTime)
+	engine := matching.NewEngine(handler, multithread)
+	engine.SetClock(clock)
+	engine.EnableMatching()
+
+	ob, err := engine.AddOrderBook(testSymbol(), price(marketPrice), matching.StopPriceModeConfig{Market: true}, opts...)
+	require.NoError(t, err)
+	if multithread {
+		t.Cleanup(func() { engine.Stop(false) })
+	}
+
+	return engine, ob, clock
+}
+
+// takeSnapshot returns the snapshot of the whole engine state.
+func takeSnapshot(t *testing.T, engine *matching.Engine) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, engine.Snapshot(&buf))
+	return buf.Bytes()
+}
+
+// setupMockHandler allows any events except rejects, errors and deleted order books.
+// NOTE: Specific expectations should be set before, since the first matching expectation is used.
 func setupMockHandler(t *testing.T, handler *mockmatching.MockHandler) {
+	setupMockOrderEvents(t, handler)
+	handler.EXPECT().OnExecuteTrade(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+}
+
+// setupMockOrderEvents allows any events except trades, rejects, errors and deleted order books.
+func setupMockOrderEvents(t *testing.T, handler *mockmatching.MockHandler) {
 	handler.EXPECT().OnAddOrderBook(gomock.Any()).AnyTimes()
 	handler.EXPECT().OnAddOrder(gomock.Any(), gomock.Any()).AnyTimes()
 	handler.EXPECT().OnActivateOrder(gomock.Any(), gomock.Any()).AnyTimes()
@@ -618,5 +748,253 @@ func setupMockHandler(t *testing.T, handler *mockmatching.MockHandler) {
 				quoteQuantity.ToFloatString(),
 			)
 		}).AnyTimes()
-	handler.EXPECT().OnExecuteTrade(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+}
+
+// expectTrades expects exactly the given trades in the given order and allows any other order events.
+func expectTrades(t *testing.T, handler *mockmatching.MockHandler, trades ...tradeArgs) {
+	calls := make([]*gomock.Call, 0, len(trades))
+	for _, trade := range trades {
+		calls = append(calls, trade.expect(handler))
+	}
+	gomock.InOrder(calls...)
+	setupMockOrderEvents(t, handler)
+}
+
+// errorIsMatcher matches errors wrapping the target error.
+type errorIsMatcher struct {
+	target error
+}
+
+func errorIs(target error) gomock.Matcher {
+	return errorIsMatcher{target: target}
+}
+
+func (m errorIsMatcher) Matches(x any) bool {
+	err, ok := x.(error)
+	return ok && errors.Is(err, m.target)
+}
+
+func (m errorIsMatcher) String() string {
+	return "is " + m.target.Error()
+}
+
+// tradeArgs matches arguments of executed trades, unset arguments match any value.
+type tradeArgs struct {
+	maker, taker                   gomock.Matcher
+	price, quantity, quoteQuantity gomock.Matcher
+}
+
+// tradeWith matches trades executed with the given price and quantities.
+func tradeWith(price, quantity, quoteQuantity matching.Uint) tradeArgs {
+	return tradeArgs{}.with(price, quantity, quoteQuantity)
+}
+
+// tradeBetween matches trades between the given maker and taker orders.
+func tradeBetween(maker, taker uint64) tradeArgs {
+	return tradeArgs{maker: orderUpdateMatcher{id: maker}, taker: orderUpdateMatcher{id: taker}}
+}
+
+// with additionally matches the price and quantities of the trade.
+func (a tradeArgs) with(price, quantity, quoteQuantity matching.Uint) tradeArgs {
+	a.price, a.quantity, a.quoteQuantity = gomock.Eq(price), gomock.Eq(quantity), gomock.Eq(quoteQuantity)
+	return a
+}
+
+// expect expects the trade with arguments matched in the given order book.
+func (a tradeArgs) expect(handler *mockmatching.MockHandler) *gomock.Call {
+	args := []gomock.Matcher{a.maker, a.taker, a.price, a.quantity, a.quoteQuantity}
+	for i, arg := range args {
+		if arg == nil {
+			args[i] = gomock.Any()
+		}
+	}
+	return handler.EXPECT().OnExecuteTrade(gomock.Any(), args[0], args[1], args[2], args[3], args[4])
+}
+
+// orderUpdateMatcher matches updates of the order with the given ID.
+type orderUpdateMatcher struct {
+	id uint64
+}
+
+func (m orderUpdateMatcher) Matches(x any) bool {
+	update, ok := x.(matching.OrderUpdate)
+	return ok && update.ID == m.id
+}
+
+func (m orderUpdateMatcher) String() string {
+	return fmt.Sprintf("update of order %d", m.id)
+}
+
+// expectDeletes expects deletions of the given orders in the given order.
+func expectDeletes(handler *mockmatching.MockHandler, orders ...gomock.Matcher) {
+	calls := make([]*gomock.Call, 0, len(orders))
+	for _, order := range orders {
+		calls = append(calls, handler.EXPECT().OnDeleteOrder(gomock.Any(), order))
+	}
+	gomock.InOrder(calls...)
+}
+
+// orderMatcher matches the order with the given ID and, if set, the given reason and type.
+type orderMatcher struct {
+	id        uint64
+	reason    matching.OrderReason
+	orderType matching.OrderType
+}
+
+func orderWithID(id uint64) gomock.Matcher {
+	return orderMatcher{id: id}
+}
+
+func orderWithReason(id uint64, reason matching.OrderReason) gomock.Matcher {
+	return orderMatcher{id: id, reason: reason}
+}
+
+func orderWithType(id uint64, orderType matching.OrderType) gomock.Matcher {
+	return orderMatcher{id: id, orderType: orderType}
+}
+
+func (m orderMatcher) Matches(x any) bool {
+	order, ok := x.(*matching.Order)
+	return ok && order.ID() == m.id &&
+		(m.reason == 0 || order.Reason() == m.reason) &&
+		(m.orderType == 0 || order.Type() == m.orderType)
+}
+
+func (m orderMatcher) String() string {
+	s := fmt.Sprintf("order %d", m.id)
+	if m.reason != 0 {
+		s += fmt.Sprintf(" with reason %s", m.reason)
+	}
+	if m.orderType != 0 {
+		s += fmt.Sprintf(" of type %s", m.orderType)
+	}
+	return s
+}
+
+// orderStateMatcher matches the order with the given ID, status and reason.
+type orderStateMatcher struct {
+	id     uint64
+	status matching.OrderStatus
+	reason matching.OrderReason
+}
+
+func orderInState(id uint64, status matching.OrderStatus, reason matching.OrderReason) gomock.Matcher {
+	return orderStateMatcher{id: id, status: status, reason: reason}
+}
+
+func (m orderStateMatcher) Matches(x any) bool {
+	order, ok := x.(*matching.Order)
+	return ok && order.ID() == m.id && order.Status() == m.status && order.Reason() == m.reason
+}
+
+func (m orderStateMatcher) String() string {
+	return fmt.Sprintf("order %d %s %s", m.id, m.status, m.reason)
+}
+
+// recordingHandler implements Handler and records all handled events in textual form,
+// so whole event logs of engines can be compared.
+type recordingHandler struct {
+	mx  sync.Mutex
+	log []string
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{}
+}
+
+func (h *recordingHandler) record(format string, args ...any) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.log = append(h.log, fmt.Sprintf(format, args...))
+}
+
+func (h *recordingHandler) events() []string {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	return append([]string(nil), h.log...)
+}
+
+func (h *recordingHandler) trades() []string {
+	trades := []string{}
+	for _, event := range h.events() {
+		var maker, taker uint64
+		if _, err := fmt.Sscanf(event, "trade maker=%d taker=%d", &maker, &taker); err == nil {
+			trades = append(trades, fmt.Sprintf("trade maker=%d taker=%d", maker, taker))
+		}
+	}
+	return trades
+}
+
+func (h *recordingHandler) OnAddOrderBook(ob *matching.OrderBook) {
+	h.record("add order book %d", ob.Symbol().ID())
+}
+
+func (h *recordingHandler) OnUpdateOrderBook(ob *matching.OrderBook) {
+	h.record("update order book %d state=%s", ob.Symbol().ID(), ob.TradingState())
+}
+
+func (h *recordingHandler) OnDeleteOrderBook(ob *matching.OrderBook) {
+	h.record("delete order book %d", ob.Symbol().ID())
+}
+
+func (h *recordingHandler) OnAddPriceLevel(ob *matching.OrderBook, update matching.PriceLevelUpdate) {
+	h.record("add price level %+v", update)
+}
+
+func (h *recordingHandler) OnUpdatePriceLevel(ob *matching.OrderBook, update matching.PriceLevelUpdate) {
+	h.record("update price level %+v", update)
+}
+
+func (h *recordingHandler) OnDeletePriceLevel(ob *matching.OrderBook, update matching.PriceLevelUpdate) {
+	h.record("delete price level %+v", update)
+}
+
+func (h *recordingHandler) OnAddOrder(ob *matching.OrderBook, order *matching.Order) {
+	h.record("add order %d price=%s rest=%s", order.ID(), order.Price(), order.RestQuantity())
+}
+
+func (h *recordingHandler) OnActivateOrder(ob *matching.OrderBook, order *matching.Order) {
+	h.record("activate order %d", order.ID())
+}
+
+func (h *recordingHandler) OnUpdateOrder(ob *matching.OrderBook, order *matching.Order) {
+	h.record("update order %d price=%s rest=%s reason=%s", order.ID(), order.Price(), order.RestQuantity(), order.Reason())
+}
+
+func (h *recordingHandler) OnDeleteOrder(ob *matching.OrderBook, order *matching.Order) {
+	h.record("delete order %d reason=%s", order.ID(), order.Reason())
+}
+
+func (h *recordingHandler) OnRejectOrder(ob *matching.OrderBook, order *matching.Order, reason error) {
+	h.record("reject order %d reason=%s", order.ID(), reason)
+}
+
+func (h *recordingHandler) OnExecuteOrder(ob *matching.OrderBook, orderID uint64, price, quantity, quoteQuantity matching.Uint) {
+	h.record("execute order %d price=%s qty=%s quote=%s", orderID, price, quantity, quoteQuantity)
+}
+
+func (h *recordingHandler) OnExecuteTrade(ob *matching.OrderBook, maker, taker matching.OrderUpdate, price, quantity, quoteQuantity matching.Uint) {
+	h.record("trade maker=%d taker=%d price=%s qty=%s", maker.ID, taker.ID, price, quantity)
+}
+
+func (h *recordingHandler) OnError(ob *matching.OrderBook, err error) {
+	h.record("error %s", err)
+}
+
+// tradingStateMatcher matches order books in the given trading state.
+type tradingStateMatcher struct {
+	state matching.TradingState
+}
+
+func inTradingState(state matching.TradingState) gomock.Matcher {
+	return tradingStateMatcher{state: state}
+}
+
+func (m tradingStateMatcher) Matches(x any) bool {
+	ob, ok := x.(*matching.OrderBook)
+	return ok && ob.TradingState() == m.state
+}
+
+func (m tradingStateMatcher) String() string {
+	return fmt.Sprintf("order book in state %s", m.state)
 }
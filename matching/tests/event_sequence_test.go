@@ -0,0 +1,238 @@
+package matching_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+// sequenceHandler checks sequence numbers of events in addition to events of the recording handler.
+// Events are handled one by one, so the number of the next event follows the number of recorded events.
+type sequenceHandler struct {
+	*recordingHandler
+	base       uint64 // sequence number of the last event before the handler is used
+	mismatches int
+}
+
+func (h *sequenceHandler) OnSequence(sequence uint64) {
+	if sequence != h.base+uint64(len(h.events()))+1 {
+		h.mismatches++
+	}
+}
+
+// failingWriter fails all writes.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk is full")
+}
+
+func TestEventSequence(t *testing.T) {
+	t.Run("deterministic", func(t *testing.T) {
+		// Events of all order books are passed in the same order in both modes
+		var expected []string
+		for i, multithread := range []bool{false, true, true, true, true, true} {
+			handler := &sequenceHandler{recordingHandler: newRecordingHandler()}
+			engine := matching.NewEngine(handler, multithread)
+			engine.SetClock(matching.NewManualClock(testTime))
+			setupSequenceState(t, engine)
+			addSequenceOrders(engine, 0, 30)
+
+			// Crossed orders of all order books are matched by the single command
+			engine.EnableMatching()
+			addSequenceOrders(engine, 30, 50)
+			_, err := engine.MassCancel(matching.MassCancelFilter{SymbolID: 2})
+			require.NoError(t, err)
+			_, err = engine.DeleteOrderBook(3)
+			require.NoError(t, err)
+			engine.Stop(false)
+
+			events := handler.events()
+			require.Zero(t, handler.mismatches)
+			require.Equal(t, uint64(len(events)), engine.EventSequence())
+			if i == 0 {
+				require.Contains(t, events, "reject order 1500 reason="+matching.ErrInvalidOrderSide.Error())
+				require.Contains(t, events, "reject order 3011 reason="+matching.ErrOrderBookNotFound.Error())
+				expected = events
+				continue
+			}
+			require.Equal(t, expected, events)
+		}
+	})
+
+	t.Run("errors of the journal", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			handler := &sequenceHandler{recordingHandler: newRecordingHandler()}
+			engine := matching.NewEngine(handler, multithread)
+			engine.SetClock(matching.NewManualClock(testTime))
+			setupSequenceState(t, engine)
+			engine.SetJournal(matching.NewJournal(failingWriter{}))
+
+			// Failed engine-wide command is reported without the order book and numbered too
+			engine.EnableMatching()
+			engine.SetJournal(nil)
+			require.NoError(t, engine.AddOrder(newLimitOrder(1, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 1)))
+			engine.Stop(false)
+
+			require.Zero(t, handler.mismatches)
+			events := handler.events()
+			require.Equal(t, uint64(len(events)), engine.EventSequence())
+			require.Contains(t, events[sequenceBooks], "error failed to journal command")
+		}
+	})
+
+	t.Run("snapshot and replay", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			handler := &sequenceHandler{recordingHandler: newRecordingHandler()}
+			source := matching.NewEngine(handler, multithread)
+			source.SetClock(matching.NewManualClock(testTime))
+			var journal bytes.Buffer
+			source.SetJournal(matching.NewJournal(&journal))
+			source.EnableMatching()
+			setupSequenceState(t, source)
+			addSequenceOrders(source, 0, 20)
+
+			var snapshot bytes.Buffer
+			require.NoError(t, source.Snapshot(&snapshot))
+			sequence := source.EventSequence()
+
+			// Rejected orders are journaled, so replayed events have the same numbers
+			addSequenceOrders(source, 20, 40)
+			source.Stop(false)
+
+			restoredHandler := &sequenceHandler{recordingHandler: newRecordingHandler(), base: sequence}
+			restored := matching.NewEngine(restoredHandler, multithread)
+			restored.SetClock(matching.NewManualClock(testTime))
+			require.NoError(t, restored.Restore(&snapshot))
+			require.Equal(t, sequence, restored.EventSequence())
+			require.NoError(t, restored.Replay(bytes.NewReader(journal.Bytes())))
+			restored.Stop(false)
+
+			require.Zero(t, restoredHandler.mismatches)
+			require.Equal(t, handler.events()[sequence:], restoredHandler.events())
+			require.Equal(t, source.EventSequence(), restored.EventSequence())
+		}
+	})
+
+	t.Run("order books do not wait for each other", func(t *testing.T) {
+		// Events of the order book are passed in the turn of the command, but
+		// other order books perform their next tasks without waiting for the turn
+		blocked, released := make(chan struct{}), make(chan struct{})
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddOrder(gomock.Any(), orderWithID(1)).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				close(blocked)
+				<-released
+			})
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, true, 100)
+		_, err := engine.AddOrderBook(matching.NewSymbol(symbolID+1, "ETH-USDT"), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+
+		// Events of previous commands are passed before the handler is blocked
+		_, err = engine.Depth(symbolID+1, 0)
+		require.NoError(t, err)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		<-blocked
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID+1, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 1)))
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := engine.Depth(symbolID+1, 0)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			close(released)
+			t.Fatal("order book waits for the turn of its events")
+		}
+
+		sequence := engine.EventSequence()
+		close(released)
+		engine.Stop(false)
+		require.Greater(t, engine.EventSequence(), sequence)
+	})
+
+	t.Run("pending events are bounded", func(t *testing.T) {
+		// The order book waits for the turn of its task if events of the maximum number of its tasks are pending
+		blocked, released := make(chan struct{}), make(chan struct{})
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddOrder(gomock.Any(), orderWithID(1)).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				close(blocked)
+				<-released
+			})
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, true, matching.WithMaxPendingEvents(1))
+		engine.SetClock(matching.NewManualClock(testTime))
+		engine.EnableMatching()
+		for _, symbol := range []matching.Symbol{testSymbol(), matching.NewSymbol(symbolID+1, "ETH-USDT")} {
+			_, err := engine.AddOrderBook(symbol, price(100), matching.StopPriceModeConfig{Market: true})
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		<-blocked
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID+1, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID+1, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 98, 1)))
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := engine.Depth(symbolID+1, 0)
+			done <- err
+		}()
+		select {
+		case <-done:
+			close(released)
+			t.Fatal("order book does not wait for pending events")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(released)
+		require.NoError(t, <-done)
+		engine.Stop(false)
+	})
+}
+
+// sequenceBooks is the number of order books events of which are numbered by the single sequence.
+const sequenceBooks = 3
+
+// setupSequenceState adds order books with symbol IDs from 1 to sequenceBooks.
+func setupSequenceState(t *testing.T, engine *matching.Engine) {
+	for symbolID := uint32(1); symbolID <= sequenceBooks; symbolID++ {
+		_, err := engine.AddOrderBook(matching.NewSymbol(symbolID, "BTC-USDT"), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+	}
+}
+
+// addSequenceOrders adds orders from the given range to all order books in turn including invalid and duplicated ones.
+func addSequenceOrders(engine *matching.Engine, from, to int) {
+	for i := from; i < to; i++ {
+		for symbolID := uint32(1); symbolID <= sequenceBooks; symbolID++ {
+			id := uint64(symbolID)*1000 + uint64(i)
+			side := matching.OrderSideBuy
+			if i%2 == 1 {
+				side = matching.OrderSideSell
+			}
+			_ = engine.AddOrder(newLimitOrder(symbolID, id, 0, side, matching.OrderTimeInForceGTC, 99+uint64(i%3), 1+uint64(i%4)))
+
+			switch {
+			case i%7 == 0:
+				_ = engine.AddOrder(newLimitOrder(symbolID, id+500, 0, 0, matching.OrderTimeInForceGTC, 100, 1))
+			case i%10 == 0:
+				_ = engine.AddOrder(newLimitOrder(symbolID, id, 0, side, matching.OrderTimeInForceGTC, 100, 1))
+			case i%11 == 0:
+				_ = engine.AddOrder(newLimitOrder(sequenceBooks+1, id, 0, side, matching.OrderTimeInForceGTC, 100, 1))
+			}
+		}
+	}
+}
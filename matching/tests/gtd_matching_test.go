@@ -0,0 +1,228 @@
+package matching_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestGoodTillDate(t *testing.T) {
+	expired := func(handler *mockmatching.MockHandler, ids ...uint64) {
+		for _, id := range ids {
+			handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(id, matching.OrderReasonExpired))
+		}
+	}
+
+	t.Run("expire on next command", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expired(handler, 1)
+		setupMockHandler(t, handler)
+
+		engine, ob, clock := newTestEngine(t, handler, false, 10)
+		order1 := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order1.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order1))
+		order2 := newLimitOrder(symbolID, 2, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 8, 1)
+		order2.SetExpireTime(testTime.Add(time.Hour))
+		require.NoError(t, engine.AddOrder(order2))
+		require.Equal(t, 2, ob.Size())
+
+		clock.Advance(time.Minute)
+		order3 := newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceGTD, 11, 1)
+		order3.SetExpireTime(testTime.Add(time.Hour))
+		require.NoError(t, engine.AddOrder(order3))
+
+		require.Nil(t, ob.Order(1))
+		require.NotNil(t, ob.Order(2))
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("expire orders command", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expired(handler, 1, 2)
+		setupMockHandler(t, handler)
+
+		engine, ob, clock := newTestEngine(t, handler, false, 10)
+		order1 := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order1.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order1))
+		order2 := newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTD, 11, 1)
+		order2.SetExpireTime(testTime.Add(2 * time.Minute))
+		require.NoError(t, engine.AddOrder(order2))
+
+		clock.Advance(time.Minute)
+		engine.ExpireOrders()
+		require.Nil(t, ob.Order(1))
+		require.NotNil(t, ob.Order(2))
+
+		clock.Advance(time.Minute)
+		engine.ExpireOrders()
+		require.Nil(t, ob.Order(2))
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("expire by order book timer", func(t *testing.T) {
+		var journal bytes.Buffer
+		handler := newRecordingHandler()
+		clock := matching.NewManualClock(testTime)
+		engine := matching.NewEngine(handler, true)
+		engine.SetClock(clock)
+		engine.SetJournal(matching.NewJournal(&journal))
+		_, err := engine.AddOrderBook(testSymbol(), price(10), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		order1 := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order1.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order1))
+		order2 := newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTD, 11, 1)
+		order2.SetExpireTime(testTime.Add(time.Hour))
+		require.NoError(t, engine.AddOrder(order2))
+		order3 := newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceGTD, 12, 1)
+		order3.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order3))
+		require.NoError(t, engine.DeleteOrder(symbolID, 3))
+
+		// No command is performed after the clock reaches the expiration
+		clock.Advance(time.Minute)
+		require.Eventually(t, func() bool {
+			return slices.Contains(handler.events(), "delete order 1 reason=expired")
+		}, time.Second, time.Millisecond)
+		engine.Stop(false)
+
+		// Expiration is journaled, so the replayed engine expires the order as well
+		replayedHandler := newRecordingHandler()
+		replayed := matching.NewEngine(replayedHandler, false)
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		require.Nil(t, replayed.OrderBook(symbolID).Order(1))
+		require.NotNil(t, replayed.OrderBook(symbolID).Order(2))
+		require.Equal(t, handler.events(), replayedHandler.events())
+	})
+
+	t.Run("modified order keeps expiration", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expired(handler, 1)
+		setupMockHandler(t, handler)
+
+		engine, ob, clock := newTestEngine(t, handler, false, 10)
+		order1 := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order1.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order1))
+		require.NoError(t, engine.ModifyOrder(symbolID, 1, price(8), price(2)))
+
+		clock.Advance(time.Minute)
+		engine.ExpireOrders()
+		require.Nil(t, ob.Order(1))
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("already expired", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrOrderExpired))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderExpired))
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		order := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order.SetExpireTime(testTime)
+		err := engine.AddOrder(order)
+		require.ErrorIs(t, err, matching.ErrOrderExpired)
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("already expired take-profit and stop-loss", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrOrderExpired))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(2), errorIs(matching.ErrOrderExpired))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderExpired))
+		setupMockHandler(t, handler)
+
+		takeProfit := matching.NewStopLimitOrder(
+			symbolID, 1, 0, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTD,
+			price(12),
+			matching.StopPriceModeMarket,
+			price(12),
+			price(1),
+			matching.NewMaxUint(),
+			price(1),
+		)
+		takeProfit.SetExpireTime(testTime)
+		stopLoss := matching.NewStopLimitOrder(
+			symbolID, 2, 0, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTD,
+			price(8),
+			matching.StopPriceModeMarket,
+			price(8),
+			price(1),
+			matching.NewMaxUint(),
+			matching.NewZeroUint(),
+		)
+		stopLoss.SetExpireTime(testTime)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		err := engine.AddTPSLMarket(takeProfit, stopLoss)
+		require.ErrorIs(t, err, matching.ErrOrderExpired)
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("invalid expire time", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrInvalidOrderExpireTime))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(2), errorIs(matching.ErrInvalidOrderTimeInForce))
+		setupMockHandler(t, handler)
+
+		engine, _, _ := newTestEngine(t, handler, false, 10)
+		order := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order.SetExpireTime(time.Time{})
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidOrderExpireTime)
+
+		order = matching.NewMarketOrder(
+			symbolID, 2, 0, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTD,
+			price(1), matching.NewZeroUint(),
+			matching.NewZeroUint(),
+			price(100),
+		)
+		order.SetExpireTime(testTime.Add(time.Minute))
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidOrderTimeInForce)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		var journal bytes.Buffer
+		handler := newRecordingHandler()
+		clock := matching.NewManualClock(testTime)
+		engine := matching.NewEngine(handler, false)
+		engine.SetClock(clock)
+		engine.SetJournal(matching.NewJournal(&journal))
+		_, err := engine.AddOrderBook(testSymbol(), price(10), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		order1 := newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTD, 9, 1)
+		order1.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order1))
+		order2 := newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTD, 11, 1)
+		order2.SetExpireTime(testTime.Add(time.Hour))
+		require.NoError(t, engine.AddOrder(order2))
+		clock.Advance(time.Minute)
+		engine.ExpireOrders()
+		require.Contains(t, handler.events(), "delete order 1 reason=expired")
+
+		// Replayed engine uses the time of journaled commands instead of its own clock
+		replayedHandler := newRecordingHandler()
+		replayed := matching.NewEngine(replayedHandler, false)
+		replayed.SetClock(matching.NewManualClock(testTime.Add(24 * time.Hour)))
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+
+		require.Nil(t, replayed.OrderBook(symbolID).Order(1))
+		require.NotNil(t, replayed.OrderBook(symbolID).Order(2))
+		require.Equal(t, handler.events(), replayedHandler.events())
+	})
+}
@@ -0,0 +1,162 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestIcebergOrders(t *testing.T) {
+	t.Run("refresh loses time priority", func(t *testing.T) {
+		updates := 0
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderWithID(1)).Do(
+			func(*matching.OrderBook, *matching.Order) { updates++ }).AnyTimes()
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newIcebergOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10, 3)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+		require.True(t, ob.TopAsk().Value().Visible().Equals(price(8)))
+		require.True(t, ob.Order(1).HiddenQuantity().Equals(price(7)))
+
+		// The visible slice is exhausted, so the iceberg is refreshed and moved to the back
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 3)))
+		require.Equal(t, []uint64{2, 1}, askQueue(ob))
+		require.True(t, ob.Order(1).VisibleQuantity().Equals(price(3)))
+		require.True(t, ob.TopAsk().Value().Volume().Equals(price(12)))
+		require.True(t, ob.TopAsk().Value().Visible().Equals(price(8)))
+
+		require.Equal(t, 2, updates)
+
+		// Partially executed slice keeps the time priority
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 6)))
+		require.Equal(t, []uint64{1}, askQueue(ob))
+		require.True(t, ob.Order(1).VisibleQuantity().Equals(price(2)))
+		require.True(t, ob.Order(1).RestQuantity().Equals(price(6)))
+	})
+
+	t.Run("sweep through refreshes", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler,
+			tradeBetween(1, 10),
+			tradeBetween(2, 10),
+			tradeBetween(1, 10),
+			tradeBetween(1, 10),
+			tradeBetween(1, 10),
+		)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newIcebergOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10, 3)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 2)))
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 12)))
+		require.Nil(t, ob.TopAsk())
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("crossed order book", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		// Refreshed iceberg is placed after the resting bid, so the bid becomes the maker
+		expectTrades(t, handler, tradeBetween(1, 10), tradeBetween(2, 10), tradeBetween(10, 1))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		engine.DisableMatching()
+		require.NoError(t, engine.AddOrder(newIcebergOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 6, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 5)))
+
+		engine.EnableMatching()
+		require.True(t, ob.Order(1).RestQuantity().Equals(price(3)))
+		require.True(t, ob.Order(1).VisibleQuantity().Equals(price(1)))
+	})
+
+	t.Run("randomized refresh", func(t *testing.T) {
+		// slices executes exactly the visible slices of the iceberg order and returns them
+		slices := func(engine *matching.Engine) []matching.Uint {
+			result := []matching.Uint{}
+			for i := uint64(0); i < 10; i++ {
+				visible := engine.OrderBook(symbolID).Order(1).VisibleQuantity()
+				require.True(t, visible.GreaterThanOrEqualTo(price(6)) && visible.LessThanOrEqualTo(price(14)))
+				result = append(result, visible)
+
+				taker := matching.NewLimitOrder(
+					symbolID, 10+i, 0, matching.OrderSideBuy,
+					matching.OrderDirectionClose,
+					matching.OrderTimeInForceIOC,
+					price(100), visible,
+					matching.NewMaxUint(),
+					price(1000000),
+				)
+				require.NoError(t, engine.AddOrder(taker))
+			}
+			return result
+		}
+
+		var journal bytes.Buffer
+		handler := newRecordingHandler()
+		engine := matching.NewEngine(handler, false)
+		engine.SetJournal(matching.NewJournal(&journal))
+		engine.EnableMatching()
+		_, err := engine.AddOrderBook(testSymbol(), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		order := newIcebergOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1000, 10)
+		order.SetIcebergVariance(price(4))
+		require.NoError(t, engine.AddOrder(order))
+
+		var snapshot bytes.Buffer
+		require.NoError(t, engine.Snapshot(&snapshot))
+		first := slices(engine)
+		different := false
+		for _, slice := range first {
+			different = different || !slice.Equals(price(10))
+		}
+		require.True(t, different)
+
+		// Slices depend on the secret seed of the order book, which is stored in snapshots and journaled
+		restored := matching.NewEngine(newRecordingHandler(), false)
+		restored.EnableMatching()
+		require.NoError(t, restored.Restore(&snapshot))
+		require.Equal(t, first, slices(restored))
+
+		replayedHandler := newRecordingHandler()
+		replayed := matching.NewEngine(replayedHandler, false)
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		require.Equal(t, handler.events(), replayedHandler.events())
+	})
+
+	t.Run("refreshed order is placed after crossed orders", func(t *testing.T) {
+		// The resting bid becomes the maker since the refreshed iceberg is placed after it
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(2, 1))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		engine.DisableMatching()
+		require.NoError(t, engine.AddOrder(newIcebergOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 6, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 101, 1)))
+		require.NoError(t, engine.ExecuteOrder(symbolID, 1, price(2)))
+
+		engine.EnableMatching()
+		require.Nil(t, ob.Order(2))
+	})
+
+	t.Run("invalid variance", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), gomock.Any(), errorIs(matching.ErrInvalidIcebergVariance)).Times(2)
+		setupMockHandler(t, handler)
+
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		order := newIcebergOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10, 3)
+		order.SetIcebergVariance(price(3))
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidIcebergVariance)
+
+		order = newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)
+		order.SetIcebergVariance(price(1))
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidIcebergVariance)
+	})
+}
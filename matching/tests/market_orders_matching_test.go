@@ -58,6 +58,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		handler.EXPECT().OnDeleteOrder(ob, gomock.Any())
 
 		err = engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -85,6 +86,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		handler.EXPECT().OnDeleteOrder(ob, gomock.Any())
 
 		err = engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -105,6 +107,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -129,6 +132,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -153,6 +157,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -180,6 +185,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		dumpOB(t, engine)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -212,6 +218,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		dumpOB(t, engine)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -241,6 +248,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -266,6 +274,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -290,6 +299,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -318,6 +328,7 @@ func TestMarketOrdersMatching(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 
 		err := engine.AddOrder(matching.NewMarketOrder(symbolID, orderID,
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -0,0 +1,104 @@
+package matching_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestMarketToLimitOrders(t *testing.T) {
+	t.Run("rest at the last execution price", func(t *testing.T) {
+		// The type conversion is reported by the update
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderWithType(10, matching.OrderTypeLimit))
+		expectTrades(t, handler, tradeBetween(1, 10), tradeBetween(2, 10), tradeBetween(10, 3))
+
+		// Asks with quantity 5 at 100 and 101
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 5)))
+		marketToLimit := newMarketToLimitOrder(symbolID, 10, matching.OrderSideBuy, 15, 0)
+		require.True(t, marketToLimit.IsMarketToLimit())
+		require.False(t, marketToLimit.IsMarket())
+		require.NoError(t, engine.AddOrder(marketToLimit))
+
+		order := ob.Order(10)
+		require.NotNil(t, order)
+		require.Equal(t, matching.OrderTypeLimit, order.Type())
+		require.False(t, order.IsMarketToLimit())
+		require.Equal(t, matching.OrderTimeInForceGTC, order.TimeInForce())
+		require.True(t, order.Price().Equals(price(101)))
+		require.True(t, order.RestQuantity().Equals(price(5)))
+		require.True(t, ob.TopBid().Value().Price().Equals(price(101)))
+		require.Nil(t, ob.TopAsk())
+
+		// The rested order is executed as the regular limit order
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 101, 5)))
+		require.Nil(t, ob.Order(10))
+	})
+
+	t.Run("quote quantity", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 5)))
+		require.NoError(t, engine.AddOrder(newMarketToLimitOrder(symbolID, 10, matching.OrderSideBuy, 0, 1010)))
+
+		// 500 is spent at 100 and 505 at 101, the rest 5 is less than the lot at 101
+		require.Nil(t, ob.Order(10))
+
+		handler = mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderWithType(10, matching.OrderTypeLimit))
+		setupMockHandler(t, handler)
+
+		engine, ob, _ = newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 5)))
+		require.NoError(t, engine.AddOrder(newMarketToLimitOrder(symbolID, 10, matching.OrderSideBuy, 0, 1510)))
+
+		// 1005 is spent, the rest 505 rests as quantity 5 at 101
+		order := ob.Order(10)
+		require.NotNil(t, order)
+		require.Equal(t, matching.OrderTypeLimit, order.Type())
+		require.True(t, order.Price().Equals(price(101)))
+		require.True(t, order.RestQuantity().Equals(price(5)))
+		require.True(t, order.Quantity().Equals(price(15)))
+	})
+
+	t.Run("completely executed", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderWithType(10, matching.OrderTypeLimit)).Do(
+			func(*matching.OrderBook, *matching.Order) {
+				t.Error("completely executed order is converted")
+			}).AnyTimes()
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 5)))
+		require.NoError(t, engine.AddOrder(newMarketToLimitOrder(symbolID, 10, matching.OrderSideBuy, 7, 0)))
+
+		require.Nil(t, ob.Order(10))
+		require.True(t, ob.Order(2).RestQuantity().Equals(price(3)))
+	})
+
+	t.Run("no liquidity", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(10, matching.OrderReasonIOCRemainder))
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 5)))
+		require.NoError(t, engine.AddOrder(newMarketToLimitOrder(symbolID, 10, matching.OrderSideSell, 5, 0)))
+
+		require.Nil(t, ob.Order(10))
+		require.Nil(t, ob.TopBid())
+	})
+}
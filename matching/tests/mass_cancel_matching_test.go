@@ -0,0 +1,242 @@
+package matching_test
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestMassCancel(t *testing.T) {
+	// deleted collects IDs of orders canceled by the user
+	deleted := func(handler *mockmatching.MockHandler) *[]uint64 {
+		ids := []uint64{}
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), gomock.Any()).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				require.Equal(t, matching.OrderReasonUserCancel, order.Reason())
+				ids = append(ids, order.ID())
+				slices.Sort(ids)
+			}).AnyTimes()
+		return &ids
+	}
+
+	t.Run("owner", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		ids := deleted(handler)
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
+		engine.EnableMatching()
+		setupOwnerState(t, engine, 1)
+		setupOwnerState(t, engine, 2)
+
+		count, err := engine.MassCancel(matching.MassCancelFilter{SymbolID: 1, OwnerID: 2})
+		require.NoError(t, err)
+		require.Equal(t, 5, count)
+		require.Equal(t, []uint64{101, 103, 111, 113, 121}, *ids)
+		require.Equal(t, 7, engine.OrderBook(1).Size())
+		require.Equal(t, 12, engine.OrderBook(2).Size())
+	})
+
+	t.Run("side and price range", func(t *testing.T) {
+		// Price levels are deleted with their orders
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnDeletePriceLevel(gomock.Any(), priceLevelAt(matching.OrderSideSell, price(102))).Times(2)
+		handler.EXPECT().OnDeletePriceLevel(gomock.Any(), priceLevelAt(matching.OrderSideSell, price(103))).Times(2)
+		ids := deleted(handler)
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
+		engine.EnableMatching()
+		setupOwnerState(t, engine, 1)
+		setupOwnerState(t, engine, 2)
+
+		count, err := engine.MassCancel(matching.MassCancelFilter{
+			AllSymbols: true,
+			Side:       matching.OrderSideSell,
+			MinPrice:   price(102),
+			MaxPrice:   price(103),
+		})
+		require.NoError(t, err)
+		require.Equal(t, 4, count)
+		require.Equal(t, []uint64{111, 112, 211, 212}, *ids)
+	})
+
+	t.Run("stop orders", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		ids := deleted(handler)
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
+		engine.EnableMatching()
+		setupOwnerState(t, engine, 1)
+		setupOwnerState(t, engine, 2)
+
+		count, err := engine.MassCancel(matching.MassCancelFilter{AllSymbols: true, StopOnly: true, MaxPrice: price(100)})
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+		require.Equal(t, []uint64{121, 221}, *ids)
+	})
+
+	t.Run("all orders", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			setupMockHandler(t, handler)
+			engine := matching.NewEngine(handler, multithread)
+			engine.EnableMatching()
+			setupOwnerState(t, engine, 1)
+			setupOwnerState(t, engine, 2)
+
+			count, err := engine.MassCancel(matching.MassCancelFilter{AllSymbols: true})
+			require.NoError(t, err)
+			require.Equal(t, 24, count)
+			require.Equal(t, 0, engine.Orders())
+			engine.Stop(false)
+		}
+	})
+
+	t.Run("zero symbol ID", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
+		engine.EnableMatching()
+		limits := matching.Limits{Min: price(1), Max: price(1000000), Step: price(1)}
+		_, err := engine.AddOrderBook(matching.NewSymbolWithLimits(0, "SYMBOL-0", limits, limits), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		require.NoError(t, engine.AddOrder(newLimitOrder(0, 1, 1, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 1)))
+		setupOwnerState(t, engine, 1)
+
+		// Zero symbol ID selects the order book of the symbol, not all order books
+		count, err := engine.MassCancel(matching.MassCancelFilter{SymbolID: 0})
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		require.Equal(t, 0, engine.OrderBook(0).Size())
+		require.Equal(t, 12, engine.OrderBook(1).Size())
+	})
+
+	t.Run("linked orders", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(30, matching.OrderReasonOCOSibling))
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(31, matching.OrderReasonUserCancel))
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
+		engine.EnableMatching()
+		setupOwnerState(t, engine, 1)
+		setupOwnerState(t, engine, 2)
+		stopLimitOrder := matching.NewStopLimitOrder(
+			1, 30, 3, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(90),
+			matching.StopPriceModeMarket,
+			price(90),
+			price(1),
+			matching.NewMaxUint(),
+			matching.NewZeroUint(),
+		)
+		require.NoError(t, engine.AddOrdersPair(stopLimitOrder, newLimitOrder(1, 31, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 120, 1)))
+
+		count, err := engine.MassCancel(matching.MassCancelFilter{SymbolID: 1, OwnerID: 3})
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+		require.Nil(t, engine.OrderBook(1).Order(31))
+	})
+
+	t.Run("journal replay", func(t *testing.T) {
+		var journal bytes.Buffer
+		source := matching.NewEngine(newRecordingHandler(), false)
+		source.SetJournal(matching.NewJournal(&journal))
+		source.EnableMatching()
+		_, err := source.AddOrderBook(testSymbol(), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		require.NoError(t, source.AddOrder(newLimitOrder(1, 1, 1, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 1)))
+		require.NoError(t, source.AddOrder(newLimitOrder(1, 2, 2, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 98, 1)))
+		_, err = source.MassCancel(matching.MassCancelFilter{AllSymbols: true, OwnerID: 1})
+		require.NoError(t, err)
+
+		replayed := matching.NewEngine(newRecordingHandler(), false)
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		require.Nil(t, replayed.OrderBook(1).Order(1))
+		require.NotNil(t, replayed.OrderBook(1).Order(2))
+		require.Equal(t, source.Sequence(), replayed.Sequence())
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrForbiddenTradingState))
+		ids := deleted(handler)
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
+		engine.EnableMatching()
+		setupOwnerState(t, engine, 1)
+		setupOwnerState(t, engine, 2)
+
+		_, err := engine.MassCancel(matching.MassCancelFilter{SymbolID: 3})
+		require.ErrorIs(t, err, matching.ErrOrderBookNotFound)
+		_, err = engine.MassCancel(matching.MassCancelFilter{AllSymbols: true, Side: 0xff})
+		require.ErrorIs(t, err, matching.ErrInvalidOrderSide)
+
+		require.NoError(t, engine.SetTradingState(1, matching.TradingStateClosed))
+		count, err := engine.MassCancel(matching.MassCancelFilter{AllSymbols: true})
+		require.ErrorIs(t, err, matching.ErrForbiddenTradingState)
+		require.Equal(t, 12, count)
+		require.False(t, slices.ContainsFunc(*ids, func(id uint64) bool { return id < 200 }))
+	})
+}
+
+// setupOwnerState adds the order book with bids at 95..99, asks at 101..105 and stop orders of two owners.
+func setupOwnerState(t *testing.T, engine *matching.Engine, symbolID uint32) {
+	limits := matching.Limits{Min: price(1), Max: price(1000000), Step: price(1)}
+	symbol := matching.NewSymbolWithLimits(symbolID, fmt.Sprintf("SYMBOL-%d", symbolID), limits, limits)
+	_, err := engine.AddOrderBook(symbol, price(100), matching.StopPriceModeConfig{Market: true})
+	require.NoError(t, err)
+
+	id := uint64(symbolID) * 100
+	for i := uint64(0); i < 5; i++ {
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, id+i, 1+i%2, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 95+i, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, id+10+i, 1+i%2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101+i, 1)))
+	}
+	for _, stop := range []struct {
+		id      uint64
+		ownerID uint64
+		side    matching.OrderSide
+		price   uint64
+	}{
+		{id + 20, 1, matching.OrderSideBuy, 110},
+		{id + 21, 2, matching.OrderSideSell, 90},
+	} {
+		require.NoError(t, engine.AddOrder(matching.NewStopLimitOrder(
+			symbolID, stop.id, stop.ownerID, stop.side,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(stop.price),
+			matching.StopPriceModeMarket,
+			price(stop.price),
+			price(1),
+			matching.NewMaxUint(),
+			price(1000000),
+		)))
+	}
+}
+
+// priceLevelMatcher matches price level updates of the given side and price.
+type priceLevelMatcher struct {
+	side  matching.OrderSide
+	price matching.Uint
+}
+
+func priceLevelAt(side matching.OrderSide, price matching.Uint) gomock.Matcher {
+	return priceLevelMatcher{side: side, price: price}
+}
+
+func (m priceLevelMatcher) Matches(x any) bool {
+	update, ok := x.(matching.PriceLevelUpdate)
+	return ok && update.Side == m.side && update.Price.Equals(m.price)
+}
+
+func (m priceLevelMatcher) String() string {
+	return fmt.Sprintf("price level %s %s", m.side, m.price)
+}
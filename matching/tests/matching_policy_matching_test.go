@@ -0,0 +1,155 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+)
+
+func TestMatchingPolicy(t *testing.T) {
+	executed := func(ob *matching.OrderBook, ids ...uint64) []matching.Uint {
+		result := make([]matching.Uint, 0, len(ids))
+		for _, id := range ids {
+			result = append(result, ob.Order(id).ExecutedQuantity())
+		}
+		return result
+	}
+
+	t.Run("fifo", func(t *testing.T) {
+		for _, policy := range []matching.MatchingPolicy{nil, matching.FIFOPolicy{}} {
+			engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(policy))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 10, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 20)))
+			require.Nil(t, ob.Order(1))
+			require.Equal(t, []matching.Uint{price(10), price(0)}, executed(ob, 2, 3))
+		}
+	})
+
+	t.Run("pro-rata", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(matching.ProRataPolicy{}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 10, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 20)))
+		require.Equal(t, []matching.Uint{price(2), price(6), price(12)}, executed(ob, 1, 2, 3))
+		require.Nil(t, ob.Order(10))
+	})
+
+	t.Run("pro-rata rounding", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(matching.ProRataPolicy{}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 10, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 15)))
+
+		// Allocations 1.5, 4.5 and 9 are rounded down and the rest is allocated by FIFO
+		require.Equal(t, []matching.Uint{price(2), price(4), price(9)}, executed(ob, 1, 2, 3))
+	})
+
+	t.Run("pro-rata minimum allocation", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(matching.ProRataPolicy{MinAllocation: price(5)}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 10, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 15)))
+
+		// Allocations 1 and 4 are dropped and the rest is allocated by FIFO
+		require.Equal(t, []matching.Uint{price(6), price(0), price(9)}, executed(ob, 1, 2, 3))
+	})
+
+	t.Run("pro-rata large quantities", func(t *testing.T) {
+		// Products of quantities exceed the range of Uint
+		orders := []matching.Allocation{{Quantity: price(20_000_000)}, {Quantity: price(60_000_000)}}
+		allocated := matching.ProRataPolicy{}.Allocate(orders, price(40_000_000), price(1))
+		require.Len(t, allocated, 2)
+		require.Equal(t, price(10_000_000), allocated[0].Quantity)
+		require.Equal(t, price(30_000_000), allocated[1].Quantity)
+	})
+
+	t.Run("pro-rata sweep", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(matching.ProRataPolicy{}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 10, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 101, 105)))
+
+		// The first price level is fully executed and the rest is matched with the next one
+		require.Nil(t, ob.Order(1))
+		require.Nil(t, ob.Order(2))
+		require.Nil(t, ob.Order(3))
+		require.True(t, ob.Order(4).ExecutedQuantity().Equals(price(5)))
+		require.Nil(t, ob.Order(10))
+	})
+
+	t.Run("hybrid", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(matching.HybridPolicy{
+			MarketMakers:     []uint64{3},
+			MarketMakerShare: 5000,
+		}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 10, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 20)))
+
+		// Market maker gets 10, the rest 10 is allocated pro-rata to 10, 30 and 50
+		require.Equal(t, []matching.Uint{price(2), price(3), price(15)}, executed(ob, 1, 2, 3))
+	})
+
+	t.Run("self-trade prevention", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(matching.ProRataPolicy{}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		order := newLimitOrder(symbolID, 10, 2, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 14)
+		order.SetSelfTradePrevention(matching.SelfTradePreventionCancelOldest)
+		require.NoError(t, engine.AddOrder(order))
+
+		// Resting order of the same owner is cancelled and the rest is allocated to 10 and 60
+		require.Nil(t, ob.Order(2))
+		require.Equal(t, []matching.Uint{price(2), price(12)}, executed(ob, 1, 3))
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(nil))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+		err := engine.SetMatchingPolicy(symbolID, matching.HybridPolicy{MarketMakerShare: 6000, FIFOShare: 5000})
+		require.ErrorIs(t, err, matching.ErrInvalidMatchingPolicy)
+		require.ErrorIs(t, engine.SetMatchingPolicy(2, nil), matching.ErrOrderBookNotFound)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		policy := matching.HybridPolicy{
+			MarketMakers:     []uint64{3, 5},
+			MarketMakerShare: 2000,
+			FIFOShare:        3000,
+			MinAllocation:    price(2),
+		}
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100, matching.WithMatchingPolicy(policy))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 1, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 2, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 3, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 4, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10)))
+
+		var buf bytes.Buffer
+		require.NoError(t, engine.Snapshot(&buf))
+
+		restored := matching.NewEngine(newRecordingHandler(), false)
+		require.NoError(t, restored.Restore(&buf))
+		require.Equal(t, policy, restored.OrderBook(symbolID).MatchingPolicy())
+	})
+}
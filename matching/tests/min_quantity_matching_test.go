@@ -0,0 +1,186 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestMinQuantityOrders(t *testing.T) {
+	withMin := func(order matching.Order, q uint64) matching.Order {
+		order.SetMinQuantity(price(q))
+		return order
+	}
+	allOrNone := func(order matching.Order) matching.Order {
+		order.SetAllOrNone(true)
+		return order
+	}
+	t.Run("minimum quantity of IOC order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(1, 11), tradeBetween(2, 11))
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 5)))
+
+		// Only 10 can be executed immediately
+		require.NoError(t, engine.AddOrder(withMin(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 101, 20), 12)))
+		require.Nil(t, ob.Order(10))
+
+		require.NoError(t, engine.AddOrder(withMin(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 101, 20), 8)))
+		require.Nil(t, ob.TopAsk())
+	})
+
+	t.Run("minimum quantity of resting order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(10, 3), tradeBetween(1, 10))
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+
+		// The order is placed into the order book without execution
+		require.NoError(t, engine.AddOrder(withMin(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 20), 8)))
+		require.NotNil(t, ob.Order(10))
+		require.NotNil(t, ob.Order(1))
+
+		// The first execution still requires the minimum quantity
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceIOC, 100, 6)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceIOC, 100, 10)))
+
+		// After the first execution the rest of the order is crossed with the skipped order
+		require.True(t, ob.Order(10).RestQuantity().Equals(price(5)))
+		require.Nil(t, ob.TopAsk())
+	})
+
+	t.Run("all-or-none resting order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(2, 10), tradeBetween(3, 10), tradeBetween(1, 11))
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10))))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 5)))
+
+		// All-or-none order is skipped keeping its time priority
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 101, 8)))
+		require.True(t, ob.Order(1).ExecutedQuantity().IsZero())
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.Equal(t, []uint64{1, 4}, askQueue(ob))
+
+		// All-or-none order is executed completely
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 10)))
+		require.Nil(t, ob.Order(1))
+		require.Equal(t, []uint64{4}, askQueue(ob))
+	})
+
+	t.Run("all-or-none taker order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(1, 11), tradeBetween(2, 11))
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 10))))
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 10))))
+		require.Nil(t, ob.TopAsk())
+	})
+
+	t.Run("fill-or-kill skips all-or-none order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(10), errorIs(matching.ErrFOKUnfillable))
+		expectTrades(t, handler, tradeBetween(2, 11))
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10))))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 5)))
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceFOK, 101, 6)))
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceFOK, 101, 5)))
+		require.NotNil(t, ob.Order(1))
+	})
+
+	t.Run("crossed order book", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(1, 11))
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		engine.DisableMatching()
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10))))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 5)))
+
+		// Crossed orders are left in the order book
+		engine.EnableMatching()
+		require.NotNil(t, ob.Order(1))
+		require.NotNil(t, ob.Order(10))
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 10)))
+		require.Nil(t, ob.Order(1))
+	})
+
+	t.Run("pro-rata skips all-or-none order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.SetMatchingPolicy(symbolID, matching.ProRataPolicy{}))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10)))
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 30))))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 60)))
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 20)))
+		require.True(t, ob.Order(2).ExecutedQuantity().IsZero())
+		executed := ob.Order(1).ExecutedQuantity().Add(ob.Order(3).ExecutedQuantity())
+		require.True(t, executed.Equals(price(20)))
+
+		// All-or-none order is allocated when it can be executed completely
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 80)))
+		require.Nil(t, ob.Order(1))
+		require.Nil(t, ob.Order(2))
+		require.Nil(t, ob.Order(3))
+	})
+
+	t.Run("invalid orders", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(1), errorIs(matching.ErrInvalidOrderMinQuantity))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(2), errorIs(matching.ErrInvalidOrderAllOrNone))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(3), errorIs(matching.ErrInvalidOrderMinQuantity))
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		order := withMin(newLimitOrder(symbolID, 1, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 10), 11)
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidOrderMinQuantity)
+
+		order = matching.NewLimitOrder(
+			symbolID, 2, 0, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(100), price(10),
+			price(5),
+			price(1000000),
+		)
+		require.ErrorIs(t, engine.AddOrder(allOrNone(order)), matching.ErrInvalidOrderAllOrNone)
+
+		order = matching.NewMarketOrder(
+			symbolID, 3, 0, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			price(10), price(0),
+			price(1000000),
+			price(1000000),
+		)
+		require.ErrorIs(t, engine.AddOrder(withMin(order, 5)), matching.ErrInvalidOrderMinQuantity)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(allOrNone(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 10))))
+		require.NoError(t, engine.AddOrder(withMin(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 10), 4)))
+
+		restored := matching.NewEngine(newRecordingHandler(), false)
+		require.NoError(t, restored.Restore(bytes.NewReader(takeSnapshot(t, engine))))
+		ob := restored.OrderBook(symbolID)
+		require.True(t, ob.Order(1).IsAllOrNone())
+		require.True(t, ob.Order(2).MinQuantity().Equals(price(4)))
+	})
+}
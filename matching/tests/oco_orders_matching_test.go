@@ -36,6 +36,7 @@ func TestOCOOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -53,6 +54,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -66,6 +68,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -91,11 +94,13 @@ func TestOCOOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrBuyOCOStopPriceLessThanMarketPrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrBuyOCOStopPriceLessThanMarketPrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrBuyOCOStopPriceLessThanMarketPrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -113,6 +118,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -126,6 +132,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -149,11 +156,13 @@ func TestOCOOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrBuyOCOLimitPriceGreaterThanMarketPrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrBuyOCOLimitPriceGreaterThanMarketPrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrBuyOCOLimitPriceGreaterThanMarketPrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -171,6 +180,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -184,6 +194,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -207,11 +218,13 @@ func TestOCOOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrSellOCOStopPriceGreaterThanMarketPrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrSellOCOStopPriceGreaterThanMarketPrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrSellOCOStopPriceGreaterThanMarketPrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -229,6 +242,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -242,6 +256,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -265,11 +280,13 @@ func TestOCOOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrSellOCOLimitPriceLessThanMarketPrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrSellOCOLimitPriceLessThanMarketPrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrSellOCOLimitPriceLessThanMarketPrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -287,6 +304,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -300,6 +318,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -323,6 +342,7 @@ func TestOCOOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -340,6 +360,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -353,6 +374,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -383,6 +405,7 @@ func TestOCOOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -400,6 +423,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -413,6 +437,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -443,6 +468,7 @@ func TestOCOOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -460,6 +486,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -473,6 +500,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -498,6 +526,7 @@ func TestOCOOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -515,6 +544,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -528,6 +558,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -553,6 +584,7 @@ func TestOCOOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -570,6 +602,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -583,6 +616,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -638,6 +672,7 @@ func TestOCOOrders(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(1),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -651,6 +686,7 @@ func TestOCOOrders(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(2),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -719,6 +755,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(3),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -732,6 +769,7 @@ func TestOCOOrders(t *testing.T) {
 			matching.NewLimitOrder(
 				symbolID,
 				uint64(4),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -746,6 +784,7 @@ func TestOCOOrders(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -0,0 +1,157 @@
+package matching_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestOrderReject(t *testing.T) {
+	// rejected expects the reject of the order by the order book with the given reason
+	rejected := func(handler *mockmatching.MockHandler, id uint64, reason error) *gomock.Call {
+		return handler.EXPECT().OnRejectOrder(gomock.Not(gomock.Nil()), orderInState(id, matching.OrderStatusRejected, matching.OrderReasonNone), errorIs(reason))
+	}
+
+	t.Run("invalid orders", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Nil(), orderInState(1, matching.OrderStatusRejected, matching.OrderReasonNone), errorIs(matching.ErrOrderBookNotFound))
+		rejected(handler, 2, matching.ErrInvalidOrderSide)
+		rejected(handler, 3, matching.ErrNotEnoughLockedAmount)
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+
+		unknown := matching.NewLimitOrder(
+			symbolID+1, 1, 0, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(100), price(1),
+			matching.NewMaxUint(),
+			price(1),
+		)
+		require.ErrorIs(t, engine.AddOrder(unknown), matching.ErrOrderBookNotFound)
+		require.ErrorIs(t, engine.AddOrder(limitOrder(2, 0, 100, 1)), matching.ErrInvalidOrderSide)
+
+		locked := matching.NewLimitOrder(
+			symbolID, 3, 0, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(100), price(1),
+			matching.NewMaxUint(),
+			matching.NewZeroUint(),
+		)
+		require.ErrorIs(t, engine.AddOrder(locked), matching.ErrNotEnoughLockedAmount)
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("rejected by order book", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		rejected(handler, 1, matching.ErrOrderDuplicate)
+		rejected(handler, 2, matching.ErrForbiddenTradingState)
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderDuplicate))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrForbiddenTradingState))
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 1)))
+		require.ErrorIs(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)), matching.ErrOrderDuplicate)
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateHalted))
+		require.ErrorIs(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 100, 1)), matching.ErrForbiddenTradingState)
+
+		// The existing order is not affected by rejects
+		require.Equal(t, 1, ob.Size())
+		require.Equal(t, matching.OrderStatusNew, ob.Order(1).Status())
+	})
+
+	t.Run("rejected by matching", func(t *testing.T) {
+		for _, multithread := range []bool{false, true} {
+			// Orders are rejected after their deletion and the caller gets no error
+			handler := mockmatching.NewMockHandler(gomock.NewController(t))
+			gomock.InOrder(
+				handler.EXPECT().OnDeleteOrder(gomock.Any(), orderInState(10, matching.OrderStatusRejected, matching.OrderReasonPostOnly)),
+				handler.EXPECT().OnRejectOrder(gomock.Not(gomock.Nil()), orderInState(10, matching.OrderStatusRejected, matching.OrderReasonPostOnly), errorIs(matching.ErrPostOnlyWouldTake)),
+				handler.EXPECT().OnDeleteOrder(gomock.Any(), orderInState(11, matching.OrderStatusRejected, matching.OrderReasonFOKUnfillable)),
+				handler.EXPECT().OnRejectOrder(gomock.Not(gomock.Nil()), orderInState(11, matching.OrderStatusRejected, matching.OrderReasonFOKUnfillable), errorIs(matching.ErrFOKUnfillable)),
+			)
+			expectTrades(t, handler)
+			engine, ob, _ := newTestEngine(t, handler, multithread, 100)
+
+			require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 1)))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnly, 100, 1)))
+			require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideBuy, matching.OrderTimeInForceFOK, 100, 2)))
+			engine.Stop(false)
+			require.Equal(t, 1, ob.Size())
+		}
+	})
+
+	t.Run("orders pair", func(t *testing.T) {
+		// Both orders of the pair are rejected by the price check, and none of orders
+		// is added if any of them is duplicated
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		rejected(handler, 1, matching.ErrSellOCOStopPriceGreaterThanMarketPrice)
+		rejected(handler, 2, matching.ErrSellOCOStopPriceGreaterThanMarketPrice)
+		rejected(handler, 3, matching.ErrOrderDuplicate)
+		rejected(handler, 4, matching.ErrOrderDuplicate)
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrSellOCOStopPriceGreaterThanMarketPrice))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderDuplicate))
+		setupMockHandler(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+
+		err := engine.AddOrdersPair(
+			matching.NewStopLimitOrder(
+				symbolID, 1, 0, matching.OrderSideSell,
+				matching.OrderDirectionClose,
+				matching.OrderTimeInForceGTC,
+				price(105),
+				matching.StopPriceModeMarket,
+				price(105),
+				price(1),
+				matching.NewMaxUint(),
+				matching.NewZeroUint(),
+			),
+			limitOrder(2, matching.OrderSideSell, 110, 1),
+		)
+		require.ErrorIs(t, err, matching.ErrSellOCOStopPriceGreaterThanMarketPrice)
+
+		require.NoError(t, engine.AddOrder(limitOrder(4, matching.OrderSideSell, 120, 1)))
+		err = engine.AddOrdersPair(
+			matching.NewStopLimitOrder(
+				symbolID, 3, 0, matching.OrderSideSell,
+				matching.OrderDirectionClose,
+				matching.OrderTimeInForceGTC,
+				price(95),
+				matching.StopPriceModeMarket,
+				price(95),
+				price(1),
+				matching.NewMaxUint(),
+				matching.NewZeroUint(),
+			),
+			limitOrder(4, matching.OrderSideSell, 110, 1),
+		)
+		require.ErrorIs(t, err, matching.ErrOrderDuplicate)
+		require.Equal(t, 1, ob.Size())
+		require.True(t, ob.Order(4).Price().Equals(price(120)))
+	})
+
+	t.Run("multithread", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		rejected(handler, 1, matching.ErrOrderDuplicate)
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderDuplicate))
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, true, 100)
+
+		_, err := engine.AddOrderWait(context.Background(), limitOrder(1, matching.OrderSideSell, 100, 1))
+		require.NoError(t, err)
+		_, err = engine.AddOrderWait(context.Background(), limitOrder(1, matching.OrderSideSell, 101, 1))
+		require.ErrorIs(t, err, matching.ErrOrderDuplicate)
+
+		var cmdErr *matching.CommandError
+		require.True(t, errors.As(err, &cmdErr))
+		require.Equal(t, matching.ErrorPhaseValidate, cmdErr.Phase)
+	})
+}
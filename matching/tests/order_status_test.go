@@ -0,0 +1,145 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestOrderStatus(t *testing.T) {
+	t.Run("execution and cancel", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		gomock.InOrder(
+			handler.EXPECT().OnAddOrder(gomock.Any(), orderInState(1, matching.OrderStatusNew, matching.OrderReasonNone)),
+			handler.EXPECT().OnAddOrder(gomock.Any(), orderInState(2, matching.OrderStatusNew, matching.OrderReasonNone)),
+			handler.EXPECT().OnDeleteOrder(gomock.Any(), orderInState(2, matching.OrderStatusFilled, matching.OrderReasonNone)),
+			handler.EXPECT().OnUpdateOrder(gomock.Any(), orderInState(1, matching.OrderStatusPartiallyFilled, matching.OrderReasonNone)),
+			handler.EXPECT().OnDeleteOrder(gomock.Any(), orderInState(1, matching.OrderStatusCancelled, matching.OrderReasonUserCancel)),
+		)
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 2)))
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+	})
+
+	t.Run("immediate orders", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(3), errorIs(matching.ErrFOKUnfillable))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(5), errorIs(matching.ErrPostOnlyWouldTake))
+		expectDeletes(handler,
+			orderInState(3, matching.OrderStatusRejected, matching.OrderReasonFOKUnfillable),
+			orderInState(1, matching.OrderStatusFilled, matching.OrderReasonNone),
+			orderInState(4, matching.OrderStatusCancelled, matching.OrderReasonIOCRemainder),
+			orderInState(5, matching.OrderStatusRejected, matching.OrderReasonPostOnly),
+		)
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideBuy, matching.OrderTimeInForceFOK, 101, 3)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 0, matching.OrderSideBuy, matching.OrderTimeInForceIOC, 100, 3)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 5, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnly, 101, 1)))
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectDeletes(handler, orderInState(1, matching.OrderStatusExpired, matching.OrderReasonExpired))
+		setupMockHandler(t, handler)
+		engine, _, clock := newTestEngine(t, handler, false, 100)
+		order := newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTD, 100, 1)
+		order.SetExpireTime(testTime.Add(time.Minute))
+		require.NoError(t, engine.AddOrder(order))
+
+		clock.Advance(time.Minute)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+	})
+
+	t.Run("triggered and linked orders", func(t *testing.T) {
+		// Linked order is deleted with the deleted order of the pair
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnActivateOrder(gomock.Any(), orderInState(3, matching.OrderStatusTriggered, matching.OrderReasonNone))
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderInState(3, matching.OrderStatusTriggered, matching.OrderReasonNone)).MinTimes(1)
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderInState(4, matching.OrderStatusPartiallyFilled, matching.OrderReasonNone)).MinTimes(1)
+		expectDeletes(handler,
+			orderInState(5, matching.OrderStatusFilled, matching.OrderReasonNone),
+			orderInState(3, matching.OrderStatusFilled, matching.OrderReasonNone),
+			orderInState(1, matching.OrderStatusCancelled, matching.OrderReasonOCOSibling),
+			orderInState(2, matching.OrderStatusCancelled, matching.OrderReasonUserCancel),
+		)
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrdersPair(
+			matching.NewStopLimitOrder(
+				symbolID, 1, 0, matching.OrderSideSell,
+				matching.OrderDirectionClose,
+				matching.OrderTimeInForceGTC,
+				price(95),
+				matching.StopPriceModeMarket,
+				price(95),
+				price(1),
+				matching.NewMaxUint(),
+				matching.NewZeroUint(),
+			),
+			newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 110, 1),
+		))
+		require.NoError(t, engine.AddOrder(matching.NewStopLimitOrder(
+			symbolID, 3, 0, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(105),
+			matching.StopPriceModeMarket,
+			price(105),
+			price(1),
+			matching.NewMaxUint(),
+			price(1000000),
+		)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 105, 3)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 5, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 105, 1)))
+
+		require.Equal(t, matching.OrderStatusPartiallyFilled, engine.OrderBook(symbolID).Order(4).Status())
+		require.NoError(t, engine.DeleteOrder(symbolID, 2))
+	})
+
+	t.Run("modified and replaced orders", func(t *testing.T) {
+		// Orders fully executed by the modification are deleted only once
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddOrder(gomock.Any(), orderInState(5, matching.OrderStatusNew, matching.OrderReasonNone))
+		expectDeletes(handler,
+			orderInState(3, matching.OrderStatusFilled, matching.OrderReasonNone),
+			orderInState(1, matching.OrderStatusFilled, matching.OrderReasonNone),
+			orderInState(4, matching.OrderStatusCancelled, matching.OrderReasonReplaced),
+			orderInState(5, matching.OrderStatusFilled, matching.OrderReasonNone),
+			orderInState(2, matching.OrderStatusFilled, matching.OrderReasonNone),
+		)
+		setupMockHandler(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 102, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 99, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 98, 2)))
+
+		require.NoError(t, engine.ModifyOrder(symbolID, 3, price(101), price(2)))
+		require.NoError(t, engine.ReplaceOrder(symbolID, 4, 5, price(102), price(2)))
+		require.Equal(t, 0, engine.OrderBook(symbolID).Size())
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 5)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 0, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 5)))
+
+		restored := matching.NewEngine(newRecordingHandler(), false)
+		require.NoError(t, restored.Restore(bytes.NewReader(takeSnapshot(t, engine))))
+
+		require.Equal(t, matching.OrderStatusPartiallyFilled, restored.OrderBook(symbolID).Order(1).Status())
+		require.Equal(t, matching.OrderStatusNew, restored.OrderBook(symbolID).Order(3).Status())
+	})
+}
@@ -0,0 +1,150 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestOrderTime(t *testing.T) {
+	requireTime := func(t *testing.T, expected, actual time.Time) {
+		require.True(t, expected.Equal(actual), "expected %s, actual %s", expected, actual)
+	}
+
+	t.Run("order lifecycle", func(t *testing.T) {
+		// Price level is added by the first order
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnAddPriceLevel(gomock.Any(), gomock.Any()).Do(
+			func(ob *matching.OrderBook, update matching.PriceLevelUpdate) {
+				requireTime(t, testTime, ob.Time())
+			})
+		expectStampedOrders(t, handler)
+		setupMockHandler(t, handler)
+		engine, _, clock := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+
+		clock.Advance(time.Minute)
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 100, 2)))
+
+		order := engine.OrderBook(symbolID).Order(1)
+		requireTime(t, testTime, order.AcceptTime())
+		requireTime(t, testTime.Add(time.Minute), order.UpdateTime())
+		require.True(t, order.ActivateTime().IsZero())
+		requireTime(t, testTime.Add(time.Minute), engine.OrderBook(symbolID).Time())
+	})
+
+	t.Run("activated order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectStampedOrders(t, handler)
+		setupMockHandler(t, handler)
+		engine, _, clock := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(matching.NewStopLimitOrder(
+			symbolID, 1, 0, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceGTC,
+			price(104),
+			matching.StopPriceModeMarket,
+			price(105),
+			price(1),
+			matching.NewMaxUint(),
+			price(1000000),
+		)))
+
+		clock.Advance(time.Minute)
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 105, 1)))
+
+		// The stop-limit order is activated by the trade and rests as the limit order
+		order := engine.OrderBook(symbolID).Order(1)
+		require.NotNil(t, order)
+		require.Equal(t, matching.OrderStatusTriggered, order.Status())
+		requireTime(t, testTime, order.AcceptTime())
+		requireTime(t, testTime.Add(time.Minute), order.ActivateTime())
+		requireTime(t, testTime.Add(time.Minute), order.UpdateTime())
+	})
+
+	t.Run("monotonic time", func(t *testing.T) {
+		// Rejected orders are stamped with the engine time as well
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(3), gomock.Any()).Do(
+			func(ob *matching.OrderBook, order *matching.Order, reason error) {
+				requireTime(t, testTime.Add(time.Minute), order.UpdateTime())
+			})
+		expectStampedOrders(t, handler)
+		setupMockHandler(t, handler)
+		engine, _, clock := newTestEngine(t, handler, false, 100)
+		clock.Advance(time.Minute)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 1)))
+
+		// The clock going back does not move the engine time back
+		clock.Set(testTime.Add(-time.Hour))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 101, 1)))
+		requireTime(t, testTime.Add(time.Minute), engine.OrderBook(symbolID).Order(2).AcceptTime())
+		requireTime(t, testTime.Add(time.Minute), engine.Time())
+
+		require.Error(t, engine.AddOrder(limitOrder(3, 0, 100, 1)))
+	})
+
+	t.Run("snapshot and replay", func(t *testing.T) {
+		clock := matching.NewManualClock(testTime)
+		engine := matching.NewEngine(newRecordingHandler(), false)
+		engine.SetClock(clock)
+		require.True(t, engine.Time().IsZero())
+		engine.EnableMatching()
+
+		var journal bytes.Buffer
+		engine.SetJournal(matching.NewJournal(&journal))
+		_, err := engine.AddOrderBook(testSymbol(), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		requireTime(t, testTime, engine.Time())
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 100, 5)))
+		clock.Advance(time.Minute)
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 100, 2)))
+
+		var snapshot bytes.Buffer
+		require.NoError(t, engine.Snapshot(&snapshot))
+
+		// Restored orders keep their times and the engine time is not moved back by the clock
+		restored := matching.NewEngine(newRecordingHandler(), false)
+		restored.SetClock(matching.NewManualClock(testTime))
+		require.NoError(t, restored.Restore(&snapshot))
+		requireTime(t, testTime.Add(time.Minute), restored.Time())
+		requireTime(t, testTime.Add(time.Minute), restored.OrderBook(symbolID).Time())
+		order := restored.OrderBook(symbolID).Order(1)
+		requireTime(t, testTime, order.AcceptTime())
+		requireTime(t, testTime.Add(time.Minute), order.UpdateTime())
+
+		// Replayed commands keep their original times regardless of the clock
+		replayed := matching.NewEngine(newRecordingHandler(), false)
+		replayed.SetClock(matching.NewManualClock(testTime.Add(time.Hour)))
+		replayed.EnableMatching()
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		order = replayed.OrderBook(symbolID).Order(1)
+		requireTime(t, testTime, order.AcceptTime())
+		requireTime(t, testTime.Add(time.Minute), order.UpdateTime())
+		requireTime(t, testTime.Add(time.Minute), replayed.Time())
+	})
+}
+
+// expectStampedOrders expects order events stamped with the time of the order book.
+func expectStampedOrders(t *testing.T, handler *mockmatching.MockHandler) {
+	handler.EXPECT().OnAddOrder(gomock.Any(), gomock.Any()).Do(
+		func(ob *matching.OrderBook, order *matching.Order) {
+			require.Equal(t, ob.Time(), order.AcceptTime())
+			require.Equal(t, ob.Time(), order.UpdateTime())
+		}).AnyTimes()
+	handler.EXPECT().OnUpdateOrder(gomock.Any(), gomock.Any()).Do(
+		func(ob *matching.OrderBook, order *matching.Order) {
+			require.Equal(t, ob.Time(), order.UpdateTime())
+		}).AnyTimes()
+	handler.EXPECT().OnDeleteOrder(gomock.Any(), gomock.Any()).Do(
+		func(ob *matching.OrderBook, order *matching.Order) {
+			require.Equal(t, ob.Time(), order.UpdateTime())
+		}).AnyTimes()
+}
@@ -0,0 +1,163 @@
+package matching_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+)
+
+func TestPeggedOrders(t *testing.T) {
+	requirePrice := func(t *testing.T, ob *matching.OrderBook, id uint64, p uint64) {
+		t.Helper()
+		require.True(t, ob.Order(id).Price().Equals(price(p)), "order %d price %s", id, ob.Order(id).Price().ToFloatString())
+	}
+
+	t.Run("initial prices", func(t *testing.T) {
+		// Bid at 99 and ask at 102
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, -1, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 11, matching.OrderSideSell, matching.PegReferencePrimary, 0, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 12, matching.OrderSideSell, matching.PegReferenceMarket, 2, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 13, matching.OrderSideBuy, matching.PegReferenceMidpoint, 0, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 14, matching.OrderSideSell, matching.PegReferenceMidpoint, 0, 0)))
+
+		requirePrice(t, ob, 10, 98)
+		requirePrice(t, ob, 11, 102)
+		requirePrice(t, ob, 12, 101)
+		requirePrice(t, ob, 13, 100)
+		requirePrice(t, ob, 14, 101)
+	})
+
+	t.Run("reprice on top of the book change", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 0, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 11, matching.OrderSideBuy, matching.PegReferencePrimary, -1, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 12, matching.OrderSideSell, matching.PegReferenceMidpoint, 0, 0)))
+		require.Equal(t, 2, ob.TopBid().Value().Orders())
+
+		// New best bid moves pegged orders to new price levels
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 100, 1)))
+		requirePrice(t, ob, 10, 100)
+		requirePrice(t, ob, 11, 99)
+		requirePrice(t, ob, 12, 101)
+		require.Equal(t, 2, ob.TopBid().Value().Orders())
+
+		// Deleted best bid moves pegged orders back
+		require.NoError(t, engine.DeleteOrder(symbolID, 3))
+		requirePrice(t, ob, 10, 99)
+		requirePrice(t, ob, 11, 98)
+	})
+
+	t.Run("cap price", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 0, 99)))
+
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 101, 1)))
+		requirePrice(t, ob, 10, 99)
+	})
+
+	t.Run("missing reference", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 1, 0)))
+		requirePrice(t, ob, 10, 100)
+
+		// Pegged order keeps its price without the reference
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+		requirePrice(t, ob, 10, 100)
+	})
+
+	t.Run("reprice crosses the book", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 2, 0)))
+		requirePrice(t, ob, 10, 101)
+
+		// Best bid at 101 moves the pegged order to 103, so it is executed with the ask at 102
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 101, 1)))
+		require.Nil(t, ob.Order(10))
+		require.Nil(t, ob.Order(2))
+		require.Nil(t, ob.TopAsk())
+	})
+
+	t.Run("invalid orders", func(t *testing.T) {
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		order := newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 0, 0)
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrPegPriceNotAvailable)
+
+		order = matching.NewPeggedOrder(
+			symbolID, 11, 0, matching.OrderSideSell,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			matching.PegReferencePrimary, 0, price(0),
+			price(1),
+			matching.NewMaxUint(),
+			price(1000000),
+		)
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidOrderPeg)
+		require.ErrorIs(t, engine.AddOrder(newPeggedOrder(symbolID, 12, matching.OrderSideSell, 0xff, 0, 0)), matching.ErrInvalidOrderPeg)
+
+		// Offset is limited by the range of price limits
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 99, 1)))
+		require.ErrorIs(t, engine.AddOrder(newPeggedOrder(symbolID, 13, matching.OrderSideBuy, matching.PegReferencePrimary, 1000000, 0)), matching.ErrInvalidOrderPeg)
+		require.ErrorIs(t, engine.AddOrder(newPeggedOrder(symbolID, 14, matching.OrderSideBuy, matching.PegReferencePrimary, math.MinInt64, 0)), matching.ErrInvalidOrderPeg)
+		require.ErrorIs(t, engine.AddOrder(newPeggedOrder(symbolID, 15, matching.OrderSideBuy, matching.PegReferencePrimary, 999999, 0)), matching.ErrPegPriceNotAvailable)
+	})
+
+	t.Run("reprice without matching", func(t *testing.T) {
+		engine, ob, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		engine.DisableMatching()
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 100, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 0, 0)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 11, matching.OrderSideSell, matching.PegReferenceMidpoint, 0, 0)))
+		requirePrice(t, ob, 10, 100)
+		requirePrice(t, ob, 11, 101)
+
+		// Deleted best bid moves pegged orders without matching
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+		requirePrice(t, ob, 10, 99)
+		requirePrice(t, ob, 11, 101)
+
+		// Crossed pegged orders are matched once the matching is enabled
+		require.NoError(t, engine.AddOrder(limitOrder(4, matching.OrderSideBuy, 103, 1)))
+		requirePrice(t, ob, 10, 103)
+		engine.EnableMatching()
+		require.Nil(t, ob.Order(3))
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		engine, _, _ := newTestEngine(t, newRecordingHandler(), false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 99, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 102, 1)))
+		require.NoError(t, engine.AddOrder(newPeggedOrder(symbolID, 10, matching.OrderSideBuy, matching.PegReferencePrimary, 0, 0)))
+
+		var buf bytes.Buffer
+		require.NoError(t, engine.Snapshot(&buf))
+
+		restored := matching.NewEngine(newRecordingHandler(), false)
+		require.NoError(t, restored.Restore(&buf))
+		restored.EnableMatching()
+		ob := restored.OrderBook(symbolID)
+		require.Equal(t, matching.PegReferencePrimary, ob.Order(10).PegReference())
+
+		require.NoError(t, restored.AddOrder(limitOrder(3, matching.OrderSideBuy, 100, 1)))
+		requirePrice(t, ob, 10, 100)
+	})
+}
@@ -0,0 +1,183 @@
+package matching_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestPostOnly(t *testing.T) {
+	// postOnly expects the rejected post-only order deleted before the reject
+	postOnly := func(handler *mockmatching.MockHandler, id uint64) {
+		gomock.InOrder(
+			handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(id, matching.OrderReasonPostOnly)),
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(id), errorIs(matching.ErrPostOnlyWouldTake)),
+		)
+	}
+	slid := func(handler *mockmatching.MockHandler, id uint64) {
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderWithReason(id, matching.OrderReasonPostOnlySlide))
+	}
+
+	t.Run("not crossed", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnly, 10, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideSell, matching.OrderTimeInForcePostOnlySlide, 11, 1)))
+
+		require.True(t, ob.Order(10).Price().Equals(price(10)))
+		require.True(t, ob.Order(11).Price().Equals(price(11)))
+		require.Equal(t, 4, ob.Size())
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		postOnly(handler, 10)
+		postOnly(handler, 11)
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnly, 11, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideSell, matching.OrderTimeInForcePostOnly, 8, 1)))
+
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("slide", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		slid(handler, 10)
+		slid(handler, 11)
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnlySlide, 12, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 0, matching.OrderSideSell, matching.OrderTimeInForcePostOnlySlide, 9, 1)))
+
+		require.True(t, ob.Order(10).Price().Equals(price(10)))
+		// The sell order slides above the previously slid bid
+		require.True(t, ob.Order(11).Price().Equals(price(11)))
+		require.Equal(t, 4, ob.Size())
+	})
+
+	t.Run("slide out of price limits", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		postOnly(handler, 10)
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 0, matching.OrderSideSell, matching.OrderTimeInForceGTC, 1, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnlySlide, 5, 1)))
+		require.Nil(t, ob.Order(10))
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		postOnly(handler, 10)
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnly, 10, 1)))
+		require.NoError(t, engine.ModifyOrder(symbolID, 10, price(11), price(1)))
+
+		require.Nil(t, ob.Order(10))
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		slid(handler, 12)
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideSell, matching.OrderTimeInForcePostOnlySlide, 10, 1)))
+		require.NoError(t, engine.ReplaceOrder(symbolID, 10, 12, price(9), price(1)))
+
+		require.True(t, ob.Order(12).Price().Equals(price(10)))
+	})
+
+	t.Run("stop-limit activation", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		gomock.InOrder(
+			handler.EXPECT().OnActivateOrder(gomock.Any(), orderWithID(10)),
+			handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(10, matching.OrderReasonPostOnly)),
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(10), errorIs(matching.ErrPostOnlyWouldTake)),
+		)
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		require.NoError(t, engine.AddOrder(matching.NewStopLimitOrder(
+			symbolID, 10, 0,
+			matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForcePostOnly,
+			price(12),
+			matching.StopPriceModeMarket,
+			price(10),
+			price(1),
+			matching.NewMaxUint(),
+			price(100),
+		)))
+
+		require.Nil(t, ob.Order(10))
+	})
+
+	t.Run("modify pending stop-limit", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler)
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+
+		// The stop price is not reached, so the order is checked only when activated
+		require.NoError(t, engine.AddOrder(matching.NewStopLimitOrder(
+			symbolID, 10, 0,
+			matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForcePostOnly,
+			price(10),
+			matching.StopPriceModeMarket,
+			price(15),
+			price(1),
+			matching.NewMaxUint(),
+			price(100),
+		)))
+		require.NoError(t, engine.ModifyOrder(symbolID, 10, price(12), price(1)))
+
+		require.NotNil(t, ob.Order(10))
+		require.Equal(t, matching.OrderTypeStopLimit, ob.Order(10).Type())
+		require.True(t, ob.Order(10).Price().Equals(price(12)))
+	})
+
+	t.Run("invalid order type", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(10), errorIs(matching.ErrInvalidOrderTimeInForce))
+		expectTrades(t, handler)
+		engine, _, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 1)))
+		err := engine.AddOrder(matching.NewMarketOrder(
+			symbolID, 10, 0,
+			matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForcePostOnly,
+			price(1),
+			matching.NewZeroUint(),
+			matching.NewMaxUint(),
+			price(100),
+		))
+		require.ErrorIs(t, err, matching.ErrInvalidOrderTimeInForce)
+	})
+}
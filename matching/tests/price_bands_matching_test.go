@@ -0,0 +1,260 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestPriceBands(t *testing.T) {
+	// outOfBand expects rejects of the given orders and errors of all commands out of band
+	outOfBand := func(handler *mockmatching.MockHandler, commands int, ids ...uint64) {
+		for _, id := range ids {
+			handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(id), errorIs(matching.ErrOrderPriceOutOfBand))
+		}
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrOrderPriceOutOfBand)).Times(commands)
+	}
+
+	// 10% from the market price
+	static := matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindPercent, Distance: matching.NewUint(1000)}
+
+	t.Run("reject limit order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		outOfBand(handler, 3, 10, 11)
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+
+		require.True(t, ob.ReferencePrice().Equals(price(100)))
+
+		// Price bands are set after orders are placed
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(static, matching.PriceBand{})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		err := engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 111, 1))
+		require.ErrorIs(t, err, matching.ErrOrderPriceOutOfBand)
+		err = engine.AddOrder(limitOrder(11, matching.OrderSideBuy, 89, 1))
+		require.ErrorIs(t, err, matching.ErrOrderPriceOutOfBand)
+		require.NoError(t, engine.AddOrder(limitOrder(12, matching.OrderSideBuy, 90, 1)))
+
+		err = engine.ModifyOrder(symbolID, 12, price(80), price(1))
+		require.ErrorIs(t, err, matching.ErrOrderPriceOutOfBand)
+		require.Equal(t, 4, ob.Size())
+	})
+
+	t.Run("circuit breaker", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		// Trade at 105 is more than 3 away from 101 so the book is halted
+		handler.EXPECT().OnUpdateOrderBook(inTradingState(matching.TradingStateHalted))
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(10, matching.OrderReasonCircuitBreaker))
+		expectTrades(t, handler, tradeBetween(1, 10))
+
+		dynamic := matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindAbsolute, Distance: price(3)}
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+
+		// Price bands are set after orders are placed
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(static, dynamic)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		order := matching.NewMarketOrder(
+			symbolID, 10, 0, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			price(3), matching.NewZeroUint(),
+			price(100),
+			price(1000),
+		)
+		require.NoError(t, engine.AddOrder(order))
+		require.Equal(t, matching.TradingStateHalted, ob.TradingState())
+		require.Equal(t, 2, ob.Size())
+
+		// Resumed trading anchors the static band to the last trade price
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateTrading))
+		require.True(t, ob.ReferencePrice().Equals(price(101)))
+	})
+
+	t.Run("circuit breaker cancels the limit order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		// Trade at 96 is more than 3 away from 100 so the book is halted before the execution
+		handler.EXPECT().OnUpdateOrderBook(inTradingState(matching.TradingStateHalted))
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), orderInState(10, matching.OrderStatusCancelled, matching.OrderReasonCircuitBreaker))
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 96, 1)))
+
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(matching.PriceBand{}, matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindAbsolute, Distance: price(3)})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		// The rest of the order is not placed crossing the top ask
+		require.NoError(t, engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 102, 1)))
+		require.Equal(t, matching.TradingStateHalted, ob.TradingState())
+		require.Nil(t, ob.Order(10))
+		require.Nil(t, ob.TopBid())
+		require.True(t, ob.TopAsk().Value().Price().Equals(price(96)))
+	})
+
+	t.Run("static band sweep", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(1, 10), tradeBetween(2, 10))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+
+		// Price bands are set after orders are placed
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindPercent, Distance: matching.NewUint(500)}, matching.PriceBand{})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		require.NoError(t, engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 105, 3)))
+
+		// Trade at 105 is within 5% from 100, the rest of the order is placed
+		require.Equal(t, matching.TradingStateTrading, ob.TradingState())
+		require.True(t, ob.Order(10).RestQuantity().Equals(price(1)))
+	})
+
+	t.Run("fill or kill", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(10), errorIs(matching.ErrFOKUnfillable))
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(4, matching.OrderSideSell, 94, 1)))
+
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(matching.PriceBand{}, matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindAbsolute, Distance: price(5)})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		// Full execution requires the trade at 94 outside of the dynamic band
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForceFOK, 101, 2)))
+		require.Equal(t, matching.TradingStateTrading, ob.TradingState())
+		require.Equal(t, 4, ob.Size())
+	})
+
+	t.Run("mark price reference", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		outOfBand(handler, 1, 10)
+		setupMockHandler(t, handler)
+
+		dynamic := matching.PriceBand{Reference: matching.PriceBandReferenceMark, Kind: matching.PriceBandKindAbsolute, Distance: price(5)}
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+
+		// Price bands are set after orders are placed
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(matching.PriceBand{}, dynamic)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		require.NoError(t, engine.SetMarkPriceForOrderBook(symbolID, price(90), false))
+
+		err := engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 96, 1))
+		require.ErrorIs(t, err, matching.ErrOrderPriceOutOfBand)
+		require.NoError(t, engine.AddOrder(limitOrder(11, matching.OrderSideBuy, 95, 1)))
+	})
+
+	t.Run("small absolute distance", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		outOfBand(handler, 1, 10)
+		setupMockHandler(t, handler)
+
+		// Absolute distance is not taken for the percentage one however small it is
+		small := matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindAbsolute, Distance: matching.NewUint(10000)}
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+
+		// Price bands are set after orders are placed
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(small, matching.PriceBand{})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		err := engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 99, 1))
+		require.ErrorIs(t, err, matching.ErrOrderPriceOutOfBand)
+	})
+
+	t.Run("post-only slide", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(10), errorIs(matching.ErrPostOnlyWouldTake))
+		handler.EXPECT().OnDeleteOrder(gomock.Any(), orderInState(10, matching.OrderStatusRejected, matching.OrderReasonPostOnly))
+		expectTrades(t, handler)
+
+		// Slid price 99 is outside of 0.5% from the market price
+		band := matching.PriceBand{Reference: matching.PriceBandReferenceMarket, Kind: matching.PriceBandKindPercent, Distance: matching.NewUint(50)}
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 101, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideSell, 109, 1)))
+
+		// Price bands are set after orders are placed
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(band, matching.PriceBand{})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+		require.NoError(t, engine.AddOrder(limitOrder(4, matching.OrderSideSell, 100, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 0, matching.OrderSideBuy, matching.OrderTimeInForcePostOnlySlide, 100, 1)))
+		require.Nil(t, ob.Order(10))
+	})
+
+	t.Run("band anchored when updated", func(t *testing.T) {
+		var journal bytes.Buffer
+		handler := newRecordingHandler()
+		engine, ob, _ := newTestEngine(t, handler, false, 100)
+		engine.SetJournal(matching.NewJournal(&journal))
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideSell, 105, 1)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideBuy, 105, 1)))
+
+		// Static price band set after the trade is anchored to the last trade price
+		symbol := ob.Symbol()
+		symbol.SetPriceBands(static, matching.PriceBand{})
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.True(t, ob.ReferencePrice().Equals(price(105)))
+		err := engine.AddOrder(limitOrder(10, matching.OrderSideBuy, 94, 1))
+		require.ErrorIs(t, err, matching.ErrOrderPriceOutOfBand)
+
+		// Symbol update is journaled
+		replayed := matching.NewEngine(newRecordingHandler(), false)
+		replayed.EnableMatching()
+		_, err = replayed.AddOrderBook(testSymbol(), price(100), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		require.Equal(t, static, replayed.OrderBook(symbolID).Symbol().StaticPriceBand())
+		require.True(t, replayed.OrderBook(symbolID).ReferencePrice().Equals(price(105)))
+		require.Nil(t, replayed.OrderBook(symbolID).Order(10))
+	})
+
+	t.Run("invalid band", func(t *testing.T) {
+		for _, band := range []matching.PriceBand{
+			{Kind: matching.PriceBandKindPercent, Distance: matching.NewUint(1000)},
+			{Reference: matching.PriceBandReferenceMarket, Distance: matching.NewUint(1000)},
+		} {
+			symbol := testSymbol()
+			symbol.SetPriceBands(band, matching.PriceBand{})
+			engine := matching.NewEngine(mockmatching.NewMockHandler(gomock.NewController(t)), false)
+			_, err := engine.AddOrderBook(symbol, price(100), matching.StopPriceModeConfig{Market: true})
+			require.ErrorIs(t, err, matching.ErrInvalidSymbol)
+		}
+	})
+}
@@ -33,6 +33,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -52,6 +53,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -78,6 +80,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -97,6 +100,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -123,6 +127,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -141,6 +146,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -159,6 +165,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(7),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -184,6 +191,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -202,6 +210,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -220,6 +229,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(7),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -245,6 +255,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -263,6 +274,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -281,6 +293,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(7),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -306,6 +319,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -324,6 +338,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -342,6 +357,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(7),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -376,6 +392,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -418,6 +435,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -460,6 +478,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -502,6 +521,7 @@ func TestStopLimitOrdersMatching(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				stopLimitOrderID,
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -0,0 +1,194 @@
+package matching_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestSelfTradePrevention(t *testing.T) {
+	// prevented expects deletions of the given orders by the self-trade prevention
+	prevented := func(handler *mockmatching.MockHandler, ids ...uint64) {
+		for _, id := range ids {
+			handler.EXPECT().OnDeleteOrder(gomock.Any(), orderWithReason(id, matching.OrderReasonSelfTradePrevention))
+		}
+	}
+	gtc := matching.OrderTimeInForceGTC
+
+	t.Run("no prevention", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(10, 11))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 1, matching.OrderSideBuy, gtc, 10, 2)))
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("different owners", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler, tradeBetween(10, 11))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionCancelNewest)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 2, matching.OrderSideBuy, gtc, 10, 2)))
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("cancel newest", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 11)
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionCancelNewest)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 1, matching.OrderSideBuy, gtc, 10, 3)))
+		require.NotNil(t, ob.Order(10))
+		require.Nil(t, ob.Order(11))
+	})
+
+	t.Run("cancel newest market order", func(t *testing.T) {
+		// The canceled market order is deleted only once and is not placed
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 11)
+		expectTrades(t, handler, tradeBetween(10, 12))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionCancelNewest)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(matching.NewMarketOrder(
+			symbolID, 11, 1, matching.OrderSideBuy,
+			matching.OrderDirectionClose,
+			matching.OrderTimeInForceIOC,
+			price(3), matching.NewZeroUint(),
+			price(100),
+			price(1000),
+		)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 12, 2, matching.OrderSideBuy, gtc, 10, 1)))
+		require.True(t, ob.Order(10).RestQuantity().Equals(price(1)))
+		require.Nil(t, ob.Order(11))
+	})
+
+	t.Run("cancel oldest", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 10)
+		expectTrades(t, handler, tradeBetween(11, 12))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 2, matching.OrderSideSell, gtc, 10, 1)))
+		order := newLimitOrder(symbolID, 12, 1, matching.OrderSideBuy, gtc, 10, 2)
+		order.SetSelfTradePrevention(matching.SelfTradePreventionCancelOldest)
+		require.NoError(t, engine.AddOrder(order))
+		require.Nil(t, ob.Order(10))
+		require.True(t, ob.Order(12).RestQuantity().Equals(price(1)))
+	})
+
+	t.Run("cancel both", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 10, 11)
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionCancelBoth)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 1, matching.OrderSideBuy, gtc, 10, 1)))
+		require.Equal(t, 0, ob.Size())
+	})
+
+	t.Run("decrement and cancel", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 10)
+		handler.EXPECT().OnUpdateOrder(gomock.Any(), orderWithReason(11, matching.OrderReasonSelfTradePrevention)).Do(
+			func(ob *matching.OrderBook, order *matching.Order) {
+				require.True(t, order.RestQuantity().Equals(price(3)))
+			})
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionDecrementAndCancel)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 1, matching.OrderSideBuy, gtc, 10, 5)))
+		require.Nil(t, ob.Order(10))
+		require.True(t, ob.Order(11).RestQuantity().Equals(price(3)))
+		require.Equal(t, matching.OrderReasonNone, ob.Order(11).Reason())
+	})
+
+	t.Run("decrement resting order", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 11)
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionDecrementAndCancel)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 5)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 1, matching.OrderSideBuy, gtc, 10, 2)))
+		require.Nil(t, ob.Order(11))
+		require.True(t, ob.Order(10).RestQuantity().Equals(price(3)))
+		require.True(t, ob.TopAsk().Value().Volume().Equals(price(3)))
+	})
+
+	t.Run("crossed order book", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		prevented(handler, 10)
+		expectTrades(t, handler, tradeBetween(11, 12))
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionCancelOldest)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		engine.DisableMatching()
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 1, matching.OrderSideBuy, gtc, 10, 2)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 2, matching.OrderSideBuy, gtc, 10, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 12, 1, matching.OrderSideSell, gtc, 10, 2)))
+		engine.Match()
+
+		require.Nil(t, ob.Order(10))
+		require.Nil(t, ob.Order(11))
+		require.True(t, ob.Order(12).RestQuantity().Equals(price(1)))
+	})
+
+	t.Run("fill or kill", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(12), errorIs(matching.ErrFOKUnfillable))
+		expectTrades(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		symbol := ob.Symbol()
+		symbol.SetSelfTradePrevention(matching.SelfTradePreventionCancelNewest)
+		require.NoError(t, engine.UpdateSymbol(symbol))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 2, matching.OrderSideSell, gtc, 10, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 11, 1, matching.OrderSideSell, gtc, 10, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 12, 1, matching.OrderSideBuy, matching.OrderTimeInForceFOK, 10, 2)))
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(10), errorIs(matching.ErrInvalidSelfTradePrevention))
+		setupMockHandler(t, handler)
+
+		engine, _, _ := newTestEngine(t, handler, false, 10)
+		order := newLimitOrder(symbolID, 10, 1, matching.OrderSideSell, gtc, 10, 1)
+		order.SetSelfTradePrevention(matching.SelfTradePrevention(100))
+		require.ErrorIs(t, engine.AddOrder(order), matching.ErrInvalidSelfTradePrevention)
+	})
+}
@@ -39,6 +39,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -55,6 +56,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(6),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceGTC,
@@ -85,6 +87,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceIOC,
@@ -108,6 +111,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -123,6 +127,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(6),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -147,6 +152,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -163,6 +169,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(6),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceIOC,
@@ -178,7 +185,10 @@ func TestTimeInForce(t *testing.T) {
 
 	// FOK
 	t.Run("FOK - empty OB", func(t *testing.T) {
-		engine := matching.NewEngine(setupHandler(t), false)
+		handler := mockmatching.NewMockHandler(ctrl)
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(5), errorIs(matching.ErrFOKUnfillable))
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
 		engine.EnableMatching()
 
 		ob, err := engine.AddOrderBook(matching.NewSymbol(symbolID, ""), matching.NewUint(0), matching.StopPriceModeConfig{Market: true, Mark: true, Index: true})
@@ -188,6 +198,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceFOK,
@@ -201,7 +212,10 @@ func TestTimeInForce(t *testing.T) {
 	})
 
 	t.Run("FOK - prepared OB for partial match", func(t *testing.T) {
-		engine := matching.NewEngine(setupHandler(t), false)
+		handler := mockmatching.NewMockHandler(ctrl)
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(6), errorIs(matching.ErrFOKUnfillable))
+		setupMockHandler(t, handler)
+		engine := matching.NewEngine(handler, false)
 		engine.EnableMatching()
 
 		ob, err := engine.AddOrderBook(matching.NewSymbol(symbolID, ""), matching.NewUint(0), matching.StopPriceModeConfig{Market: true, Mark: true, Index: true})
@@ -211,6 +225,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -226,6 +241,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(6),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceFOK,
@@ -250,6 +266,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -266,6 +283,7 @@ func TestTimeInForce(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(6),
+			0,
 			matching.OrderSideSell,
 			matching.OrderDirectionClose,
 			matching.OrderTimeInForceFOK,
@@ -324,6 +342,7 @@ func TestTimeInForceFOK(t *testing.T) {
 	ob, err := engine.AddOrderBook(matching.NewSymbol(symbolID, ""), matching.NewUint(0), matching.StopPriceModeConfig{Market: true, Mark: true, Index: true})
 	require.NoError(t, err)
 	handler.EXPECT().OnError(ob, gomock.Any()).AnyTimes()
+	handler.EXPECT().OnRejectOrder(ob, orderWithID(100), errorIs(matching.ErrFOKUnfillable)).Times(2)
 
 	for _, tc := range testCases {
 		for _, g := range gtcState {
@@ -340,6 +359,7 @@ func TestTimeInForceFOK(t *testing.T) {
 			err = engine.AddOrder(matching.NewLimitOrder(
 				symbolID,
 				g.orderID,
+				0,
 				gtcSide,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -355,6 +375,7 @@ func TestTimeInForceFOK(t *testing.T) {
 		err = engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(100),
+			0,
 			tc.fokSide,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceFOK,
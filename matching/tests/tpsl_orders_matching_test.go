@@ -36,6 +36,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -53,6 +54,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -66,6 +68,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -96,11 +99,13 @@ func TestTPSLOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrBuySLStopPriceLessThanEnginePrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrBuySLStopPriceLessThanEnginePrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrBuySLStopPriceLessThanEnginePrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -118,6 +123,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -131,6 +137,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -156,11 +163,13 @@ func TestTPSLOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrBuyTPStopPriceGreaterThanEnginePrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrBuyTPStopPriceGreaterThanEnginePrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrBuyTPStopPriceGreaterThanEnginePrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -178,6 +187,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -191,6 +201,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -216,11 +227,13 @@ func TestTPSLOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrSellSLStopPriceGreaterThanEnginePrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrSellSLStopPriceGreaterThanEnginePrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrSellSLStopPriceGreaterThanEnginePrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -238,6 +251,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -251,6 +265,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -276,11 +291,13 @@ func TestTPSLOrders(t *testing.T) {
 		setupMarketState(t, engine, symbolID)
 		ob := engine.OrderBook(symbolID)
 
-		handler.EXPECT().OnError(ob, matching.ErrSellTPStopPriceLessThanEnginePrice)
+		handler.EXPECT().OnRejectOrder(ob, gomock.Any(), errorIs(matching.ErrSellTPStopPriceLessThanEnginePrice)).Times(2)
+		handler.EXPECT().OnError(ob, errorIs(matching.ErrSellTPStopPriceLessThanEnginePrice))
 
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -298,6 +315,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -311,6 +329,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideSell,
 				matching.OrderDirectionClose,
 				matching.OrderTimeInForceGTC,
@@ -336,6 +355,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -353,6 +373,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -366,6 +387,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -398,6 +420,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -415,6 +438,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -428,6 +452,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -460,6 +485,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -477,6 +503,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -490,6 +517,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -517,6 +545,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -534,6 +563,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -547,6 +577,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -574,6 +605,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -591,6 +623,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -604,6 +637,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -631,6 +665,7 @@ func TestTPSLOrders(t *testing.T) {
 		err := engine.AddOrder(matching.NewLimitOrder(
 			symbolID,
 			uint64(5),
+			0,
 			matching.OrderSideBuy,
 			matching.OrderDirectionOpen,
 			matching.OrderTimeInForceGTC,
@@ -648,6 +683,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(6),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
@@ -661,6 +697,7 @@ func TestTPSLOrders(t *testing.T) {
 			matching.NewStopLimitOrder(
 				symbolID,
 				uint64(7),
+				0,
 				matching.OrderSideBuy,
 				matching.OrderDirectionOpen,
 				matching.OrderTimeInForceGTC,
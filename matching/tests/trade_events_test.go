@@ -0,0 +1,133 @@
+package matching_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+// tradeHandler is the mock handler receiving trades with OnTrade().
+type tradeHandler struct {
+	*mockmatching.MockHandler
+	*mockmatching.MockTradeHandler
+}
+
+// newTradeHandler returns the handler allowing any order events and collecting executed trades.
+func newTradeHandler(t *testing.T) (*tradeHandler, *[]matching.Trade) {
+	ctrl := gomock.NewController(t)
+	handler := &tradeHandler{mockmatching.NewMockHandler(ctrl), mockmatching.NewMockTradeHandler(ctrl)}
+
+	trades := []matching.Trade{}
+	handler.MockTradeHandler.EXPECT().OnTrade(gomock.Any(), gomock.Any()).Do(
+		func(ob *matching.OrderBook, trade matching.Trade) {
+			trades = append(trades, trade)
+		}).AnyTimes()
+	setupMockOrderEvents(t, handler.MockHandler)
+	return handler, &trades
+}
+
+func TestTradeEvents(t *testing.T) {
+	t.Run("incoming order", func(t *testing.T) {
+		handler, executed := newTradeHandler(t)
+		engine, _, clock := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 11, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 12, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 1)))
+
+		clock.Advance(time.Minute)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 13, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 101, 2)))
+
+		require.Len(t, *executed, 2)
+		for i, trade := range *executed {
+			require.Equal(t, uint64(i+1), trade.ID)
+			require.Equal(t, testTime.Add(time.Minute), trade.Time.UTC())
+			require.Equal(t, matching.OrderSideBuy, trade.AggressorSide)
+			require.Equal(t, matching.OrderSideSell, trade.MakerSide)
+			require.Equal(t, uint64(3), trade.TakerOrderID)
+			require.Equal(t, uint64(13), trade.TakerOwnerID)
+			require.Equal(t, matching.OrderSideBuy, trade.TakerSide)
+		}
+		require.Equal(t, uint64(1), (*executed)[0].MakerOrderID)
+		require.Equal(t, uint64(11), (*executed)[0].MakerOwnerID)
+		require.Equal(t, uint64(2), (*executed)[1].MakerOrderID)
+		require.Equal(t, uint64(12), (*executed)[1].MakerOwnerID)
+	})
+
+	t.Run("acknowledged trades", func(t *testing.T) {
+		handler, executed := newTradeHandler(t)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 11, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 1)))
+
+		trades, err := engine.AddOrderWait(context.Background(), newLimitOrder(symbolID, 2, 12, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1))
+		require.NoError(t, err)
+		require.Equal(t, *executed, trades)
+		require.Equal(t, matching.OrderSideSell, trades[0].AggressorSide)
+	})
+
+	t.Run("crossed orders", func(t *testing.T) {
+		handler, executed := newTradeHandler(t)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		engine.DisableMatching()
+
+		// The order with the greater ID has come earlier, so it is the maker
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 10, 11, matching.OrderSideSell, matching.OrderTimeInForceGTC, 99, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 5, 12, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 101, 1)))
+		engine.EnableMatching()
+
+		require.Len(t, *executed, 1)
+		trade := (*executed)[0]
+		require.Equal(t, uint64(10), trade.MakerOrderID)
+		require.Equal(t, uint64(5), trade.TakerOrderID)
+		require.Equal(t, matching.OrderSideBuy, trade.AggressorSide)
+		require.True(t, trade.Price.Equals(price(99)))
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		handler, _ := newTradeHandler(t)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 11, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 12, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+		engine.DisableMatching()
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 4, 14, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 13, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 1)))
+
+		var snapshot bytes.Buffer
+		require.NoError(t, engine.Snapshot(&snapshot))
+
+		// Trade IDs and placement of orders are kept by the restore
+		handler, executed := newTradeHandler(t)
+		restored := matching.NewEngine(handler, false)
+		require.NoError(t, restored.Restore(&snapshot))
+		restored.EnableMatching()
+
+		require.Len(t, *executed, 1)
+		require.Equal(t, uint64(2), (*executed)[0].ID)
+		require.Equal(t, uint64(4), (*executed)[0].MakerOrderID)
+		require.Equal(t, uint64(3), (*executed)[0].TakerOrderID)
+
+		require.NoError(t, restored.AddOrder(newLimitOrder(symbolID, 5, 15, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 100, 1)))
+		require.NoError(t, restored.AddOrder(newLimitOrder(symbolID, 6, 16, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+		require.Len(t, *executed, 2)
+		require.Equal(t, uint64(3), (*executed)[1].ID)
+	})
+
+	t.Run("order updates", func(t *testing.T) {
+		// Handlers without OnTrade() receive updates of orders
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		expectTrades(t, handler,
+			tradeBetween(1, 3).with(price(100), price(1), price(100)),
+			tradeBetween(2, 3).with(price(101), price(1), price(101)),
+		)
+		engine, _, _ := newTestEngine(t, handler, false, 100)
+
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 1, 11, matching.OrderSideSell, matching.OrderTimeInForceGTC, 100, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 2, 12, matching.OrderSideSell, matching.OrderTimeInForceGTC, 101, 1)))
+		require.NoError(t, engine.AddOrder(newLimitOrder(symbolID, 3, 13, matching.OrderSideBuy, matching.OrderTimeInForceGTC, 101, 2)))
+	})
+}
@@ -0,0 +1,130 @@
+package matching_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	matching "github.com/cryptonstudio/crypton-matching-engine/matching"
+	mockmatching "github.com/cryptonstudio/crypton-matching-engine/matching/mocks"
+)
+
+func TestTradingState(t *testing.T) {
+	// forbidden expects the reject of the given order and errors of the given number of commands
+	forbidden := func(handler *mockmatching.MockHandler, commands int, id uint64) {
+		handler.EXPECT().OnRejectOrder(gomock.Any(), orderWithID(id), errorIs(matching.ErrForbiddenTradingState))
+		handler.EXPECT().OnError(gomock.Any(), errorIs(matching.ErrForbiddenTradingState)).Times(commands)
+	}
+
+	t.Run("halted", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		handler.EXPECT().OnUpdateOrderBook(inTradingState(matching.TradingStateHalted))
+		forbidden(handler, 4, 3)
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+		require.Equal(t, matching.TradingStateTrading, ob.TradingState())
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateHalted))
+
+		errForbidden := matching.ErrForbiddenTradingState
+		require.ErrorIs(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 11, 1)), errForbidden)
+		require.ErrorIs(t, engine.ModifyOrder(symbolID, 1, price(10), price(2)), errForbidden)
+		require.ErrorIs(t, engine.ReplaceOrder(symbolID, 1, 4, price(10), price(2)), errForbidden)
+		require.ErrorIs(t, engine.ReduceOrder(symbolID, 1, price(1)), errForbidden)
+		require.NoError(t, engine.DeleteOrder(symbolID, 1))
+		require.Equal(t, 1, ob.Size())
+	})
+
+	t.Run("cancel only", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		forbidden(handler, 1, 3)
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateCancelOnly))
+
+		require.ErrorIs(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 11, 1)), matching.ErrForbiddenTradingState)
+		require.NoError(t, engine.ReduceOrder(symbolID, 1, price(1)))
+		require.True(t, ob.Order(1).RestQuantity().Equals(price(1)))
+		require.NoError(t, engine.DeleteOrder(symbolID, 2))
+		require.Equal(t, 1, ob.Size())
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		forbidden(handler, 2, 3)
+		setupMockHandler(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateClosed))
+
+		require.ErrorIs(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 11, 1)), matching.ErrForbiddenTradingState)
+		require.ErrorIs(t, engine.DeleteOrder(symbolID, 1), matching.ErrForbiddenTradingState)
+		require.Equal(t, 2, ob.Size())
+
+		// Closed order book is opened with the auction
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 11, 1)))
+		require.NoError(t, engine.StopAuction(symbolID))
+		require.Equal(t, matching.TradingStateTrading, ob.TradingState())
+		require.Nil(t, ob.Order(3))
+	})
+
+	t.Run("resume trading", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		halted := handler.EXPECT().OnUpdateOrderBook(inTradingState(matching.TradingStateHalted))
+		tradeBetween(2, 3).expect(handler).After(halted)
+		setupMockOrderEvents(t, handler)
+
+		engine, ob, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+		require.NoError(t, engine.StartAuction(symbolID))
+		require.NoError(t, engine.AddOrder(limitOrder(3, matching.OrderSideBuy, 12, 1)))
+
+		// Abandoned auction is not uncrossed, crossed orders are matched when continuous trading is resumed
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateHalted))
+		require.NoError(t, engine.SetTradingState(symbolID, matching.TradingStateTrading))
+		require.Equal(t, 2, ob.Size())
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		handler := mockmatching.NewMockHandler(gomock.NewController(t))
+		setupMockHandler(t, handler)
+
+		engine, _, _ := newTestEngine(t, handler, false, 10)
+		require.NoError(t, engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 2)))
+		require.NoError(t, engine.AddOrder(limitOrder(2, matching.OrderSideSell, 11, 2)))
+		require.ErrorIs(t, engine.SetTradingState(symbolID, 0), matching.ErrInvalidTradingState)
+		require.ErrorIs(t, engine.SetTradingState(symbolID+1, matching.TradingStateHalted), matching.ErrOrderBookNotFound)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		var journal bytes.Buffer
+		handler := newRecordingHandler()
+		engine := matching.NewEngine(handler, false)
+		engine.SetJournal(matching.NewJournal(&journal))
+		engine.EnableMatching()
+		_, err := engine.AddOrderBook(testSymbol(), price(10), matching.StopPriceModeConfig{Market: true})
+		require.NoError(t, err)
+		_ = engine.AddOrder(limitOrder(1, matching.OrderSideBuy, 9, 2))
+		_ = engine.SetTradingState(symbolID, matching.TradingStateHalted)
+		_ = engine.AddOrder(limitOrder(2, matching.OrderSideSell, 9, 2))
+		_ = engine.SetTradingState(symbolID, matching.TradingStateTrading)
+		_ = engine.AddOrder(limitOrder(3, matching.OrderSideSell, 9, 1))
+
+		replayedHandler := newRecordingHandler()
+		replayed := matching.NewEngine(replayedHandler, false)
+		require.NoError(t, replayed.Replay(bytes.NewReader(journal.Bytes())))
+		require.Equal(t, handler.events(), replayedHandler.events())
+		require.Equal(t, []string{"trade maker=1 taker=3"}, replayedHandler.trades())
+	})
+}
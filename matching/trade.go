@@ -0,0 +1,41 @@
+package matching
+
+import (
+	"time"
+)
+
+// Trade is the execution of the resting maker order with the taker order.
+type Trade struct {
+	// Trade ID, unique and monotonically increasing within the order book starting from 1.
+	ID uint64
+
+	// Time of the command caused the trade.
+	Time time.Time
+
+	// Side of the taker order, which has initiated the trade.
+	AggressorSide OrderSide
+
+	// Maker order, which has come earlier.
+	MakerOrderID uint64
+	MakerOwnerID uint64
+	MakerSide    OrderSide
+
+	// Taker order, which has come later.
+	TakerOrderID uint64
+	TakerOwnerID uint64
+	TakerSide    OrderSide
+
+	Price         Uint
+	Quantity      Uint
+	QuoteQuantity Uint
+}
+
+// makerOrderUpdate returns the update of the maker order passed to Handler.OnExecuteTrade().
+func (t *Trade) makerOrderUpdate() OrderUpdate {
+	return OrderUpdate{ID: t.MakerOrderID, Quantity: t.Quantity, QuoteQuantity: t.QuoteQuantity}
+}
+
+// takerOrderUpdate returns the update of the taker order passed to Handler.OnExecuteTrade().
+func (t *Trade) takerOrderUpdate() OrderUpdate {
+	return OrderUpdate{ID: t.TakerOrderID, Quantity: t.Quantity, QuoteQuantity: t.QuoteQuantity}
+}
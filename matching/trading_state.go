@@ -0,0 +1,70 @@
+package matching
+
+// TradingState is an enumeration of possible trading states of the order book.
+// The state defines which operations are allowed for the order book:
+//
+//	State        Add/Modify  Reduce  Delete  Matching/Execution
+//	Trading      yes         yes     yes     yes
+//	Auction      yes         yes     yes     no (uncrossed at the auction end)
+//	Halted       no          no      yes     no
+//	CancelOnly   no          yes     yes     no
+//	Closed       no          no      no      no
+//
+// Expired GTD orders are deleted in any state.
+type TradingState uint8
+
+const (
+	// TradingStateTrading represents the continuous trading (default state).
+	TradingStateTrading TradingState = iota + 1
+	// TradingStateAuction represents the pre-open or closing call auction,
+	// orders are accumulated without matching.
+	TradingStateAuction
+	// TradingStateHalted represents the halted trading, orders can only be deleted.
+	TradingStateHalted
+	// TradingStateCancelOnly represents the trading where orders can only be reduced or deleted.
+	TradingStateCancelOnly
+	// TradingStateClosed represents the closed order book, orders can not be changed at all.
+	TradingStateClosed
+)
+
+func (ts TradingState) String() string {
+	switch ts {
+	case TradingStateTrading:
+		return "trading"
+	case TradingStateAuction:
+		return "auction"
+	case TradingStateHalted:
+		return "halted"
+	case TradingStateCancelOnly:
+		return "cancel-only"
+	case TradingStateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Valid returns true if the trading state is known.
+func (ts TradingState) Valid() bool {
+	return ts >= TradingStateTrading && ts <= TradingStateClosed
+}
+
+// canAddOrders returns true if new orders can be added and existing ones modified or replaced.
+func (ts TradingState) canAddOrders() bool {
+	return ts == TradingStateTrading || ts == TradingStateAuction
+}
+
+// canReduceOrders returns true if orders can be reduced.
+func (ts TradingState) canReduceOrders() bool {
+	return ts == TradingStateTrading || ts == TradingStateAuction || ts == TradingStateCancelOnly
+}
+
+// canDeleteOrders returns true if orders can be deleted.
+func (ts TradingState) canDeleteOrders() bool {
+	return ts != TradingStateClosed
+}
+
+// canMatch returns true if orders can be matched or executed.
+func (ts TradingState) canMatch() bool {
+	return ts == TradingStateTrading
+}
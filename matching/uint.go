@@ -3,6 +3,8 @@ package matching
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"math/bits"
 	"strings"
 
 	"lukechampine.com/uint128"
@@ -148,6 +150,19 @@ func (u Uint) QuoRem(v Uint) (Uint, Uint) {
 	return u, Uint{v: remainder}
 }
 
+// MulQuo returns u*v/d computing the intermediate product without overflow.
+// The result itself must fit into Uint.
+func (u Uint) MulQuo(v Uint, d Uint) Uint {
+	if u.v.Hi == 0 && v.v.Hi == 0 {
+		hi, lo := bits.Mul64(u.v.Lo, v.v.Lo)
+		u.v = uint128.New(lo, hi).Div(d.v)
+		return u
+	}
+	product := new(big.Int).Mul(u.v.Big(), v.v.Big())
+	u.v = uint128.FromBig(product.Quo(product, d.v.Big()))
+	return u
+}
+
 func (u Uint) Div64(v uint64) Uint {
 	u.v = u.v.Div64(v)
 	return u
@@ -211,6 +211,32 @@ func TestUintQuoRem(t *testing.T) {
 	}
 }
 
+func TestUintMulQuo(t *testing.T) {
+	tc := []struct {
+		number   Uint
+		mul      Uint
+		div      Uint
+		expected Uint
+	}{
+		{
+			number:   NewUint(15),
+			mul:      NewUint(10),
+			div:      NewUint(100),
+			expected: NewUint(1),
+		},
+		{
+			number:   NewUint(40_000_000).Mul64(UintPrecision),
+			mul:      NewUint(20_000_000).Mul64(UintPrecision),
+			div:      NewUint(80_000_000).Mul64(UintPrecision),
+			expected: NewUint(10_000_000).Mul64(UintPrecision),
+		},
+	}
+
+	for _, v := range tc {
+		require.Equal(t, v.expected.String(), v.number.MulQuo(v.mul, v.div).String())
+	}
+}
+
 func BenchmarkRemoveTrailingZeros(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = removeTrailingZerosFromFloatStr("123.00100")